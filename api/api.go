@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/icoder-new/avito-shop/api/handler"
+	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/pkg/logger"
 )
 
@@ -46,12 +47,107 @@ func SetUpRoutes(h *handler.Handler, log *logger.Logger) *gin.Engine {
 	})
 
 	route.POST("/auth", h.Login)
+	route.POST("/slack/commands",
+		handler.SlackSignatureMiddleware(log, h.Cfg.Credentials.Slack.SigningSecret),
+		h.SlackCommand,
+	)
 
 	protected := route.Group("")
 	protected.Use(handler.AuthMiddleware(log, h.Manager))
 	protected.GET("/info", h.GetUserInfo)
 	protected.POST("/sendCoin", h.SendCoin)
+	protected.POST("/sendCoin/batch", h.SendCoinBatch)
+	protected.GET("/limits", h.GetLimits)
 	protected.GET("/buy/:item", h.BuyItem)
+	protected.POST("/gifts", h.SendGift)
+	protected.POST("/inventory/transfer", h.TransferItem)
+	protected.GET("/marketplace", h.SearchListings)
+	protected.GET("/marketplace/my", h.GetMyListings)
+	protected.POST("/marketplace", h.CreateListing)
+	protected.POST("/marketplace/:id/buy", h.BuyListing)
+	protected.POST("/marketplace/:id/cancel", h.CancelListing)
+	protected.GET("/wishlist", h.GetWishlist)
+	protected.POST("/wishlist", h.AddToWishlist)
+	protected.DELETE("/wishlist/:id", h.RemoveFromWishlist)
+	protected.GET("/notifications", h.GetNotifications)
+	protected.POST("/notifications/read", h.MarkNotificationsRead)
+	protected.GET("/notifications/preferences", h.GetNotificationPreferences)
+	protected.PUT("/notifications/preferences", h.SetNotificationPreferences)
+	protected.GET("/stream", h.Stream)
+	protected.GET("/email/settings", h.GetEmailSettings)
+	protected.PUT("/email/settings", h.UpdateEmailSettings)
+	protected.POST("/slack/link", h.CreateSlackLinkCode)
+	protected.GET("/merch/:item/variants", h.GetVariants)
+	protected.GET("/promotions", h.GetActivePromotions)
+	protected.POST("/preorders", h.CreatePreorder)
+	protected.GET("/preorders", h.GetPreorders)
+	protected.POST("/preorders/:id/cancel", h.CancelPreorder)
+	protected.GET("/balance", h.GetBalance)
+	protected.GET("/history", h.GetHistory)
+
+	protected.POST("/paymentRequests", h.CreatePaymentRequest)
+	protected.GET("/paymentRequests", h.GetPaymentRequests)
+	protected.POST("/paymentRequests/:id/accept", h.AcceptPaymentRequest)
+	protected.POST("/paymentRequests/:id/decline", h.DeclinePaymentRequest)
+	protected.POST("/paymentRequests/:id/cancel", h.CancelPaymentRequest)
+
+	protected.POST("/scheduledTransfers", h.CreateScheduledTransfer)
+	protected.GET("/scheduledTransfers", h.GetScheduledTransfers)
+	protected.DELETE("/scheduledTransfers/:id", h.CancelScheduledTransfer)
+	protected.GET("/scheduledTransfers/:id/runs", h.GetScheduledTransferRuns)
+
+	protected.POST("/transactions/:id/reversal", h.OpenReversal)
+	protected.GET("/reversals", h.GetReversals)
+	protected.POST("/reversals/:id/approve", h.ApproveReversal)
+	protected.POST("/reversals/:id/reject", h.RejectReversal)
+
+	protected.POST("/purchases/:id/return", h.CreateReturn)
+	protected.GET("/returns", h.GetReturns)
+	protected.GET("/orders", h.GetOrders)
+	protected.POST("/orders/:id/cancel", h.CancelOrder)
+
+	protected.GET("/team", h.GetTeam)
+	protected.POST("/team/grants", h.GrantTeamBudget)
+
+	admin := protected.Group("/admin")
+	admin.Use(handler.RoleMiddleware(models.RoleAdmin))
+	admin.GET("/users/:username/balance", h.GetUserBalance)
+	admin.POST("/teams", h.CreateTeam)
+	admin.POST("/teams/:id/members", h.AddTeamMembers)
+	admin.POST("/webhooks", h.CreateWebhook)
+	admin.GET("/webhooks", h.GetWebhooks)
+	admin.DELETE("/webhooks/:id", h.DeleteWebhook)
+	admin.GET("/webhooks/deliveries", h.GetWebhookDeliveries)
+	admin.POST("/webhooks/deliveries/:id/retry", h.RetryWebhookDelivery)
+
+	treasury := protected.Group("/treasury")
+	treasury.Use(handler.RoleMiddleware(models.RoleTreasurer))
+	treasury.POST("/grants", h.GrantCoins)
+	treasury.POST("/clawbacks", h.ClawbackCoins)
+	treasury.GET("/adjustments", h.GetAdjustments)
+	treasury.GET("/reversals", h.GetPendingReversals)
+
+	shop := protected.Group("/shop")
+	shop.Use(handler.RoleMiddleware(models.RoleShopManager))
+	shop.GET("/returns", h.GetPendingReturns)
+	shop.POST("/returns/:id/approve", h.ApproveReturn)
+	shop.POST("/returns/:id/reject", h.RejectReturn)
+	shop.GET("/orders", h.GetShopOrders)
+	shop.POST("/orders/:id/status", h.UpdateOrderStatus)
+	shop.POST("/merch", h.CreateItem)
+	shop.PUT("/merch/:item/availability", h.SetAvailability)
+	shop.POST("/merch/:item/cancel", h.CancelDrop)
+	shop.POST("/merch/:item/variants", h.AddVariant)
+	shop.PUT("/variants/:sku/stock", h.SetVariantStock)
+	shop.POST("/promotions", h.CreatePromotion)
+	shop.GET("/promotions", h.GetPromotions)
+	shop.POST("/promotions/:id/end", h.EndPromotion)
+
+	approvals := protected.Group("/approvals")
+	approvals.Use(handler.RoleMiddleware(models.RoleManager, models.RoleTreasurer))
+	approvals.GET("/transfers", h.GetPendingTransfers)
+	approvals.POST("/transfers/:id/approve", h.ApproveTransfer)
+	approvals.POST("/transfers/:id/reject", h.RejectTransfer)
 
 	return router
 }
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetPendingTransfers(c *gin.Context) {
+	const op = "handler.GetPendingTransfers"
+
+	transfers, err := h.svc.Approval().GetPendingTransfers()
+	if err != nil {
+		h.respondError(c, op, "failed to get pending transfers", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+func (h *Handler) ApproveTransfer(c *gin.Context) {
+	h.resolvePendingTransfer(c, "handler.ApproveTransfer", h.svc.Approval().ApproveTransfer)
+}
+
+func (h *Handler) RejectTransfer(c *gin.Context) {
+	h.resolvePendingTransfer(c, "handler.RejectTransfer", h.svc.Approval().RejectTransfer)
+}
+
+func (h *Handler) resolvePendingTransfer(c *gin.Context, op string, resolve func(actorID, transferID int64) error) {
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	transferID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := resolve(actorID, transferID); err != nil {
+		h.respondError(c, op, "failed to resolve pending transfer", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
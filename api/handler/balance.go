@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+)
+
+func (h *Handler) GetBalance(c *gin.Context) {
+	const op = "handler.GetBalance"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	at, ok := h.parseAt(c)
+	if !ok {
+		return
+	}
+
+	balance, err := h.svc.User().GetBalanceAt(userID, at)
+	if err != nil {
+		h.respondError(c, op, "failed to get balance", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+func (h *Handler) GetUserBalance(c *gin.Context) {
+	const op = "handler.GetUserBalance"
+
+	at, ok := h.parseAt(c)
+	if !ok {
+		return
+	}
+
+	balance, err := h.svc.User().GetBalanceAtByUsername(c.Param("username"), at)
+	if err != nil {
+		h.respondError(c, op, "failed to get user balance", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+// parseAt reads the optional RFC 3339 "at" query parameter, defaulting to now.
+func (h *Handler) parseAt(c *gin.Context) (time.Time, bool) {
+	raw := c.Query("at")
+	if raw == "" {
+		return time.Now(), true
+	}
+
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.AppError{
+			Code:    errors.BadRequest,
+			Message: "at must be an RFC 3339 timestamp",
+		})
+		return time.Time{}, false
+	}
+
+	return at, true
+}
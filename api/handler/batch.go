@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) SendCoinBatch(c *gin.Context) {
+	const op = "handler.SendCoinBatch"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.BatchSendCoinRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	response, err := h.svc.Coin().SendBatch(userID, req)
+	if err != nil {
+		var appErr *errors.AppError
+		if len(response.Results) > 0 && errors.As(err, &appErr) {
+			h.log.Error("batch rejected",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			c.JSON(appErr.Code, response)
+			return
+		}
+		h.respondError(c, op, "failed to send coin batch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetEmailSettings(c *gin.Context) {
+	const op = "handler.GetEmailSettings"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	settings, err := h.svc.Email().GetSettings(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get email settings", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) UpdateEmailSettings(c *gin.Context) {
+	const op = "handler.UpdateEmailSettings"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.EmailSettings
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Email().UpdateSettings(userID, req); err != nil {
+		h.respondError(c, op, "failed to update email settings", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) SendGift(c *gin.Context) {
+	const op = "handler.SendGift"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.GiftRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Inventory().Gift(userID, req); err != nil {
+		h.respondError(c, op, "failed to send gift", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) TransferItem(c *gin.Context) {
+	const op = "handler.TransferItem"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.ItemTransferRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Inventory().TransferItem(userID, req); err != nil {
+		h.respondError(c, op, "failed to transfer item", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
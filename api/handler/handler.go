@@ -156,7 +156,7 @@ func (h *Handler) SendCoin(c *gin.Context) {
 		return
 	}
 
-	err := h.svc.Coin().Send(userID.(int64), req)
+	resp, err := h.svc.Coin().Send(userID.(int64), req)
 	if err != nil {
 		h.log.Error("failed to send coins",
 			zap.String("method", op),
@@ -174,6 +174,11 @@ func (h *Handler) SendCoin(c *gin.Context) {
 		return
 	}
 
+	if resp.PendingTransferID != 0 {
+		c.JSON(http.StatusAccepted, resp)
+		return
+	}
+
 	c.Status(http.StatusOK)
 }
 
@@ -204,7 +209,12 @@ func (h *Handler) BuyItem(c *gin.Context) {
 		return
 	}
 
-	err := h.svc.Inventory().BuyItem(userID.(int64), itemName)
+	var query dto.BuyQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	err := h.svc.Inventory().BuyItem(userID.(int64), itemName, query)
 	if err != nil {
 		h.log.Error("failed to buy item",
 			zap.String("method", op),
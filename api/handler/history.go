@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetHistory(c *gin.Context) {
+	const op = "handler.GetHistory"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var query dto.HistoryQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	history, err := h.svc.History().GetHistory(userID, query)
+	if err != nil {
+		h.respondError(c, op, "failed to get history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetLimits(c *gin.Context) {
+	const op = "handler.GetLimits"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	limits, err := h.svc.Coin().GetLimits(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get transfer limits", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) CreateListing(c *gin.Context) {
+	const op = "handler.CreateListing"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.ListingRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	listing, err := h.svc.Marketplace().CreateListing(userID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create listing", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, listing)
+}
+
+func (h *Handler) SearchListings(c *gin.Context) {
+	const op = "handler.SearchListings"
+
+	var query dto.ListingsQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	listings, err := h.svc.Marketplace().Search(query)
+	if err != nil {
+		h.respondError(c, op, "failed to search listings", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listings)
+}
+
+func (h *Handler) GetMyListings(c *gin.Context) {
+	const op = "handler.GetMyListings"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	listings, err := h.svc.Marketplace().MyListings(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get listings", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listings)
+}
+
+func (h *Handler) BuyListing(c *gin.Context) {
+	const op = "handler.BuyListing"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	listingID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Marketplace().Buy(userID, listingID); err != nil {
+		h.respondError(c, op, "failed to buy listing", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) CancelListing(c *gin.Context) {
+	const op = "handler.CancelListing"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	listingID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Marketplace().Cancel(userID, listingID); err != nil {
+		h.respondError(c, op, "failed to cancel listing", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -1,12 +1,19 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/pkg/errors"
 	"github.com/icoder-new/avito-shop/pkg/jwt"
 	"github.com/icoder-new/avito-shop/pkg/logger"
@@ -69,6 +76,7 @@ func AuthMiddleware(log *logger.Logger, manager *jwt.TokenManager) gin.HandlerFu
 
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
 
 		log.Info("user_id:", zap.Int64("user_id", claims.UserID))
 		log.Info("username:", zap.String("username", claims.Username))
@@ -77,6 +85,23 @@ func AuthMiddleware(log *logger.Logger, manager *jwt.TokenManager) gin.HandlerFu
 	}
 }
 
+func RoleMiddleware(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := models.Role(c.GetString("role"))
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, errors.AppError{
+			Code:    errors.ForbiddenError,
+			Message: "Insufficient permissions",
+		})
+	}
+}
+
 func CorsMiddleware(cfg config.CORSSettings) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", strings.Join(cfg.AllowedOrigins, ", "))
@@ -93,3 +118,68 @@ func CorsMiddleware(cfg config.CORSSettings) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+const (
+	// slackSignatureMaxAge bounds the request timestamp to stop replays.
+	slackSignatureMaxAge = 5 * time.Minute
+	// slackMaxBodySize bounds the body read before the signature is checked.
+	// Slash command and interaction payloads are a few kilobytes.
+	slackMaxBodySize = 64 << 10
+)
+
+// SlackSignatureMiddleware verifies the X-Slack-Signature of a request signed
+// with the app's signing secret. Without a secret every request is rejected.
+func SlackSignatureMiddleware(log *logger.Logger, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reject := func(msg string) {
+			log.Error("slack signature rejected:", zap.String("reason", msg))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errors.AppError{
+				Code:    errors.UnauthorizedError,
+				Message: msg,
+			})
+		}
+
+		if secret == "" {
+			reject("Slack integration is not configured")
+			return
+		}
+
+		timestamp := c.GetHeader("X-Slack-Request-Timestamp")
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			reject("Invalid request timestamp")
+			return
+		}
+		if age := time.Since(time.Unix(seconds, 0)); age > slackSignatureMaxAge || age < -slackSignatureMaxAge {
+			reject("Request timestamp is too old")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, slackMaxBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errors.AppError{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: "Request body is too large",
+			})
+			return
+		}
+		if err != nil {
+			reject("Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + timestamp + ":"))
+		mac.Write(body)
+		expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+		if !hmac.Equal([]byte(expected), []byte(c.GetHeader("X-Slack-Signature"))) {
+			reject("Invalid signature")
+			return
+		}
+
+		c.Next()
+	}
+}
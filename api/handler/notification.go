@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetNotifications(c *gin.Context) {
+	const op = "handler.GetNotifications"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var query dto.NotificationsQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	notifications, err := h.svc.Notification().List(userID, query)
+	if err != nil {
+		h.respondError(c, op, "failed to get notifications", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func (h *Handler) MarkNotificationsRead(c *gin.Context) {
+	const op = "handler.MarkNotificationsRead"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.MarkNotificationsReadRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Notification().MarkRead(userID, req); err != nil {
+		h.respondError(c, op, "failed to mark notifications as read", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetNotificationPreferences(c *gin.Context) {
+	const op = "handler.GetNotificationPreferences"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	preferences, err := h.svc.Notification().GetPreferences(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get notification preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+func (h *Handler) SetNotificationPreferences(c *gin.Context) {
+	const op = "handler.SetNotificationPreferences"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.NotificationPreferences
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Notification().SetPreferences(userID, req); err != nil {
+		h.respondError(c, op, "failed to set notification preferences", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
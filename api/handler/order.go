@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetOrders(c *gin.Context) {
+	const op = "handler.GetOrders"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	orders, err := h.svc.Order().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get orders", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *Handler) CancelOrder(c *gin.Context) {
+	const op = "handler.CancelOrder"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	orderID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Order().Cancel(userID, orderID); err != nil {
+		h.respondError(c, op, "failed to cancel order", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetShopOrders(c *gin.Context) {
+	const op = "handler.GetShopOrders"
+
+	var query dto.OrdersQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	orders, err := h.svc.Order().GetOrders(query)
+	if err != nil {
+		h.respondError(c, op, "failed to get orders", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *Handler) UpdateOrderStatus(c *gin.Context) {
+	const op = "handler.UpdateOrderStatus"
+
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	orderID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req dto.OrderStatusRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Order().UpdateStatus(actorID, orderID, req); err != nil {
+		h.respondError(c, op, "failed to update order status", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) CreatePaymentRequest(c *gin.Context) {
+	const op = "handler.CreatePaymentRequest"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.CreatePaymentRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	request, err := h.svc.PaymentRequest().Create(userID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create payment request", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+func (h *Handler) GetPaymentRequests(c *gin.Context) {
+	const op = "handler.GetPaymentRequests"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	requests, err := h.svc.PaymentRequest().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get payment requests", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *Handler) AcceptPaymentRequest(c *gin.Context) {
+	const op = "handler.AcceptPaymentRequest"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	requestID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.PaymentRequest().Accept(userID, requestID)
+	if err != nil {
+		h.respondError(c, op, "failed to accept payment request", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) DeclinePaymentRequest(c *gin.Context) {
+	h.resolvePaymentRequest(c, "handler.DeclinePaymentRequest", h.svc.PaymentRequest().Decline)
+}
+
+func (h *Handler) CancelPaymentRequest(c *gin.Context) {
+	h.resolvePaymentRequest(c, "handler.CancelPaymentRequest", h.svc.PaymentRequest().Cancel)
+}
+
+func (h *Handler) resolvePaymentRequest(c *gin.Context, op string, resolve func(userID, requestID int64) error) {
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	requestID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := resolve(userID, requestID); err != nil {
+		h.respondError(c, op, "failed to resolve payment request", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) CreatePreorder(c *gin.Context) {
+	const op = "handler.CreatePreorder"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.PreorderRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	preorder, err := h.svc.Preorder().Create(userID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create pre-order", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, preorder)
+}
+
+func (h *Handler) GetPreorders(c *gin.Context) {
+	const op = "handler.GetPreorders"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	preorders, err := h.svc.Preorder().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get pre-orders", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preorders)
+}
+
+func (h *Handler) CancelPreorder(c *gin.Context) {
+	const op = "handler.CancelPreorder"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	preorderID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Preorder().Cancel(userID, preorderID); err != nil {
+		h.respondError(c, op, "failed to cancel pre-order", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) CreateItem(c *gin.Context) {
+	const op = "handler.CreateItem"
+
+	var req dto.MerchRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	item, err := h.svc.Inventory().CreateItem(req)
+	if err != nil {
+		h.respondError(c, op, "failed to create item", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func (h *Handler) SetAvailability(c *gin.Context) {
+	const op = "handler.SetAvailability"
+
+	var req dto.AvailabilityRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Inventory().SetAvailability(c.Param("item"), req); err != nil {
+		h.respondError(c, op, "failed to set availability", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) CancelDrop(c *gin.Context) {
+	const op = "handler.CancelDrop"
+
+	if err := h.svc.Preorder().CancelDrop(c.Param("item")); err != nil {
+		h.respondError(c, op, "failed to cancel drop", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
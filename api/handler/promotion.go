@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetActivePromotions(c *gin.Context) {
+	const op = "handler.GetActivePromotions"
+
+	promotions, err := h.svc.Promotion().GetActive()
+	if err != nil {
+		h.respondError(c, op, "failed to get promotions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
+
+func (h *Handler) CreatePromotion(c *gin.Context) {
+	const op = "handler.CreatePromotion"
+
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.PromotionRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	promotion, err := h.svc.Promotion().Create(actorID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create promotion", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, promotion)
+}
+
+func (h *Handler) GetPromotions(c *gin.Context) {
+	const op = "handler.GetPromotions"
+
+	promotions, err := h.svc.Promotion().List()
+	if err != nil {
+		h.respondError(c, op, "failed to get promotions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
+
+func (h *Handler) EndPromotion(c *gin.Context) {
+	const op = "handler.EndPromotion"
+
+	promotionID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Promotion().End(promotionID); err != nil {
+		h.respondError(c, op, "failed to end promotion", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
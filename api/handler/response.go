@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) currentUserID(c *gin.Context, op string) (int64, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.log.Error("user_id not found in context",
+			zap.String("method", op),
+		)
+		c.JSON(http.StatusUnauthorized, errors.AppError{
+			Code:    errors.UnauthorizedError,
+			Message: "Unauthorized",
+		})
+		return 0, false
+	}
+
+	return userID.(int64), true
+}
+
+func (h *Handler) currentRole(c *gin.Context) models.Role {
+	return models.Role(c.GetString("role"))
+}
+
+func (h *Handler) paramID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, errors.AppError{
+			Code:    errors.BadRequest,
+			Message: "Invalid " + name,
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (h *Handler) bindJSON(c *gin.Context, op string, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		h.log.Error("failed to bind request",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, errors.AppError{
+			Code:    errors.BadRequest,
+			Message: "Invalid request body",
+		})
+		return false
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		h.log.Error("validation failed",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, errors.AppError{
+			Code:    errors.ValidationError,
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) bindQuery(c *gin.Context, op string, req interface{}) bool {
+	if err := c.ShouldBindQuery(req); err != nil {
+		h.log.Error("failed to bind query",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, errors.AppError{
+			Code:    errors.BadRequest,
+			Message: "Invalid query parameters",
+		})
+		return false
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		h.log.Error("validation failed",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, errors.AppError{
+			Code:    errors.ValidationError,
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) respondError(c *gin.Context, op, msg string, err error) {
+	h.log.Error(msg,
+		zap.String("method", op),
+		zap.Error(err),
+	)
+	if errors.IsAppError(err) {
+		appErr := err.(*errors.AppError)
+		c.JSON(appErr.Code, appErr)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, errors.AppError{
+		Code:    errors.InternalServerError,
+		Message: "Internal server error",
+	})
+}
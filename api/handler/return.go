@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) CreateReturn(c *gin.Context) {
+	const op = "handler.CreateReturn"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	purchaseID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req dto.ReturnRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	ret, err := h.svc.Return().Create(userID, purchaseID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create return", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+func (h *Handler) GetReturns(c *gin.Context) {
+	const op = "handler.GetReturns"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	returns, err := h.svc.Return().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get returns", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+func (h *Handler) GetPendingReturns(c *gin.Context) {
+	const op = "handler.GetPendingReturns"
+
+	returns, err := h.svc.Return().GetPending()
+	if err != nil {
+		h.respondError(c, op, "failed to get pending returns", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+func (h *Handler) ApproveReturn(c *gin.Context) {
+	h.resolveReturn(c, "handler.ApproveReturn", h.svc.Return().Approve)
+}
+
+func (h *Handler) RejectReturn(c *gin.Context) {
+	h.resolveReturn(c, "handler.RejectReturn", h.svc.Return().Reject)
+}
+
+func (h *Handler) resolveReturn(c *gin.Context, op string, resolve func(actorID, returnID int64) error) {
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	returnID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := resolve(actorID, returnID); err != nil {
+		h.respondError(c, op, "failed to resolve return", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+)
+
+func (h *Handler) OpenReversal(c *gin.Context) {
+	const op = "handler.OpenReversal"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	transactionID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req dto.ReversalRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	reversal, err := h.svc.Reversal().Open(userID, transactionID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to open reversal", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reversal)
+}
+
+func (h *Handler) GetReversals(c *gin.Context) {
+	const op = "handler.GetReversals"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	reversals, err := h.svc.Reversal().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get reversals", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reversals)
+}
+
+func (h *Handler) GetPendingReversals(c *gin.Context) {
+	const op = "handler.GetPendingReversals"
+
+	reversals, err := h.svc.Reversal().GetPending()
+	if err != nil {
+		h.respondError(c, op, "failed to get pending reversals", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reversals)
+}
+
+func (h *Handler) ApproveReversal(c *gin.Context) {
+	h.resolveReversal(c, "handler.ApproveReversal", h.svc.Reversal().Approve)
+}
+
+func (h *Handler) RejectReversal(c *gin.Context) {
+	h.resolveReversal(c, "handler.RejectReversal", h.svc.Reversal().Reject)
+}
+
+func (h *Handler) resolveReversal(
+	c *gin.Context,
+	op string,
+	resolve func(actorID int64, role models.Role, reversalID int64) error,
+) {
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	reversalID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := resolve(actorID, h.currentRole(c), reversalID); err != nil {
+		h.respondError(c, op, "failed to resolve reversal", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
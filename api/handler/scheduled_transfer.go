@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) CreateScheduledTransfer(c *gin.Context) {
+	const op = "handler.CreateScheduledTransfer"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.CreateScheduledTransfer
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	transfer, err := h.svc.ScheduledTransfer().Create(userID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create scheduled transfer", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+func (h *Handler) GetScheduledTransfers(c *gin.Context) {
+	const op = "handler.GetScheduledTransfers"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	transfers, err := h.svc.ScheduledTransfer().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get scheduled transfers", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+func (h *Handler) CancelScheduledTransfer(c *gin.Context) {
+	const op = "handler.CancelScheduledTransfer"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	transferID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.ScheduledTransfer().Cancel(userID, transferID); err != nil {
+		h.respondError(c, op, "failed to cancel scheduled transfer", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetScheduledTransferRuns(c *gin.Context) {
+	const op = "handler.GetScheduledTransferRuns"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	transferID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	runs, err := h.svc.ScheduledTransfer().GetRuns(userID, transferID)
+	if err != nil {
+		h.respondError(c, op, "failed to get scheduled transfer runs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
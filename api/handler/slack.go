@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) CreateSlackLinkCode(c *gin.Context) {
+	const op = "handler.CreateSlackLinkCode"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	code, err := h.svc.Slack().CreateLinkCode(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to create slack link code", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, code)
+}
+
+// SlackCommand answers a slash command. Slack shows any non-200 response as
+// a generic failure, so rejected input is replied to as a message instead.
+func (h *Handler) SlackCommand(c *gin.Context) {
+	const op = "handler.SlackCommand"
+
+	var cmd dto.SlackCommand
+	if err := c.ShouldBind(&cmd); err != nil || h.validator.Validate(&cmd) != nil {
+		h.log.Error("invalid slack command",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusOK, dto.SlackResponse{
+			ResponseType: "ephemeral",
+			Text:         "Could not read the command.",
+		})
+		return
+	}
+
+	resp, err := h.svc.Slack().HandleCommand(cmd)
+	if err != nil {
+		h.log.Error("failed to handle slack command",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusOK, dto.SlackResponse{
+			ResponseType: "ephemeral",
+			Text:         "Something went wrong, please try again later.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetTeam(c *gin.Context) {
+	const op = "handler.GetTeam"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	team, err := h.svc.Team().GetUserTeam(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get team", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+func (h *Handler) GrantTeamBudget(c *gin.Context) {
+	const op = "handler.GrantTeamBudget"
+
+	leadID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.TeamGrantRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Team().Grant(leadID, req); err != nil {
+		h.respondError(c, op, "failed to grant team budget", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) CreateTeam(c *gin.Context) {
+	const op = "handler.CreateTeam"
+
+	var req dto.CreateTeamRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	team, err := h.svc.Team().Create(req)
+	if err != nil {
+		h.respondError(c, op, "failed to create team", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+func (h *Handler) AddTeamMembers(c *gin.Context) {
+	const op = "handler.AddTeamMembers"
+
+	teamID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req dto.TeamMembersRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	team, err := h.svc.Team().AddMembers(teamID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to add team members", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
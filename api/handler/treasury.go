@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GrantCoins(c *gin.Context) {
+	const op = "handler.GrantCoins"
+
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.GrantRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	grants, err := h.svc.Treasury().Grant(actorID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to grant coins", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+func (h *Handler) ClawbackCoins(c *gin.Context) {
+	const op = "handler.ClawbackCoins"
+
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.ClawbackRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	clawback, err := h.svc.Treasury().Clawback(actorID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to claw back coins", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clawback)
+}
+
+func (h *Handler) GetAdjustments(c *gin.Context) {
+	const op = "handler.GetAdjustments"
+
+	var query dto.AdjustmentsQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	adjustments, err := h.svc.Treasury().GetAdjustments(query)
+	if err != nil {
+		h.respondError(c, op, "failed to get adjustments", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustments)
+}
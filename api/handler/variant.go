@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetVariants(c *gin.Context) {
+	const op = "handler.GetVariants"
+
+	variants, err := h.svc.Inventory().GetVariants(c.Param("item"))
+	if err != nil {
+		h.respondError(c, op, "failed to get variants", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, variants)
+}
+
+func (h *Handler) AddVariant(c *gin.Context) {
+	const op = "handler.AddVariant"
+
+	var req dto.VariantRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	variant, err := h.svc.Inventory().AddVariant(c.Param("item"), req)
+	if err != nil {
+		h.respondError(c, op, "failed to add variant", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, variant)
+}
+
+func (h *Handler) SetVariantStock(c *gin.Context) {
+	const op = "handler.SetVariantStock"
+
+	var req dto.VariantStockRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	if err := h.svc.Inventory().SetVariantStock(c.Param("sku"), req); err != nil {
+		h.respondError(c, op, "failed to set variant stock", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
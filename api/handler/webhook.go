@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	const op = "handler.CreateWebhook"
+
+	actorID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.WebhookRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	webhook, err := h.svc.Webhook().Create(actorID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to create webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	const op = "handler.GetWebhooks"
+
+	webhooks, err := h.svc.Webhook().List()
+	if err != nil {
+		h.respondError(c, op, "failed to get webhooks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	const op = "handler.DeleteWebhook"
+
+	webhookID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Webhook().Delete(webhookID); err != nil {
+		h.respondError(c, op, "failed to delete webhook", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	const op = "handler.GetWebhookDeliveries"
+
+	var query dto.WebhookDeliveriesQuery
+	if !h.bindQuery(c, op, &query) {
+		return
+	}
+
+	deliveries, err := h.svc.Webhook().GetDeliveries(query)
+	if err != nil {
+		h.respondError(c, op, "failed to get webhook deliveries", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (h *Handler) RetryWebhookDelivery(c *gin.Context) {
+	const op = "handler.RetryWebhookDelivery"
+
+	deliveryID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Webhook().Retry(deliveryID); err != nil {
+		h.respondError(c, op, "failed to retry webhook delivery", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
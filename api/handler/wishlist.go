@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/icoder-new/avito-shop/internal/dto"
+)
+
+func (h *Handler) GetWishlist(c *gin.Context) {
+	const op = "handler.GetWishlist"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.Wishlist().List(userID)
+	if err != nil {
+		h.respondError(c, op, "failed to get wishlist", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *Handler) AddToWishlist(c *gin.Context) {
+	const op = "handler.AddToWishlist"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	var req dto.WishlistRequest
+	if !h.bindJSON(c, op, &req) {
+		return
+	}
+
+	item, err := h.svc.Wishlist().Add(userID, req)
+	if err != nil {
+		h.respondError(c, op, "failed to add to wishlist", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func (h *Handler) RemoveFromWishlist(c *gin.Context) {
+	const op = "handler.RemoveFromWishlist"
+
+	userID, ok := h.currentUserID(c, op)
+	if !ok {
+		return
+	}
+
+	itemID, ok := h.paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Wishlist().Remove(userID, itemID); err != nil {
+		h.respondError(c, op, "failed to remove from wishlist", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -6,10 +6,14 @@ import (
 	"github.com/icoder-new/avito-shop/api"
 	"github.com/icoder-new/avito-shop/api/handler"
 	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/outbox"
 	"github.com/icoder-new/avito-shop/internal/service"
 	"github.com/icoder-new/avito-shop/internal/storage/postgres"
+	"github.com/icoder-new/avito-shop/internal/worker"
 	"github.com/icoder-new/avito-shop/pkg/jwt"
 	"github.com/icoder-new/avito-shop/pkg/logger"
+	"github.com/icoder-new/avito-shop/pkg/pubsub"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
@@ -40,10 +44,72 @@ func main() {
 
 	manager, err := jwt.NewTokenManager(cfg.Credentials.JWT)
 
-	services := service.NewService(cfg, log, storage, manager)
+	broker := pubsub.NewBroker(log, storage.Events())
+	go broker.Run(ctx)
+
+	sender := email.NewSMTPSender(cfg.Settings.Email, cfg.Credentials.SMTP)
+
+	services := service.NewService(cfg, log, storage, manager, broker, sender)
 	handlers := handler.NewHandler(cfg, log, services, manager)
 	router := api.SetUpRoutes(handlers, log)
 
+	go worker.New(log, "scheduled-transfers",
+		cfg.Settings.Worker.ScheduledTransfersInterval,
+		services.ScheduledTransfer().RunDue,
+	).Run(ctx)
+
+	go worker.New(log, "coin-expiry",
+		cfg.Settings.Worker.CoinExpiryInterval,
+		services.Expiry().ExpireDue,
+	).Run(ctx)
+
+	go worker.New(log, "balance-snapshots",
+		cfg.Settings.Worker.BalanceSnapshotInterval,
+		services.Snapshot().TakeDue,
+	).Run(ctx)
+
+	go worker.New(log, "monthly-allowance",
+		cfg.Settings.Worker.AllowanceInterval,
+		services.Allowance().TopUp,
+	).Run(ctx)
+
+	go worker.New(log, "payment-requests",
+		cfg.Settings.Worker.PaymentRequestInterval,
+		services.PaymentRequest().ExpireDue,
+	).Run(ctx)
+
+	go worker.New(log, "preorders",
+		cfg.Settings.Worker.PreorderInterval,
+		services.Preorder().FulfilDue,
+	).Run(ctx)
+
+	go worker.New(log, "wishlist",
+		cfg.Settings.Worker.WishlistInterval,
+		services.Wishlist().Evaluate,
+	).Run(ctx)
+
+	sinks, err := outbox.NewSinks(cfg.Settings.Outbox, log, storage)
+	if err != nil {
+		log.Fatal("failed to initialize outbox sinks", zap.Error(err))
+	}
+
+	go worker.New(log, "outbox",
+		cfg.Settings.Worker.OutboxInterval,
+		outbox.NewRelay(log, storage.Outbox(), sinks...).Run,
+	).Run(ctx)
+
+	go worker.New(log, "webhooks",
+		cfg.Settings.Worker.WebhooksInterval,
+		services.Webhook().Deliver,
+	).Run(ctx)
+
+	if cfg.Settings.Email.Enabled {
+		go worker.New(log, "emails",
+			cfg.Settings.Worker.EmailInterval,
+			services.Email().SendQueued,
+		).Run(ctx)
+	}
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Settings.App.Port),
 		Handler:      router,
@@ -22,11 +22,16 @@ type (
 		Logger  LoggerSettings  `mapstructure:"logger"`
 		CORS    CORSSettings    `mapstructure:"cors"`
 		Service ServiceSettings `mapstructure:"service"`
+		Worker  WorkerSettings  `mapstructure:"worker"`
+		Outbox  OutboxSettings  `mapstructure:"outbox"`
+		Email   EmailSettings   `mapstructure:"email"`
 	}
 
 	Credentials struct {
-		DB  DBCredentials
-		JWT JWTCredentials
+		DB    DBCredentials
+		JWT   JWTCredentials
+		SMTP  SMTPCredentials
+		Slack SlackCredentials
 	}
 
 	AppSettings struct {
@@ -63,7 +68,79 @@ type (
 	}
 
 	ServiceSettings struct {
-		InitialCoins int `mapstructure:"initial_coins"`
+		InitialCoins      int                    `mapstructure:"initial_coins"`
+		PaymentRequestTTL time.Duration          `mapstructure:"payment_request_ttl"`
+		TransferLimits    TransferLimitsSettings `mapstructure:"transfer_limits"`
+		// ApprovalThreshold is the amount above which a transfer waits for a
+		// manager or treasurer. Zero disables approvals.
+		ApprovalThreshold int64 `mapstructure:"transfer_approval_threshold"`
+		// CoinExpiryWarning is how far ahead /api/info reports expiring coins.
+		CoinExpiryWarning time.Duration `mapstructure:"coin_expiry_warning"`
+		// MonthlyAllowance is the giftable balance every user gets each month.
+		MonthlyAllowance int64 `mapstructure:"monthly_allowance"`
+		// ReturnWindow is how long after a purchase the item can be returned.
+		ReturnWindow           time.Duration `mapstructure:"return_window"`
+		ReturnRequiresApproval bool          `mapstructure:"return_requires_approval"`
+		// MarketplaceFeePercent is the share of a marketplace sale that goes
+		// to the system account. Zero disables the fee.
+		MarketplaceFeePercent int64           `mapstructure:"marketplace_fee_percent"`
+		Webhooks              WebhookSettings `mapstructure:"webhooks"`
+		// SlackLinkCodeTTL is how long a code for linking a Slack account
+		// stays valid.
+		SlackLinkCodeTTL time.Duration `mapstructure:"slack_link_code_ttl"`
+	}
+
+	// WebhookSettings control delivery: a failed attempt is retried after
+	// RetryBase, doubling up to RetryMax, until MaxAttempts is reached.
+	WebhookSettings struct {
+		Timeout     time.Duration `mapstructure:"timeout"`
+		MaxAttempts int           `mapstructure:"max_attempts"`
+		RetryBase   time.Duration `mapstructure:"retry_base"`
+		RetryMax    time.Duration `mapstructure:"retry_max"`
+	}
+
+	// OutboxSettings choose where committed domain events are relayed: any
+	// of log, http and webhooks.
+	OutboxSettings struct {
+		Sinks       []string      `mapstructure:"sinks"`
+		HTTPURL     string        `mapstructure:"http_url"`
+		HTTPTimeout time.Duration `mapstructure:"http_timeout"`
+	}
+
+	// EmailSettings configure the SMTP server. A failed email is retried
+	// after RetryDelay until MaxAttempts is reached.
+	EmailSettings struct {
+		Enabled     bool          `mapstructure:"enabled"`
+		Host        string        `mapstructure:"host"`
+		Port        int           `mapstructure:"port"`
+		From        string        `mapstructure:"from"`
+		Timeout     time.Duration `mapstructure:"timeout"`
+		MaxAttempts int           `mapstructure:"max_attempts"`
+		RetryDelay  time.Duration `mapstructure:"retry_delay"`
+	}
+
+	TransferLimitsSettings struct {
+		Default TransferLimitSettings            `mapstructure:"default"`
+		Roles   map[string]TransferLimitSettings `mapstructure:"roles"`
+	}
+
+	TransferLimitSettings struct {
+		PerTransfer int64 `mapstructure:"per_transfer"`
+		Daily       int64 `mapstructure:"daily"`
+		Monthly     int64 `mapstructure:"monthly"`
+	}
+
+	WorkerSettings struct {
+		ScheduledTransfersInterval time.Duration `mapstructure:"scheduled_transfers_interval"`
+		CoinExpiryInterval         time.Duration `mapstructure:"coin_expiry_interval"`
+		BalanceSnapshotInterval    time.Duration `mapstructure:"balance_snapshot_interval"`
+		AllowanceInterval          time.Duration `mapstructure:"allowance_interval"`
+		PaymentRequestInterval     time.Duration `mapstructure:"payment_request_interval"`
+		PreorderInterval           time.Duration `mapstructure:"preorder_interval"`
+		WishlistInterval           time.Duration `mapstructure:"wishlist_interval"`
+		WebhooksInterval           time.Duration `mapstructure:"webhooks_interval"`
+		OutboxInterval             time.Duration `mapstructure:"outbox_interval"`
+		EmailInterval              time.Duration `mapstructure:"email_interval"`
 	}
 
 	DBCredentials struct {
@@ -79,6 +156,15 @@ type (
 		SecretKey string
 		ExpiresIn time.Duration
 	}
+
+	SMTPCredentials struct {
+		Username string
+		Password string
+	}
+
+	SlackCredentials struct {
+		SigningSecret string
+	}
 )
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -125,6 +211,15 @@ func (c *Config) loadCredentials() error {
 		ExpiresIn: time.Hour * 24, // default value
 	}
 
+	c.Credentials.SMTP = SMTPCredentials{
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+
+	c.Credentials.Slack = SlackCredentials{
+		SigningSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+	}
+
 	if envExpiresIn := os.Getenv("JWT_EXPIRES_IN"); envExpiresIn != "" {
 		duration, err := time.ParseDuration(envExpiresIn)
 		if err != nil {
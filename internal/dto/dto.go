@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type AuthRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Password string `json:"password" validate:"required,min=6,max=50"`
@@ -10,18 +15,64 @@ type AuthResponse struct {
 }
 
 type SendCoinRequest struct {
-	ToUser string `json:"toUser" validate:"required"`
-	Amount int64  `json:"amount" validate:"required,gt=0"`
+	ToUser   string `json:"toUser" validate:"required"`
+	Amount   int64  `json:"amount" validate:"required,gt=0"`
+	Memo     string `json:"memo,omitempty" validate:"max=140"`
+	Category string `json:"category,omitempty" validate:"omitempty,oneof=thanks lunch gift help other"`
+}
+
+// SendCoinResponse reports whether a transfer, or the payment of a payment
+// request, executed immediately or is held for approval.
+type SendCoinResponse struct {
+	Status            string `json:"status"`
+	PendingTransferID int64  `json:"pendingTransferId,omitempty"`
+}
+
+type BatchSendCoinRequest struct {
+	Transfers []BatchTransfer `json:"transfers" validate:"required,min=1,max=100,dive"`
+}
+
+type BatchTransfer struct {
+	ToUser   string `json:"toUser" validate:"required"`
+	Amount   int64  `json:"amount" validate:"required,gt=0"`
+	Memo     string `json:"memo,omitempty" validate:"max=140"`
+	Category string `json:"category,omitempty" validate:"omitempty,oneof=thanks lunch gift help other"`
+}
+
+type BatchSendCoinResponse struct {
+	Status  string                `json:"status"`
+	Message string                `json:"message,omitempty"`
+	Results []BatchTransferResult `json:"results"`
+}
+
+type BatchTransferResult struct {
+	ToUser        string `json:"toUser"`
+	Amount        int64  `json:"amount"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	TransactionID int64  `json:"transactionId,omitempty"`
 }
 
 type UserInfo struct {
-	Coins       int64           `json:"coins"`
-	Inventory   []InventoryItem `json:"inventory"`
-	CoinHistory CoinHistory     `json:"coinHistory"`
+	Coins         int64           `json:"coins"`
+	GiftableCoins int64           `json:"giftableCoins"`
+	PendingCoins  int64           `json:"pendingCoins"`
+	PreorderCoins int64           `json:"preorderCoins"`
+	ExpiringSoon  []ExpiringCoins `json:"expiringSoon"`
+	Inventory     []InventoryItem `json:"inventory"`
+	CoinHistory   CoinHistory     `json:"coinHistory"`
+}
+
+type ExpiringCoins struct {
+	Amount    int64     `json:"amount"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 type InventoryItem struct {
 	Type     string `json:"type"`
+	SKU      string `json:"sku,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
 	Quantity int64  `json:"quantity"`
 }
 
@@ -31,11 +82,508 @@ type CoinHistory struct {
 }
 
 type CoinTransfer struct {
-	FromUser string `json:"fromUser,omitempty"`
-	ToUser   string `json:"toUser,omitempty"`
-	Amount   int64  `json:"amount"`
+	FromUser   string `json:"fromUser,omitempty"`
+	ToUser     string `json:"toUser,omitempty"`
+	Amount     int64  `json:"amount"`
+	Memo       string `json:"memo,omitempty"`
+	Category   string `json:"category,omitempty"`
+	ReversalOf *int64 `json:"reversalOf,omitempty"`
+}
+
+type HistoryQuery struct {
+	Query    string `form:"q" validate:"max=140"`
+	Type     string `form:"type" validate:"omitempty,oneof=transfer purchase grant clawback reversal expiry allowance team_budget refund gift item_transfer marketplace_sale marketplace_fee"`
+	Category string `form:"category" validate:"omitempty,oneof=thanks lunch gift help other"`
+	Limit    int    `form:"limit" validate:"min=0,max=100"`
+	Offset   int    `form:"offset" validate:"min=0"`
+}
+
+type HistoryEntry struct {
+	ID             int64     `json:"id"`
+	Type           string    `json:"type"`
+	FromUser       string    `json:"fromUser,omitempty"`
+	ToUser         string    `json:"toUser,omitempty"`
+	Item           string    `json:"item,omitempty"`
+	Amount         int64     `json:"amount"`
+	Memo           string    `json:"memo,omitempty"`
+	Category       string    `json:"category,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	ReversalOf     *int64    `json:"reversalOf,omitempty"`
+	RefundOf       *int64    `json:"refundOf,omitempty"`
+	OriginalAmount *int64    `json:"originalAmount,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type HistoryResponse struct {
+	Transactions []HistoryEntry `json:"transactions"`
+}
+
+type BalanceResponse struct {
+	Username string    `json:"username"`
+	Coins    int64     `json:"coins"`
+	At       time.Time `json:"at"`
+}
+
+// LimitsResponse describes the caller's transfer limits. A null limit means
+// the limit is disabled for the caller's role.
+type LimitsResponse struct {
+	PerTransfer *int64      `json:"perTransfer"`
+	Daily       LimitStatus `json:"daily"`
+	Monthly     LimitStatus `json:"monthly"`
+}
+
+type LimitStatus struct {
+	Limit     *int64 `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining *int64 `json:"remaining"`
+}
+
+type CreatePaymentRequest struct {
+	FromUser string `json:"fromUser" validate:"required"`
+	Amount   int64  `json:"amount" validate:"required,gt=0"`
+	Memo     string `json:"memo,omitempty" validate:"max=140"`
+}
+
+type PaymentRequest struct {
+	ID        int64     `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int64     `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type PaymentRequests struct {
+	Incoming []PaymentRequest `json:"incoming"`
+	Outgoing []PaymentRequest `json:"outgoing"`
+}
+
+type CreateScheduledTransfer struct {
+	ToUser   string     `json:"toUser" validate:"required"`
+	Amount   int64      `json:"amount" validate:"required,gt=0"`
+	Memo     string     `json:"memo,omitempty" validate:"max=140"`
+	Category string     `json:"category,omitempty" validate:"omitempty,oneof=thanks lunch gift help other"`
+	RunAt    *time.Time `json:"runAt,omitempty"`
+	Schedule string     `json:"schedule,omitempty" validate:"max=100"`
+}
+
+type ScheduledTransfer struct {
+	ID        int64      `json:"id"`
+	ToUser    string     `json:"toUser"`
+	Amount    int64      `json:"amount"`
+	Memo      string     `json:"memo,omitempty"`
+	Category  string     `json:"category,omitempty"`
+	Schedule  string     `json:"schedule,omitempty"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type ScheduledTransferRun struct {
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	ScheduledFor time.Time `json:"scheduledFor"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type GrantRequest struct {
+	Usernames []string `json:"usernames" validate:"required,min=1,max=100,dive,required"`
+	Amount    int64    `json:"amount" validate:"required,gt=0"`
+	Reason    string   `json:"reason" validate:"required,max=255"`
+}
+
+type ClawbackRequest struct {
+	Username string `json:"username" validate:"required"`
+	Amount   int64  `json:"amount" validate:"required,gt=0"`
+	Reason   string `json:"reason" validate:"required,max=255"`
+}
+
+type Adjustment struct {
+	TransactionID int64     `json:"transactionId"`
+	Type          string    `json:"type"`
+	Username      string    `json:"username"`
+	Amount        int64     `json:"amount"`
+	Reason        string    `json:"reason"`
+	CreatedBy     string    `json:"createdBy,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type AdjustmentsQuery struct {
+	Limit  int `form:"limit" validate:"min=0,max=100"`
+	Offset int `form:"offset" validate:"min=0"`
+}
+
+type ReversalRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+type Reversal struct {
+	ID                    int64      `json:"id"`
+	TransactionID         int64      `json:"transactionId"`
+	FromUser              string     `json:"fromUser"`
+	ToUser                string     `json:"toUser"`
+	Amount                int64      `json:"amount"`
+	Reason                string     `json:"reason"`
+	Status                string     `json:"status"`
+	ReversalTransactionID *int64     `json:"reversalTransactionId,omitempty"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	ResolvedAt            *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type Reversals struct {
+	Opened   []Reversal `json:"opened"`
+	Received []Reversal `json:"received"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
+
+type PendingTransfer struct {
+	ID        int64     `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int64     `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	Category  string    `json:"category,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateTeamRequest struct {
+	Name          string `json:"name" validate:"required,max=100"`
+	Lead          string `json:"lead" validate:"required"`
+	MonthlyBudget int64  `json:"monthlyBudget" validate:"gte=0"`
+}
+
+type TeamMembersRequest struct {
+	Usernames []string `json:"usernames" validate:"required,min=1,max=100,dive,required"`
+}
+
+type TeamGrantRequest struct {
+	ToUser string `json:"toUser" validate:"required"`
+	Amount int64  `json:"amount" validate:"required,gt=0"`
+	Memo   string `json:"memo,omitempty" validate:"max=140"`
+}
+
+type Team struct {
+	ID            int64    `json:"id"`
+	Name          string   `json:"name"`
+	Lead          string   `json:"lead,omitempty"`
+	Budget        int64    `json:"budget"`
+	MonthlyBudget int64    `json:"monthlyBudget"`
+	Members       []string `json:"members"`
+}
+
+type ReturnRequest struct {
+	Reason string `json:"reason,omitempty" validate:"max=255"`
+}
+
+type Return struct {
+	ID                  int64      `json:"id"`
+	PurchaseID          int64      `json:"purchaseId"`
+	User                string     `json:"user"`
+	Item                string     `json:"item"`
+	SKU                 string     `json:"sku,omitempty"`
+	Amount              int64      `json:"amount"`
+	Reason              string     `json:"reason,omitempty"`
+	Status              string     `json:"status"`
+	RefundTransactionID *int64     `json:"refundTransactionId,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	ResolvedAt          *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type Order struct {
+	ID                  int64     `json:"id"`
+	PurchaseID          int64     `json:"purchaseId"`
+	User                string    `json:"user"`
+	Item                string    `json:"item"`
+	SKU                 string    `json:"sku,omitempty"`
+	Size                string    `json:"size,omitempty"`
+	Color               string    `json:"color,omitempty"`
+	Amount              int64     `json:"amount"`
+	Status              string    `json:"status"`
+	RefundTransactionID *int64    `json:"refundTransactionId,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+	UpdatedAt           time.Time `json:"updatedAt"`
+}
+
+type OrdersQuery struct {
+	Status string `form:"status" validate:"omitempty,oneof=placed ready_for_pickup handed_over cancelled"`
+	Limit  int    `form:"limit" validate:"min=0,max=100"`
+	Offset int    `form:"offset" validate:"min=0"`
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=ready_for_pickup handed_over cancelled"`
+}
+
+type Variant struct {
+	Item  string `json:"item"`
+	SKU   string `json:"sku"`
+	Size  string `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+	Stock *int64 `json:"stock"`
+}
+
+type VariantRequest struct {
+	SKU   string `json:"sku" validate:"required,max=50"`
+	Size  string `json:"size" validate:"max=20"`
+	Color string `json:"color" validate:"max=30"`
+	Stock *int64 `json:"stock" validate:"omitempty,min=0"`
+}
+
+type VariantStockRequest struct {
+	Stock *int64 `json:"stock" validate:"omitempty,min=0"`
+}
+
+type BuyQuery struct {
+	SKU       string `form:"sku" validate:"max=50"`
+	PromoCode string `form:"promo" validate:"max=50"`
+}
+
+type Promotion struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Code         string     `json:"code,omitempty"`
+	Kind         string     `json:"kind"`
+	Value        int64      `json:"value"`
+	Item         string     `json:"item,omitempty"`
+	Category     string     `json:"category,omitempty"`
+	StartsAt     time.Time  `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt,omitempty"`
+	PerUserLimit *int64     `json:"perUserLimit,omitempty"`
+	MaxUses      *int64     `json:"maxUses,omitempty"`
+	Uses         int64      `json:"uses"`
+}
+
+type PromotionRequest struct {
+	Name         string     `json:"name" validate:"required,max=100"`
+	Code         string     `json:"code" validate:"omitempty,alphanum,max=50"`
+	Kind         string     `json:"kind" validate:"required,oneof=percent fixed"`
+	Value        int64      `json:"value" validate:"required,min=1"`
+	Item         string     `json:"item" validate:"max=50"`
+	Category     string     `json:"category" validate:"omitempty,oneof=apparel accessories stationery"`
+	StartsAt     *time.Time `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt"`
+	PerUserLimit *int64     `json:"perUserLimit" validate:"omitempty,min=1"`
+	MaxUses      *int64     `json:"maxUses" validate:"omitempty,min=1"`
+}
+
+type Merch struct {
+	Name           string     `json:"name"`
+	Price          int64      `json:"price"`
+	Stock          *int64     `json:"stock"`
+	Category       string     `json:"category,omitempty"`
+	AvailableFrom  *time.Time `json:"availableFrom,omitempty"`
+	AvailableUntil *time.Time `json:"availableUntil,omitempty"`
+	PerUserLimit   *int64     `json:"perUserLimit,omitempty"`
+	PreordersOpen  bool       `json:"preordersOpen"`
+}
+
+type AvailabilityRequest struct {
+	AvailableFrom  *time.Time `json:"availableFrom"`
+	AvailableUntil *time.Time `json:"availableUntil"`
+	PerUserLimit   *int64     `json:"perUserLimit" validate:"omitempty,min=1"`
+	PreordersOpen  bool       `json:"preordersOpen"`
+}
+
+type MerchRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	Price    int64  `json:"price" validate:"required,min=1"`
+	Stock    *int64 `json:"stock" validate:"omitempty,min=0"`
+	Category string `json:"category" validate:"omitempty,oneof=apparel accessories stationery"`
+	AvailabilityRequest
+}
+
+type PreorderRequest struct {
+	Item string `json:"item" validate:"required,max=50"`
+	SKU  string `json:"sku" validate:"max=50"`
+}
+
+type Preorder struct {
+	ID            int64      `json:"id"`
+	Item          string     `json:"item"`
+	SKU           string     `json:"sku,omitempty"`
+	Amount        int64      `json:"amount"`
+	Status        string     `json:"status"`
+	PurchaseID    *int64     `json:"purchaseId,omitempty"`
+	AvailableFrom *time.Time `json:"availableFrom,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ResolvedAt    *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type GiftRequest struct {
+	ToUser    string `json:"toUser" validate:"required"`
+	Item      string `json:"item" validate:"required,max=50"`
+	SKU       string `json:"sku" validate:"max=50"`
+	PromoCode string `json:"promo" validate:"max=50"`
+	Memo      string `json:"memo" validate:"max=140"`
+}
+
+type ItemTransferRequest struct {
+	ToUser string `json:"toUser" validate:"required"`
+	Item   string `json:"item" validate:"required,max=50"`
+	SKU    string `json:"sku" validate:"max=50"`
+	Memo   string `json:"memo" validate:"max=140"`
+}
+
+type ListingRequest struct {
+	Item  string `json:"item" validate:"required,max=50"`
+	SKU   string `json:"sku" validate:"max=50"`
+	Price int64  `json:"price" validate:"required,gt=0"`
+}
+
+type ListingsQuery struct {
+	Query    string `form:"q" validate:"max=50"`
+	Item     string `form:"item" validate:"max=50"`
+	Category string `form:"category" validate:"max=30"`
+	MinPrice int64  `form:"minPrice" validate:"min=0"`
+	MaxPrice int64  `form:"maxPrice" validate:"min=0"`
+	Limit    int    `form:"limit" validate:"min=0,max=100"`
+	Offset   int    `form:"offset" validate:"min=0"`
+}
+
+type Listing struct {
+	ID        int64      `json:"id"`
+	Seller    string     `json:"seller"`
+	Item      string     `json:"item"`
+	Category  string     `json:"category,omitempty"`
+	SKU       string     `json:"sku,omitempty"`
+	Size      string     `json:"size,omitempty"`
+	Color     string     `json:"color,omitempty"`
+	Price     int64      `json:"price"`
+	Status    string     `json:"status"`
+	Buyer     string     `json:"buyer,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ClosedAt  *time.Time `json:"closedAt,omitempty"`
+}
+
+type WishlistRequest struct {
+	Item string `json:"item" validate:"required,max=50"`
+	SKU  string `json:"sku" validate:"max=50"`
+}
+
+type WishlistItem struct {
+	ID         int64     `json:"id"`
+	Item       string    `json:"item"`
+	SKU        string    `json:"sku,omitempty"`
+	Size       string    `json:"size,omitempty"`
+	Color      string    `json:"color,omitempty"`
+	Price      int64     `json:"price"`
+	InStock    bool      `json:"inStock"`
+	Affordable bool      `json:"affordable"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type NotificationsQuery struct {
+	Unread bool `form:"unread"`
+	Limit  int  `form:"limit" validate:"min=0,max=100"`
+	Offset int  `form:"offset" validate:"min=0"`
+}
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	Type      string     `json:"type"`
+	Message   string     `json:"message"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+type NotificationsResponse struct {
+	UnreadCount   int64          `json:"unreadCount"`
+	Notifications []Notification `json:"notifications"`
+}
+
+type MarkNotificationsReadRequest struct {
+	IDs []int64 `json:"ids" validate:"max=100"`
+	All bool    `json:"all"`
+}
+
+type NotificationPreferences struct {
+	Muted []string `json:"muted" validate:"max=20,dive,oneof=coins_received coins_granted merch_purchased gift_received wishlist_affordable wishlist_price_drop wishlist_back_in_stock"`
+}
+
+type BalanceEvent struct {
+	Coins         int64 `json:"coins"`
+	GiftableCoins int64 `json:"giftableCoins"`
+	PendingCoins  int64 `json:"pendingCoins"`
+	PreorderCoins int64 `json:"preorderCoins"`
+}
+
+type TransferEvent struct {
+	FromUser string `json:"fromUser"`
+	Amount   int64  `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+}
+
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes []string `json:"eventTypes" validate:"required,min=1,dive,oneof=coin.transferred merch.purchased user.created"`
+}
+
+type Webhook struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveriesQuery struct {
+	WebhookID int64  `form:"webhookId" validate:"min=0"`
+	Status    string `form:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Limit     int    `form:"limit" validate:"min=0,max=100"`
+	Offset    int    `form:"offset" validate:"min=0"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhookId"`
+	EventID        int64           `json:"eventId"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	ResponseStatus *int            `json:"responseStatus,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+// WebhookPayload is the body posted to a webhook.
+type WebhookPayload struct {
+	ID        int64           `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type EmailSettings struct {
+	Email  string `json:"email" validate:"omitempty,email,max=255"`
+	OptOut bool   `json:"optOut"`
+}
+
+type SlackLinkCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SlackCommand is the form Slack posts for a slash command.
+type SlackCommand struct {
+	TeamID   string `form:"team_id" validate:"required"`
+	UserID   string `form:"user_id" validate:"required"`
+	UserName string `form:"user_name"`
+	Command  string `form:"command"`
+	Text     string `form:"text"`
+}
+
+// SlackResponse is the reply to a slash command. ResponseType is ephemeral
+// (seen only by the caller) or in_channel.
+type SlackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
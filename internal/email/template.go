@@ -0,0 +1,61 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	TemplateCoinsReceived = "coins_received"
+	TemplateOrderReady    = "order_ready"
+)
+
+// CoinsReceivedData fills TemplateCoinsReceived.
+type CoinsReceivedData struct {
+	Username string
+	From     string
+	Amount   int64
+	Memo     string
+}
+
+// OrderReadyData fills TemplateOrderReady.
+type OrderReadyData struct {
+	Username string
+	OrderID  int64
+	Item     string
+}
+
+// Each template has a name.subject.txt, a name.txt and a name.html file.
+//
+//go:embed templates
+var templates embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templates, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/*.html"))
+)
+
+// Render fills the named template with data. The recipient is left to the
+// caller.
+func Render(name string, data any) (Message, error) {
+	var subject, text, html bytes.Buffer
+
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject.txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
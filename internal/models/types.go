@@ -2,42 +2,669 @@ package models
 
 import "time"
 
+type Role string
+
+const (
+	RoleUser        Role = "user"
+	RoleAdmin       Role = "admin"
+	RoleTreasurer   Role = "treasurer"
+	RoleManager     Role = "manager"
+	RoleShopManager Role = "shop_manager"
+	RoleSystem      Role = "system"
+)
+
 type User struct {
-	ID           int64     `db:"id" json:"id"`
-	Username     string    `db:"username" json:"username"`
-	PasswordHash string    `db:"password_hash" json:"-"`
-	Coins        int64     `db:"coins" json:"coins"`
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+	ID            int64     `db:"id" json:"id"`
+	Username      string    `db:"username" json:"username"`
+	PasswordHash  string    `db:"password_hash" json:"-"`
+	Coins         int64     `db:"coins" json:"coins"`
+	ReservedCoins int64     `db:"reserved_coins" json:"reserved_coins"`
+	PreorderCoins int64     `db:"preorder_coins" json:"preorder_coins"`
+	GiftableCoins int64     `db:"giftable_coins" json:"giftable_coins"`
+	TeamID        *int64    `db:"team_id" json:"team_id,omitempty"`
+	Email         *string   `db:"email" json:"email,omitempty"`
+	EmailOptOut   bool      `db:"email_opt_out" json:"email_opt_out"`
+	Role          Role      `db:"role" json:"role"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
 }
 
+// Merch is a shop item. A nil Stock means the item is never out of stock.
+// Items with an availability window are on sale only within it, and items
+// with PreordersOpen can be pre-ordered before the window opens. A cancelled
+// item cannot be bought at all.
 type Merch struct {
-	ID    int64  `db:"id" json:"id"`
-	Name  string `db:"name" json:"name"`
-	Price int64  `db:"price" json:"price"`
+	ID             int64      `db:"id" json:"id"`
+	Name           string     `db:"name" json:"name"`
+	Price          int64      `db:"price" json:"price"`
+	Stock          *int64     `db:"stock" json:"stock,omitempty"`
+	Category       string     `db:"category" json:"category,omitempty"`
+	AvailableFrom  *time.Time `db:"available_from" json:"available_from,omitempty"`
+	AvailableUntil *time.Time `db:"available_until" json:"available_until,omitempty"`
+	PerUserLimit   *int64     `db:"per_user_limit" json:"per_user_limit,omitempty"`
+	PreordersOpen  bool       `db:"preorders_open" json:"preorders_open"`
+	CancelledAt    *time.Time `db:"cancelled_at" json:"cancelled_at,omitempty"`
+}
+
+// OnSale reports whether the item can be bought at the given time.
+func (m Merch) OnSale(at time.Time) bool {
+	if m.CancelledAt != nil {
+		return false
+	}
+	if m.AvailableFrom != nil && at.Before(*m.AvailableFrom) {
+		return false
+	}
+	return m.AvailableUntil == nil || at.Before(*m.AvailableUntil)
+}
+
+// AcceptsPreorders reports whether the item can be pre-ordered at the given
+// time.
+func (m Merch) AcceptsPreorders(at time.Time) bool {
+	return m.CancelledAt == nil && m.PreordersOpen && m.AvailableFrom != nil && at.Before(*m.AvailableFrom)
+}
+
+// MerchVariant is a size or color of a merch item with its own SKU and stock.
+// A nil Stock means the variant is not limited.
+type MerchVariant struct {
+	ID      int64  `db:"id" json:"id"`
+	MerchID int64  `db:"merch_id" json:"merch_id"`
+	SKU     string `db:"sku" json:"sku"`
+	Size    string `db:"size" json:"size,omitempty"`
+	Color   string `db:"color" json:"color,omitempty"`
+	Stock   *int64 `db:"stock" json:"stock,omitempty"`
 }
 
 type UserInventory struct {
 	ID        int64     `db:"id" json:"id"`
 	UserID    int64     `db:"user_id" json:"user_id"`
 	MerchID   int64     `db:"merch_id" json:"merch_id"`
+	VariantID *int64    `db:"variant_id" json:"variant_id,omitempty"`
 	Quantity  int64     `db:"quantity" json:"quantity"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	MerchName string    `db:"merch_name" json:"merch_name"`
+	SKU       string    `db:"sku" json:"sku,omitempty"`
+	Size      string    `db:"size" json:"size,omitempty"`
+	Color     string    `db:"color" json:"color,omitempty"`
 }
 
 type TransactionType string
 
 const (
-	TransactionTypeTransfer TransactionType = "transfer"
-	TransactionTypePurchase TransactionType = "purchase"
+	TransactionTypeTransfer        TransactionType = "transfer"
+	TransactionTypePurchase        TransactionType = "purchase"
+	TransactionTypeGrant           TransactionType = "grant"
+	TransactionTypeClawback        TransactionType = "clawback"
+	TransactionTypeReversal        TransactionType = "reversal"
+	TransactionTypeExpiry          TransactionType = "expiry"
+	TransactionTypeAllowance       TransactionType = "allowance"
+	TransactionTypeTeamBudget      TransactionType = "team_budget"
+	TransactionTypeRefund          TransactionType = "refund"
+	TransactionTypeGift            TransactionType = "gift"
+	TransactionTypeItemTransfer    TransactionType = "item_transfer"
+	TransactionTypeMarketplaceSale TransactionType = "marketplace_sale"
+	TransactionTypeMarketplaceFee  TransactionType = "marketplace_fee"
+)
+
+type TransferCategory string
+
+const (
+	TransferCategoryThanks TransferCategory = "thanks"
+	TransferCategoryLunch  TransferCategory = "lunch"
+	TransferCategoryGift   TransferCategory = "gift"
+	TransferCategoryHelp   TransferCategory = "help"
+	TransferCategoryOther  TransferCategory = "other"
 )
 
+// Transaction is a ledger entry. GiftableAmount is the part of a peer transfer
+// paid from the sender's giftable allowance rather than from spendable coins.
 type Transaction struct {
-	ID         int64           `db:"id" json:"id"`
-	FromUserID int64           `db:"from_user_id" json:"from_user_id"`
-	ToUserID   int64           `db:"to_user_id" json:"to_user_id,omitempty"`
-	Amount     int64           `db:"amount" json:"amount"`
-	Type       TransactionType `db:"type" json:"type"`
-	MerchID    *int64          `db:"merch_id" json:"merch_id,omitempty"`
-	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
+	ID                    int64            `db:"id" json:"id"`
+	FromUserID            int64            `db:"from_user_id" json:"from_user_id"`
+	ToUserID              int64            `db:"to_user_id" json:"to_user_id,omitempty"`
+	Amount                int64            `db:"amount" json:"amount"`
+	GiftableAmount        int64            `db:"giftable_amount" json:"giftable_amount,omitempty"`
+	Type                  TransactionType  `db:"type" json:"type"`
+	MerchID               *int64           `db:"merch_id" json:"merch_id,omitempty"`
+	VariantID             *int64           `db:"variant_id" json:"variant_id,omitempty"`
+	OriginalAmount        *int64           `db:"original_amount" json:"original_amount,omitempty"`
+	PromotionID           *int64           `db:"promotion_id" json:"promotion_id,omitempty"`
+	Memo                  string           `db:"memo" json:"memo,omitempty"`
+	Category              TransferCategory `db:"category" json:"category,omitempty"`
+	Reason                string           `db:"reason" json:"reason,omitempty"`
+	CreatedBy             *int64           `db:"created_by" json:"created_by,omitempty"`
+	OriginalTransactionID *int64           `db:"original_transaction_id" json:"original_transaction_id,omitempty"`
+	CreatedAt             time.Time        `db:"created_at" json:"created_at"`
+}
+
+// Owner returns the user who receives the item of a purchase: the recipient
+// of a gift, or the buyer.
+func (t Transaction) Owner() int64 {
+	if t.ToUserID != 0 {
+		return t.ToUserID
+	}
+	return t.FromUserID
+}
+
+// TransactionDetails is a transaction joined with the names of the users and
+// the item it refers to, as shown in history views.
+type TransactionDetails struct {
+	Transaction
+	FromUsername      string `db:"from_username" json:"from_username,omitempty"`
+	ToUsername        string `db:"to_username" json:"to_username,omitempty"`
+	MerchName         string `db:"merch_name" json:"merch_name,omitempty"`
+	CreatedByUsername string `db:"created_by_username" json:"created_by_username,omitempty"`
+}
+
+// BalanceSnapshot is a checkpoint of a user's balance. Historical balances are
+// computed from the latest snapshot plus the transactions that follow it.
+type BalanceSnapshot struct {
+	UserID  int64     `db:"user_id" json:"user_id"`
+	Balance int64     `db:"balance" json:"balance"`
+	TakenAt time.Time `db:"taken_at" json:"taken_at"`
+}
+
+// Team groups users under a lead who hands out the team budget. The budget is
+// reset to MonthlyBudget at the start of every month.
+type Team struct {
+	ID            int64      `db:"id" json:"id"`
+	Name          string     `db:"name" json:"name"`
+	LeadID        *int64     `db:"lead_id" json:"lead_id,omitempty"`
+	Budget        int64      `db:"budget" json:"budget"`
+	MonthlyBudget int64      `db:"monthly_budget" json:"monthly_budget"`
+	BudgetPeriod  *time.Time `db:"budget_period" json:"budget_period,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+// CoinLot is a batch of coins received together. Spending consumes the oldest
+// lots first, and whatever remains of a lot is written off once it expires.
+type CoinLot struct {
+	ID         int64     `db:"id" json:"id"`
+	UserID     int64     `db:"user_id" json:"user_id"`
+	Amount     int64     `db:"amount" json:"amount"`
+	Remaining  int64     `db:"remaining" json:"remaining"`
+	ReceivedAt time.Time `db:"received_at" json:"received_at"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// TransferLimits caps how many coins a user may send. Zero disables a limit.
+type TransferLimits struct {
+	PerTransfer int64
+	Daily       int64
+	Monthly     int64
+}
+
+// TransferUsage is how many coins a user has sent today and this month.
+type TransferUsage struct {
+	Daily   int64
+	Monthly int64
+}
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestStatusPending   PaymentRequestStatus = "pending"
+	PaymentRequestStatusAccepted  PaymentRequestStatus = "accepted"
+	PaymentRequestStatusDeclined  PaymentRequestStatus = "declined"
+	PaymentRequestStatusCancelled PaymentRequestStatus = "cancelled"
+	PaymentRequestStatusExpired   PaymentRequestStatus = "expired"
+)
+
+// PaymentRequest is a request for coins. An accepted request has either a
+// TransactionID, or a PendingTransferID when the payment was held for approval.
+type PaymentRequest struct {
+	ID                int64                `db:"id" json:"id"`
+	RequesterID       int64                `db:"requester_id" json:"requester_id"`
+	PayerID           int64                `db:"payer_id" json:"payer_id"`
+	Amount            int64                `db:"amount" json:"amount"`
+	Memo              string               `db:"memo" json:"memo,omitempty"`
+	Status            PaymentRequestStatus `db:"status" json:"status"`
+	TransactionID     *int64               `db:"transaction_id" json:"transaction_id,omitempty"`
+	PendingTransferID *int64               `db:"pending_transfer_id" json:"pending_transfer_id,omitempty"`
+	CreatedAt         time.Time            `db:"created_at" json:"created_at"`
+	ExpiresAt         time.Time            `db:"expires_at" json:"expires_at"`
+	ResolvedAt        *time.Time           `db:"resolved_at" json:"resolved_at,omitempty"`
+}
+
+type PaymentRequestDetails struct {
+	PaymentRequest
+	RequesterUsername string `db:"requester_username" json:"requester_username"`
+	PayerUsername     string `db:"payer_username" json:"payer_username"`
+}
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferStatusActive    ScheduledTransferStatus = "active"
+	ScheduledTransferStatusCompleted ScheduledTransferStatus = "completed"
+	ScheduledTransferStatusCancelled ScheduledTransferStatus = "cancelled"
+)
+
+// ScheduledTransfer is a future transfer. Schedule holds a cron expression for
+// recurring transfers and is empty for one-off ones.
+type ScheduledTransfer struct {
+	ID         int64                   `db:"id" json:"id"`
+	FromUserID int64                   `db:"from_user_id" json:"from_user_id"`
+	ToUserID   int64                   `db:"to_user_id" json:"to_user_id"`
+	Amount     int64                   `db:"amount" json:"amount"`
+	Memo       string                  `db:"memo" json:"memo,omitempty"`
+	Category   TransferCategory        `db:"category" json:"category,omitempty"`
+	Schedule   string                  `db:"schedule" json:"schedule,omitempty"`
+	NextRunAt  *time.Time              `db:"next_run_at" json:"next_run_at,omitempty"`
+	Status     ScheduledTransferStatus `db:"status" json:"status"`
+	CreatedAt  time.Time               `db:"created_at" json:"created_at"`
+}
+
+type ScheduledTransferDetails struct {
+	ScheduledTransfer
+	ToUsername string `db:"to_username" json:"to_username"`
+}
+
+// ScheduledTransferRunStatus is running from the moment a run is claimed
+// until the outcome of its transfer is recorded.
+type ScheduledTransferRunStatus string
+
+const (
+	ScheduledTransferRunStatusRunning         ScheduledTransferRunStatus = "running"
+	ScheduledTransferRunStatusSucceeded       ScheduledTransferRunStatus = "succeeded"
+	ScheduledTransferRunStatusPendingApproval ScheduledTransferRunStatus = "pending_approval"
+	ScheduledTransferRunStatusFailed          ScheduledTransferRunStatus = "failed"
+)
+
+type ScheduledTransferRun struct {
+	ID                  int64                      `db:"id" json:"id"`
+	ScheduledTransferID int64                      `db:"scheduled_transfer_id" json:"scheduled_transfer_id"`
+	Status              ScheduledTransferRunStatus `db:"status" json:"status"`
+	Error               string                     `db:"error" json:"error,omitempty"`
+	ScheduledFor        time.Time                  `db:"scheduled_for" json:"scheduled_for"`
+	CreatedAt           time.Time                  `db:"created_at" json:"created_at"`
+}
+
+type ReversalStatus string
+
+const (
+	ReversalStatusPending  ReversalStatus = "pending"
+	ReversalStatusApproved ReversalStatus = "approved"
+	ReversalStatusRejected ReversalStatus = "rejected"
+)
+
+// TransferReversal is a sender's request to undo a transfer. Approving it posts
+// a compensating reversal transaction; the original is never modified.
+type TransferReversal struct {
+	ID                    int64          `db:"id" json:"id"`
+	TransactionID         int64          `db:"transaction_id" json:"transaction_id"`
+	RequestedBy           int64          `db:"requested_by" json:"requested_by"`
+	Reason                string         `db:"reason" json:"reason"`
+	Status                ReversalStatus `db:"status" json:"status"`
+	ReversalTransactionID *int64         `db:"reversal_transaction_id" json:"reversal_transaction_id,omitempty"`
+	ResolvedBy            *int64         `db:"resolved_by" json:"resolved_by,omitempty"`
+	CreatedAt             time.Time      `db:"created_at" json:"created_at"`
+	ResolvedAt            *time.Time     `db:"resolved_at" json:"resolved_at,omitempty"`
+}
+
+type TransferReversalDetails struct {
+	TransferReversal
+	Amount            int64  `db:"amount" json:"amount"`
+	SenderID          int64  `db:"sender_id" json:"sender_id"`
+	RecipientID       int64  `db:"recipient_id" json:"recipient_id"`
+	SenderUsername    string `db:"sender_username" json:"sender_username"`
+	RecipientUsername string `db:"recipient_username" json:"recipient_username"`
+}
+
+type PendingTransferStatus string
+
+const (
+	PendingTransferStatusPending  PendingTransferStatus = "pending_approval"
+	PendingTransferStatusApproved PendingTransferStatus = "approved"
+	PendingTransferStatusRejected PendingTransferStatus = "rejected"
+)
+
+// PendingTransfer is a transfer above the approval threshold. Its amount stays
+// in the sender's reserved coins until a manager or treasurer resolves it.
+type PendingTransfer struct {
+	ID            int64                 `db:"id" json:"id"`
+	FromUserID    int64                 `db:"from_user_id" json:"from_user_id"`
+	ToUserID      int64                 `db:"to_user_id" json:"to_user_id"`
+	Amount        int64                 `db:"amount" json:"amount"`
+	Memo          string                `db:"memo" json:"memo,omitempty"`
+	Category      TransferCategory      `db:"category" json:"category,omitempty"`
+	Status        PendingTransferStatus `db:"status" json:"status"`
+	TransactionID *int64                `db:"transaction_id" json:"transaction_id,omitempty"`
+	ResolvedBy    *int64                `db:"resolved_by" json:"resolved_by,omitempty"`
+	CreatedAt     time.Time             `db:"created_at" json:"created_at"`
+	ResolvedAt    *time.Time            `db:"resolved_at" json:"resolved_at,omitempty"`
+}
+
+type PendingTransferDetails struct {
+	PendingTransfer
+	FromUsername string `db:"from_username" json:"from_username"`
+	ToUsername   string `db:"to_username" json:"to_username"`
+}
+
+type ReturnStatus string
+
+const (
+	ReturnStatusPending  ReturnStatus = "pending"
+	ReturnStatusApproved ReturnStatus = "approved"
+	ReturnStatusRejected ReturnStatus = "rejected"
+)
+
+// Return is a request to send back a purchased item. Approving it removes the
+// item from the inventory, restocks it and posts a refund transaction linked
+// to the purchase.
+type Return struct {
+	ID                    int64        `db:"id" json:"id"`
+	UserID                int64        `db:"user_id" json:"user_id"`
+	PurchaseTransactionID int64        `db:"purchase_transaction_id" json:"purchase_transaction_id"`
+	MerchID               int64        `db:"merch_id" json:"merch_id"`
+	VariantID             *int64       `db:"variant_id" json:"variant_id,omitempty"`
+	Amount                int64        `db:"amount" json:"amount"`
+	Reason                string       `db:"reason" json:"reason,omitempty"`
+	Status                ReturnStatus `db:"status" json:"status"`
+	RefundTransactionID   *int64       `db:"refund_transaction_id" json:"refund_transaction_id,omitempty"`
+	ResolvedBy            *int64       `db:"resolved_by" json:"resolved_by,omitempty"`
+	CreatedAt             time.Time    `db:"created_at" json:"created_at"`
+	ResolvedAt            *time.Time   `db:"resolved_at" json:"resolved_at,omitempty"`
+}
+
+type ReturnDetails struct {
+	Return
+	Username  string `db:"username" json:"username"`
+	MerchName string `db:"merch_name" json:"merch_name"`
+	SKU       string `db:"sku" json:"sku,omitempty"`
+	Size      string `db:"size" json:"size,omitempty"`
+	Color     string `db:"color" json:"color,omitempty"`
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusPlaced         OrderStatus = "placed"
+	OrderStatusReadyForPickup OrderStatus = "ready_for_pickup"
+	OrderStatusHandedOver     OrderStatus = "handed_over"
+	OrderStatusCancelled      OrderStatus = "cancelled"
+)
+
+// Order tracks the physical fulfilment of a purchase.
+type Order struct {
+	ID                    int64       `db:"id" json:"id"`
+	UserID                int64       `db:"user_id" json:"user_id"`
+	MerchID               int64       `db:"merch_id" json:"merch_id"`
+	VariantID             *int64      `db:"variant_id" json:"variant_id,omitempty"`
+	PurchaseTransactionID int64       `db:"purchase_transaction_id" json:"purchase_transaction_id"`
+	Status                OrderStatus `db:"status" json:"status"`
+	RefundTransactionID   *int64      `db:"refund_transaction_id" json:"refund_transaction_id,omitempty"`
+	CreatedAt             time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt             time.Time   `db:"updated_at" json:"updated_at"`
+}
+
+type OrderDetails struct {
+	Order
+	Amount    int64  `db:"amount" json:"amount"`
+	Username  string `db:"username" json:"username"`
+	MerchName string `db:"merch_name" json:"merch_name"`
+	SKU       string `db:"sku" json:"sku,omitempty"`
+	Size      string `db:"size" json:"size,omitempty"`
+	Color     string `db:"color" json:"color,omitempty"`
+}
+
+type PromotionKind string
+
+const (
+	PromotionKindPercent PromotionKind = "percent"
+	PromotionKindFixed   PromotionKind = "fixed"
+)
+
+// Promotion is a discount rule. Promotions without a code apply to every
+// matching purchase, the ones with a code only when the buyer enters it. A
+// promotion scoped to neither an item nor a category covers the whole shop.
+type Promotion struct {
+	ID           int64         `db:"id" json:"id"`
+	Name         string        `db:"name" json:"name"`
+	Code         string        `db:"code" json:"code,omitempty"`
+	Kind         PromotionKind `db:"kind" json:"kind"`
+	Value        int64         `db:"value" json:"value"`
+	MerchID      *int64        `db:"merch_id" json:"merch_id,omitempty"`
+	Category     string        `db:"category" json:"category,omitempty"`
+	StartsAt     time.Time     `db:"starts_at" json:"starts_at"`
+	EndsAt       *time.Time    `db:"ends_at" json:"ends_at,omitempty"`
+	PerUserLimit *int64        `db:"per_user_limit" json:"per_user_limit,omitempty"`
+	MaxUses      *int64        `db:"max_uses" json:"max_uses,omitempty"`
+	Uses         int64         `db:"uses" json:"uses"`
+	MerchName    string        `db:"merch_name" json:"merch_name,omitempty"`
+	CreatedBy    int64         `db:"created_by" json:"created_by"`
+	CreatedAt    time.Time     `db:"created_at" json:"created_at"`
+}
+
+// Discount returns how many coins the promotion takes off the price. The
+// buyer always pays at least one coin.
+func (p Promotion) Discount(price int64) int64 {
+	discount := p.Value
+	if p.Kind == PromotionKindPercent {
+		discount = price * p.Value / 100
+	}
+	if discount >= price {
+		discount = price - 1
+	}
+	return discount
+}
+
+type PreorderStatus string
+
+const (
+	PreorderStatusReserved  PreorderStatus = "reserved"
+	PreorderStatusFulfilled PreorderStatus = "fulfilled"
+	PreorderStatusCancelled PreorderStatus = "cancelled"
+	PreorderStatusRefunded  PreorderStatus = "refunded"
+)
+
+// Preorder holds coins in reserve for an item that is not on sale yet. It
+// becomes a purchase when the item goes on sale; if the drop is cancelled or
+// sells out first, the coins are returned.
+type Preorder struct {
+	ID                    int64          `db:"id" json:"id"`
+	UserID                int64          `db:"user_id" json:"user_id"`
+	MerchID               int64          `db:"merch_id" json:"merch_id"`
+	VariantID             *int64         `db:"variant_id" json:"variant_id,omitempty"`
+	Amount                int64          `db:"amount" json:"amount"`
+	Status                PreorderStatus `db:"status" json:"status"`
+	PurchaseTransactionID *int64         `db:"purchase_transaction_id" json:"purchase_transaction_id,omitempty"`
+	CreatedAt             time.Time      `db:"created_at" json:"created_at"`
+	ResolvedAt            *time.Time     `db:"resolved_at" json:"resolved_at,omitempty"`
+}
+
+type PreorderDetails struct {
+	Preorder
+	MerchName     string     `db:"merch_name" json:"merch_name"`
+	SKU           string     `db:"sku" json:"sku,omitempty"`
+	AvailableFrom *time.Time `db:"available_from" json:"available_from,omitempty"`
+}
+
+type ListingStatus string
+
+const (
+	ListingStatusActive    ListingStatus = "active"
+	ListingStatusSold      ListingStatus = "sold"
+	ListingStatusCancelled ListingStatus = "cancelled"
+)
+
+// Listing offers one unit of an item from the seller's inventory to other
+// users. The unit is held by the listing until it is sold or cancelled.
+type Listing struct {
+	ID                int64         `db:"id" json:"id"`
+	SellerID          int64         `db:"seller_id" json:"seller_id"`
+	MerchID           int64         `db:"merch_id" json:"merch_id"`
+	VariantID         *int64        `db:"variant_id" json:"variant_id,omitempty"`
+	Price             int64         `db:"price" json:"price"`
+	Status            ListingStatus `db:"status" json:"status"`
+	BuyerID           *int64        `db:"buyer_id" json:"buyer_id,omitempty"`
+	SaleTransactionID *int64        `db:"sale_transaction_id" json:"sale_transaction_id,omitempty"`
+	CreatedAt         time.Time     `db:"created_at" json:"created_at"`
+	ClosedAt          *time.Time    `db:"closed_at" json:"closed_at,omitempty"`
+}
+
+// Fee returns the shop's cut of the sale at the given percentage, rounded
+// down.
+func (l Listing) Fee(percent int64) int64 {
+	return l.Price * percent / 100
+}
+
+type ListingDetails struct {
+	Listing
+	Seller    string `db:"seller" json:"seller"`
+	Buyer     string `db:"buyer" json:"buyer,omitempty"`
+	MerchName string `db:"merch_name" json:"merch_name"`
+	Category  string `db:"category" json:"category,omitempty"`
+	SKU       string `db:"sku" json:"sku,omitempty"`
+	Size      string `db:"size" json:"size,omitempty"`
+	Color     string `db:"color" json:"color,omitempty"`
+}
+
+// WishlistItem is an item a user saves for later. It remembers what the
+// evaluator last saw so that each change is reported once.
+type WishlistItem struct {
+	ID                 int64     `db:"id" json:"id"`
+	UserID             int64     `db:"user_id" json:"user_id"`
+	MerchID            int64     `db:"merch_id" json:"merch_id"`
+	VariantID          *int64    `db:"variant_id" json:"variant_id,omitempty"`
+	LastPrice          int64     `db:"last_price" json:"last_price"`
+	InStock            bool      `db:"in_stock" json:"in_stock"`
+	AffordableNotified bool      `db:"affordable_notified" json:"affordable_notified"`
+	CreatedAt          time.Time `db:"created_at" json:"created_at"`
+}
+
+// WishlistItemDetails is a wishlist item with the current state of the item
+// and of the user's balance. Stock is the variant's stock for items with
+// variants; nil means unlimited.
+type WishlistItemDetails struct {
+	WishlistItem
+	MerchName string `db:"merch_name" json:"merch_name"`
+	SKU       string `db:"sku" json:"sku,omitempty"`
+	Size      string `db:"size" json:"size,omitempty"`
+	Color     string `db:"color" json:"color,omitempty"`
+	Price     int64  `db:"price" json:"price"`
+	Stock     *int64 `db:"stock" json:"stock,omitempty"`
+	Coins     int64  `db:"coins" json:"coins"`
+}
+
+// Available reports whether the item is in stock.
+func (d WishlistItemDetails) Available() bool {
+	return d.Stock == nil || *d.Stock > 0
+}
+
+type NotificationType string
+
+const (
+	NotificationTypeCoinsReceived      NotificationType = "coins_received"
+	NotificationTypeCoinsGranted       NotificationType = "coins_granted"
+	NotificationTypeMerchPurchased     NotificationType = "merch_purchased"
+	NotificationTypeGiftReceived       NotificationType = "gift_received"
+	NotificationTypeWishlistAffordable NotificationType = "wishlist_affordable"
+	NotificationTypeWishlistPriceDrop  NotificationType = "wishlist_price_drop"
+	NotificationTypeWishlistRestocked  NotificationType = "wishlist_back_in_stock"
+)
+
+// Notification is a message for a user. It is stored first and delivered by
+// whichever channel picks it up.
+type Notification struct {
+	ID        int64            `db:"id" json:"id"`
+	UserID    int64            `db:"user_id" json:"user_id"`
+	Type      NotificationType `db:"type" json:"type"`
+	Message   string           `db:"message" json:"message"`
+	MerchID   *int64           `db:"merch_id" json:"merch_id,omitempty"`
+	CreatedAt time.Time        `db:"created_at" json:"created_at"`
+	ReadAt    *time.Time       `db:"read_at" json:"read_at,omitempty"`
+}
+
+// EventType names a domain event. Events are recorded in the outbox in the
+// transaction of the change and relayed to integrations afterwards.
+type EventType string
+
+const (
+	EventCoinTransferred EventType = "coin.transferred"
+	EventMerchPurchased  EventType = "merch.purchased"
+	EventUserCreated     EventType = "user.created"
+)
+
+// OutboxEvent is a committed domain event. Payload is the JSON of the model
+// the event is about.
+type OutboxEvent struct {
+	ID          int64      `db:"id" json:"id"`
+	Type        EventType  `db:"type" json:"type"`
+	Payload     []byte     `db:"payload" json:"payload"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	PublishedAt *time.Time `db:"published_at" json:"published_at,omitempty"`
+}
+
+// Webhook is an endpoint registered by an admin. Events of its types are
+// signed with Secret and posted to URL.
+type Webhook struct {
+	ID         int64     `db:"id" json:"id"`
+	URL        string    `db:"url" json:"url"`
+	Secret     string    `db:"secret" json:"-"`
+	EventTypes []string  `db:"event_types" json:"event_types"`
+	Active     bool      `db:"active" json:"active"`
+	CreatedBy  int64     `db:"created_by" json:"created_by"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one outbox event queued for one webhook, with the type,
+// payload and time of the event. A delivery that runs out of attempts is dead
+// and stays for inspection until retried.
+type WebhookDelivery struct {
+	ID             int64                 `db:"id" json:"id"`
+	WebhookID      int64                 `db:"webhook_id" json:"webhook_id"`
+	EventID        int64                 `db:"event_id" json:"event_id"`
+	EventType      EventType             `db:"event_type" json:"event_type"`
+	Payload        []byte                `db:"payload" json:"payload"`
+	Status         WebhookDeliveryStatus `db:"status" json:"status"`
+	Attempts       int                   `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time             `db:"next_attempt_at" json:"next_attempt_at"`
+	ResponseStatus *int                  `db:"response_status" json:"response_status,omitempty"`
+	LastError      string                `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt      time.Time             `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time            `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveryDetails struct {
+	WebhookDelivery
+	URL    string `db:"url" json:"url"`
+	Secret string `db:"secret" json:"-"`
+}
+
+type EmailStatus string
+
+const (
+	EmailStatusPending EmailStatus = "pending"
+	EmailStatusSent    EmailStatus = "sent"
+	EmailStatusFailed  EmailStatus = "failed"
+)
+
+// QueuedEmail is an email waiting to be rendered from Template with Data and
+// sent to the user.
+type QueuedEmail struct {
+	ID            int64       `db:"id" json:"id"`
+	UserID        int64       `db:"user_id" json:"user_id"`
+	Template      string      `db:"template" json:"template"`
+	Data          []byte      `db:"data" json:"data"`
+	Status        EmailStatus `db:"status" json:"status"`
+	Attempts      int         `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time   `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string      `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt     time.Time   `db:"created_at" json:"created_at"`
+	SentAt        *time.Time  `db:"sent_at" json:"sent_at,omitempty"`
+}
+
+// QueuedEmailDetails is a queued email with the address it goes to. The
+// address is read when the email is sent, so a changed address is used.
+type QueuedEmailDetails struct {
+	QueuedEmail
+	To string `db:"to" json:"to"`
 }
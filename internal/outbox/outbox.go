@@ -0,0 +1,112 @@
+// Package outbox relays domain events recorded in the outbox table to the
+// configured sinks once the changes that produced them are committed.
+package outbox
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const relayBatch = 100
+
+// relayLease hides a claimed batch from other replicas while it is published.
+// It must outlast the sinks, so the HTTP sink timeout is kept below it.
+const relayLease = 5 * time.Minute
+
+const (
+	sinkLog      = "log"
+	sinkHTTP     = "http"
+	sinkWebhooks = "webhooks"
+)
+
+// Sink receives relayed events. Delivery is at least once: when any sink
+// fails, the whole batch is handed to every sink again, so a sink must
+// tolerate events it has already seen, for example by their ID.
+type Sink interface {
+	Name() string
+	Publish(events []models.OutboxEvent) error
+}
+
+type Relay struct {
+	log    *logger.Logger
+	outbox storage.IOutbox
+	sinks  []Sink
+}
+
+func NewRelay(log *logger.Logger, outbox storage.IOutbox, sinks ...Sink) *Relay {
+	return &Relay{
+		log:    log,
+		outbox: outbox,
+		sinks:  sinks,
+	}
+}
+
+// Run relays every unpublished event, batch by batch. A batch that fails stays
+// leased, so it is retried once the lease runs out.
+func (r *Relay) Run() error {
+	for {
+		events, err := r.outbox.ClaimDue(relayBatch, relayLease)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err = r.publish(events); err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if err = r.outbox.MarkPublished(ids); err != nil {
+			return err
+		}
+
+		r.log.Debug("outbox events relayed", zap.Int("count", len(events)))
+		if len(events) < relayBatch {
+			return nil
+		}
+	}
+}
+
+func (r *Relay) publish(events []models.OutboxEvent) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(events); err != nil {
+			return fmt.Errorf("sink %s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// NewSinks builds the sinks named in the settings.
+func NewSinks(cfg config.OutboxSettings, log *logger.Logger, storage storage.IStorage) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case sinkLog:
+			sinks = append(sinks, NewLogSink(log))
+		case sinkHTTP:
+			if cfg.HTTPURL == "" {
+				return nil, fmt.Errorf("outbox sink %q requires http_url", name)
+			}
+			if cfg.HTTPTimeout >= relayLease {
+				return nil, fmt.Errorf("outbox sink %q requires http_timeout below %s", name, relayLease)
+			}
+			sinks = append(sinks, NewHTTPSink(cfg.HTTPURL, cfg.HTTPTimeout))
+		case sinkWebhooks:
+			sinks = append(sinks, NewWebhookSink(storage.Webhook()))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultHTTPTimeout = 10 * time.Second
+
+// message is how an event is encoded for external sinks.
+type message struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// LogSink writes every event to the application log.
+type LogSink struct {
+	log *logger.Logger
+}
+
+func NewLogSink(log *logger.Logger) *LogSink {
+	return &LogSink{log: log}
+}
+
+func (s *LogSink) Name() string {
+	return sinkLog
+}
+
+func (s *LogSink) Publish(events []models.OutboxEvent) error {
+	for _, event := range events {
+		s.log.Info("domain event",
+			zap.Int64("event_id", event.ID),
+			zap.String("type", string(event.Type)),
+			zap.ByteString("payload", event.Payload),
+		)
+	}
+	return nil
+}
+
+// HTTPSink posts each batch as a JSON array to a URL. Any response outside
+// 2xx fails the batch.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	return &HTTPSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSink) Name() string {
+	return sinkHTTP
+}
+
+func (s *HTTPSink) Publish(events []models.OutboxEvent) error {
+	messages := make([]message, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, message{
+			ID:        event.ID,
+			Type:      string(event.Type),
+			CreatedAt: event.CreatedAt,
+			Data:      event.Payload,
+		})
+	}
+
+	body, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MemorySink keeps the events it receives. It is meant for tests.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []models.OutboxEvent
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Name() string {
+	return "memory"
+}
+
+func (s *MemorySink) Publish(events []models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, events...)
+	return nil
+}
+
+// Events returns the events received so far.
+func (s *MemorySink) Events() []models.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.OutboxEvent(nil), s.events...)
+}
+
+// WebhookSink queues each event for the webhooks subscribed to its type.
+// The deliveries are then sent by the webhook worker.
+type WebhookSink struct {
+	webhooks storage.IWebhook
+}
+
+func NewWebhookSink(webhooks storage.IWebhook) *WebhookSink {
+	return &WebhookSink{webhooks: webhooks}
+}
+
+func (s *WebhookSink) Name() string {
+	return sinkWebhooks
+}
+
+func (s *WebhookSink) Publish(events []models.OutboxEvent) error {
+	for _, event := range events {
+		if err := s.webhooks.Enqueue(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
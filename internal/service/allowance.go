@@ -0,0 +1,70 @@
+package service
+
+import (
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IAllowance interface {
+	TopUp() error
+}
+
+type allowance struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newAllowance(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IAllowance {
+	return &allowance{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+// TopUp refills user allowances and team budgets for the current month. It is
+// safe to run often: each user and team is topped up once per month.
+func (a *allowance) TopUp() error {
+	const op = "service.allowance.TopUp"
+
+	now := time.Now().UTC()
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	users, err := a.storage.Allowance().TopUpUsers(period, a.cfg.Settings.Service.MonthlyAllowance)
+	if err != nil {
+		a.log.Error("failed to top up allowances:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	teams, err := a.storage.Allowance().TopUpTeams(period)
+	if err != nil {
+		a.log.Error("failed to top up team budgets:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	a.events.balance(op, users...)
+
+	if len(users) > 0 || teams > 0 {
+		a.log.Info("monthly allowances topped up",
+			zap.String("method", op),
+			zap.Time("period", period),
+			zap.Int("users", len(users)),
+			zap.Int64("teams", teams),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IApproval interface {
+	GetPendingTransfers() ([]dto.PendingTransfer, error)
+	ApproveTransfer(actorID, transferID int64) error
+	RejectTransfer(actorID, transferID int64) error
+}
+
+type approval struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newApproval(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IApproval {
+	return &approval{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+func (a *approval) GetPendingTransfers() ([]dto.PendingTransfer, error) {
+	const op = "service.approval.GetPendingTransfers"
+
+	transfers, err := a.storage.PendingTransfer().GetPending()
+	if err != nil {
+		a.log.Error("failed to get pending transfers:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.PendingTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		response = append(response, dto.PendingTransfer{
+			ID:        transfer.ID,
+			FromUser:  transfer.FromUsername,
+			ToUser:    transfer.ToUsername,
+			Amount:    transfer.Amount,
+			Memo:      transfer.Memo,
+			Category:  string(transfer.Category),
+			CreatedAt: transfer.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (a *approval) ApproveTransfer(actorID, transferID int64) error {
+	const op = "service.approval.ApproveTransfer"
+
+	transfer, err := a.storage.PendingTransfer().Approve(transferID, actorID)
+	if err != nil {
+		return a.resolveError(op, err)
+	}
+
+	a.events.balance(op, transfer.FromUserID, transfer.ToUserID)
+
+	// The transfer is committed; the users are only needed for the event and
+	// the email, so a failed lookup is logged and not returned.
+	sender, err := a.storage.User().GetUserByID(transfer.FromUserID)
+	if err != nil {
+		a.log.Error("failed to get sender:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil
+	}
+	recipient, err := a.storage.User().GetUserByID(transfer.ToUserID)
+	if err != nil {
+		a.log.Error("failed to get recipient:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	a.events.transfer(transfer, sender.Username)
+	notify(a.log, a.storage, op, models.Notification{
+		UserID:  recipient.ID,
+		Type:    models.NotificationTypeCoinsReceived,
+		Message: withMemo(fmt.Sprintf("%s sent you %d coins", sender.Username, transfer.Amount), transfer.Memo),
+	})
+	queueEmail(a.cfg, a.log, a.storage, op, recipient.ID, email.TemplateCoinsReceived, email.CoinsReceivedData{
+		Username: recipient.Username,
+		From:     sender.Username,
+		Amount:   transfer.Amount,
+		Memo:     transfer.Memo,
+	})
+
+	return nil
+}
+
+func (a *approval) RejectTransfer(actorID, transferID int64) error {
+	const op = "service.approval.RejectTransfer"
+
+	transfer, err := a.storage.PendingTransfer().Reject(transferID, actorID)
+	if err != nil {
+		return a.resolveError(op, err)
+	}
+
+	a.events.balance(op, transfer.FromUserID)
+
+	return nil
+}
+
+func (a *approval) resolveError(op string, err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("pending transfer not found")
+	}
+
+	a.log.Error("failed to resolve pending transfer:",
+		zap.String("method", op),
+		zap.Error(err),
+	)
+	return errors.ErrInternal(err)
+}
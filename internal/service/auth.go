@@ -3,6 +3,7 @@ package service
 import (
 	"github.com/icoder-new/avito-shop/internal/config"
 	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/icoder-new/avito-shop/pkg/errors"
 	"github.com/icoder-new/avito-shop/pkg/hash"
@@ -65,7 +66,9 @@ func (a *auth) Login(req dto.AuthRequest) (dto.AuthResponse, error) {
 			return dto.AuthResponse{}, errors.ErrInternal(err)
 		}
 
-		err = a.storage.User().UpdateUserCoins(user.ID, cast.ToInt64(a.cfg.Settings.Service.InitialCoins))
+		initialCoins := cast.ToInt64(a.cfg.Settings.Service.InitialCoins)
+
+		err = a.storage.User().UpdateUserCoins(user.ID, initialCoins)
 		if err != nil {
 			a.log.Error("failed to set initial coins:",
 				zap.String("method", op),
@@ -73,7 +76,20 @@ func (a *auth) Login(req dto.AuthRequest) (dto.AuthResponse, error) {
 			)
 			return dto.AuthResponse{}, errors.ErrInternal(err)
 		}
+
+		err = a.storage.Balance().CreateSnapshot(user.ID, initialCoins)
+		if err != nil {
+			a.log.Error("failed to create initial balance snapshot:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return dto.AuthResponse{}, errors.ErrInternal(err)
+		}
 	} else {
+		if user.Role == models.RoleSystem {
+			return dto.AuthResponse{}, errors.ErrUnauthorized("invalid credentials")
+		}
+
 		valid, err := a.hasher.Verify(req.Password, user.PasswordHash)
 		if err != nil {
 			a.log.Error("failed to verify password:",
@@ -87,7 +103,7 @@ func (a *auth) Login(req dto.AuthRequest) (dto.AuthResponse, error) {
 		}
 	}
 
-	token, err := a.jwt.NewJWT(user.ID, user.Username)
+	token, err := a.jwt.NewJWT(user.ID, user.Username, string(user.Role))
 	if err != nil {
 		a.log.Error("failed to generate token:",
 			zap.String("method", op),
@@ -1,50 +1,75 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/icoder-new/avito-shop/internal/config"
 	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/icoder-new/avito-shop/pkg/errors"
 	"github.com/icoder-new/avito-shop/pkg/logger"
 	"go.uber.org/zap"
 )
 
+const (
+	sendStatusCompleted       = "completed"
+	sendStatusPendingApproval = "pending_approval"
+)
+
+const (
+	batchStatusCompleted   = "completed"
+	batchStatusRejected    = "rejected"
+	batchStatusInvalid     = "invalid"
+	batchStatusNotExecuted = "not_executed"
+)
+
 type ICoin interface {
-	Send(fromUserID int64, req dto.SendCoinRequest) error
+	Send(fromUserID int64, req dto.SendCoinRequest) (dto.SendCoinResponse, error)
+	SendBatch(fromUserID int64, req dto.BatchSendCoinRequest) (dto.BatchSendCoinResponse, error)
+	GetLimits(userID int64) (dto.LimitsResponse, error)
 }
 
 type coin struct {
 	cfg     *config.Config
 	log     *logger.Logger
 	storage storage.IStorage
+	events  *events
 }
 
-func newCoin(cfg *config.Config, log *logger.Logger, storage storage.IStorage) ICoin {
+func newCoin(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) ICoin {
 	return &coin{
 		cfg:     cfg,
 		log:     log,
 		storage: storage,
+		events:  events,
 	}
 }
 
-func (c *coin) Send(fromUserID int64, req dto.SendCoinRequest) error {
+// Send executes a transfer, or reserves the coins and queues it for approval
+// when the amount is above the configured threshold.
+func (c *coin) Send(fromUserID int64, req dto.SendCoinRequest) (dto.SendCoinResponse, error) {
 	const op = "service.coin.Send"
 
 	toUser, err := c.storage.User().GetUserByUsername(req.ToUser)
+	if err == nil && toUser.Role == models.RoleSystem {
+		err = storage.ErrNotFound
+	}
 	if err != nil {
 		c.log.Error("recipient not found:",
 			zap.String("method", op),
 			zap.Error(err),
 		)
-		return errors.ErrNotFound("recipient not found")
+		return dto.SendCoinResponse{}, errors.ErrNotFound("recipient not found")
 	}
 
 	if fromUserID == toUser.ID {
-		return errors.ErrBadRequest("cannot send coins to yourself")
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("cannot send coins to yourself")
 	}
 
 	if req.Amount <= 0 {
-		return errors.ErrBadRequest("amount must be positive")
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("amount must be positive")
 	}
 
 	sender, err := c.storage.User().GetUserByID(fromUserID)
@@ -53,21 +78,247 @@ func (c *coin) Send(fromUserID int64, req dto.SendCoinRequest) error {
 			zap.String("method", op),
 			zap.Error(err),
 		)
-		return errors.ErrInternal(err)
+		return dto.SendCoinResponse{}, errors.ErrInternal(err)
 	}
 
-	if sender.Coins < req.Amount {
-		return errors.ErrBadRequest("insufficient funds")
+	if sender.Coins+sender.GiftableCoins < req.Amount {
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("insufficient funds")
 	}
 
-	err = c.storage.Coin().TransferCoins(fromUserID, toUser.ID, req.Amount)
+	if threshold := c.cfg.Settings.Service.ApprovalThreshold; threshold > 0 && req.Amount > threshold {
+		return c.requestApproval(op, sender, toUser.ID, req)
+	}
+
+	err = c.storage.Coin().TransferCoins(models.Transaction{
+		FromUserID: fromUserID,
+		ToUserID:   toUser.ID,
+		Amount:     req.Amount,
+		Memo:       req.Memo,
+		Category:   models.TransferCategory(req.Category),
+	}, transferLimits(c.cfg, sender.Role))
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("insufficient funds")
+	}
+	if appErr := limitError(err); appErr != nil {
+		return dto.SendCoinResponse{}, appErr
+	}
 	if err != nil {
 		c.log.Error("failed to transfer coins:",
 			zap.String("method", op),
 			zap.Error(err),
 		)
-		return errors.ErrInternal(err)
+		return dto.SendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	notify(c.log, c.storage, op, models.Notification{
+		UserID:  toUser.ID,
+		Type:    models.NotificationTypeCoinsReceived,
+		Message: withMemo(fmt.Sprintf("%s sent you %d coins", sender.Username, req.Amount), req.Memo),
+	})
+	queueEmail(c.cfg, c.log, c.storage, op, toUser.ID, email.TemplateCoinsReceived, email.CoinsReceivedData{
+		Username: toUser.Username,
+		From:     sender.Username,
+		Amount:   req.Amount,
+		Memo:     req.Memo,
+	})
+	c.events.balance(op, fromUserID, toUser.ID)
+	c.events.transfer(models.Transaction{ToUserID: toUser.ID, Amount: req.Amount, Memo: req.Memo}, sender.Username)
+
+	return dto.SendCoinResponse{Status: sendStatusCompleted}, nil
+}
+
+func (c *coin) requestApproval(
+	op string,
+	sender models.User,
+	toUserID int64,
+	req dto.SendCoinRequest,
+) (dto.SendCoinResponse, error) {
+	pending, err := c.storage.PendingTransfer().Create(models.PendingTransfer{
+		FromUserID: sender.ID,
+		ToUserID:   toUserID,
+		Amount:     req.Amount,
+		Memo:       req.Memo,
+		Category:   models.TransferCategory(req.Category),
+	}, transferLimits(c.cfg, sender.Role))
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("insufficient funds")
+	}
+	if appErr := limitError(err); appErr != nil {
+		return dto.SendCoinResponse{}, appErr
+	}
+	if err != nil {
+		c.log.Error("failed to create pending transfer:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	c.events.balance(op, sender.ID)
+
+	return dto.SendCoinResponse{
+		Status:            sendStatusPendingApproval,
+		PendingTransferID: pending.ID,
+	}, nil
+}
+
+// SendBatch validates every recipient and the batch total before moving any
+// coins, then executes all transfers atomically. On rejection the response
+// still lists each recipient with its own status.
+func (c *coin) SendBatch(fromUserID int64, req dto.BatchSendCoinRequest) (dto.BatchSendCoinResponse, error) {
+	const op = "service.coin.SendBatch"
+
+	usernames := make([]string, 0, len(req.Transfers))
+	for _, item := range req.Transfers {
+		usernames = append(usernames, item.ToUser)
+	}
+
+	recipients, err := c.storage.User().GetUsersByUsernames(usernames)
+	if err != nil {
+		c.log.Error("failed to get recipients:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.BatchSendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	recipientIDs := make(map[string]int64, len(recipients))
+	for _, recipient := range recipients {
+		if recipient.Role != models.RoleSystem {
+			recipientIDs[recipient.Username] = recipient.ID
+		}
+	}
+
+	sender, err := c.storage.User().GetUserByID(fromUserID)
+	if err != nil {
+		c.log.Error("failed to get sender:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.BatchSendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	var (
+		threshold = c.cfg.Settings.Service.ApprovalThreshold
+		response  = dto.BatchSendCoinResponse{Results: make([]dto.BatchTransferResult, len(req.Transfers))}
+		transfers = make([]models.Transaction, 0, len(req.Transfers))
+		total     int64
+		invalid   bool
+	)
+
+	for i, item := range req.Transfers {
+		response.Results[i] = dto.BatchTransferResult{
+			ToUser: item.ToUser,
+			Amount: item.Amount,
+			Status: batchStatusNotExecuted,
+		}
+
+		toUserID, exists := recipientIDs[item.ToUser]
+		switch {
+		case !exists:
+			response.Results[i].Status, response.Results[i].Error = batchStatusInvalid, "recipient not found"
+		case toUserID == fromUserID:
+			response.Results[i].Status, response.Results[i].Error = batchStatusInvalid, "cannot send coins to yourself"
+		case threshold > 0 && item.Amount > threshold:
+			response.Results[i].Status, response.Results[i].Error = batchStatusInvalid,
+				"transfers above the approval threshold must be sent individually"
+		}
+		if response.Results[i].Status == batchStatusInvalid {
+			invalid = true
+			continue
+		}
+
+		total += item.Amount
+		transfers = append(transfers, models.Transaction{
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			Amount:     item.Amount,
+			Memo:       item.Memo,
+			Category:   models.TransferCategory(item.Category),
+		})
+	}
+
+	if invalid {
+		return rejectBatch(response, errors.ErrBadRequest("some transfers are invalid"))
+	}
+	if sender.Coins+sender.GiftableCoins < total {
+		return rejectBatch(response, errors.ErrBadRequest("insufficient funds for the batch total"))
+	}
+
+	completed, err := c.storage.Coin().TransferBatch(transfers, transferLimits(c.cfg, sender.Role))
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		return rejectBatch(response, errors.ErrBadRequest("insufficient funds for the batch total"))
+	}
+	if appErr := limitError(err); appErr != nil {
+		return rejectBatch(response, appErr)
+	}
+	if err != nil {
+		c.log.Error("failed to transfer batch:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.BatchSendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	response.Status = batchStatusCompleted
+	c.events.balance(op, fromUserID)
+	for i, transfer := range completed {
+		response.Results[i].Status = batchStatusCompleted
+		response.Results[i].TransactionID = transfer.ID
+
+		notify(c.log, c.storage, op, models.Notification{
+			UserID:  transfer.ToUserID,
+			Type:    models.NotificationTypeCoinsReceived,
+			Message: withMemo(fmt.Sprintf("%s sent you %d coins", sender.Username, transfer.Amount), transfer.Memo),
+		})
+		queueEmail(c.cfg, c.log, c.storage, op, transfer.ToUserID, email.TemplateCoinsReceived, email.CoinsReceivedData{
+			Username: response.Results[i].ToUser,
+			From:     sender.Username,
+			Amount:   transfer.Amount,
+			Memo:     transfer.Memo,
+		})
+		c.events.balance(op, transfer.ToUserID)
+		c.events.transfer(transfer, sender.Username)
 	}
 
-	return nil
+	return response, nil
+}
+
+func (c *coin) GetLimits(userID int64) (dto.LimitsResponse, error) {
+	const op = "service.coin.GetLimits"
+
+	user, err := c.storage.User().GetUserByID(userID)
+	if err != nil {
+		c.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.LimitsResponse{}, errors.ErrInternal(err)
+	}
+
+	usage, err := c.storage.Coin().GetTransferUsage(userID)
+	if err != nil {
+		c.log.Error("failed to get transfer usage:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.LimitsResponse{}, errors.ErrInternal(err)
+	}
+
+	limits := transferLimits(c.cfg, user.Role)
+	response := dto.LimitsResponse{
+		Daily:   limitStatus(limits.Daily, usage.Daily),
+		Monthly: limitStatus(limits.Monthly, usage.Monthly),
+	}
+	if limits.PerTransfer > 0 {
+		response.PerTransfer = &limits.PerTransfer
+	}
+
+	return response, nil
+}
+
+func rejectBatch(response dto.BatchSendCoinResponse, err *errors.AppError) (dto.BatchSendCoinResponse, error) {
+	response.Status = batchStatusRejected
+	response.Message = err.Message
+	return response, err
 }
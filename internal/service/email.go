@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	emailClaimBatch     = 20
+	emailMaxErrorLength = 500
+
+	defaultEmailTimeout     = 10 * time.Second
+	defaultEmailMaxAttempts = 5
+	defaultEmailRetryDelay  = 5 * time.Minute
+)
+
+type IEmail interface {
+	GetSettings(userID int64) (dto.EmailSettings, error)
+	UpdateSettings(userID int64, req dto.EmailSettings) error
+	SendQueued() error
+}
+
+type mail struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	sender  email.Sender
+}
+
+func newEmail(cfg *config.Config, log *logger.Logger, storage storage.IStorage, sender email.Sender) IEmail {
+	return &mail{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		sender:  sender,
+	}
+}
+
+func (m *mail) GetSettings(userID int64) (dto.EmailSettings, error) {
+	const op = "service.email.GetSettings"
+
+	user, err := m.storage.User().GetUserByID(userID)
+	if err != nil {
+		m.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.EmailSettings{}, errors.ErrInternal(err)
+	}
+
+	settings := dto.EmailSettings{OptOut: user.EmailOptOut}
+	if user.Email != nil {
+		settings.Email = *user.Email
+	}
+	return settings, nil
+}
+
+// UpdateSettings sets the address emails go to and whether the user wants
+// them at all. An empty address removes it.
+func (m *mail) UpdateSettings(userID int64, req dto.EmailSettings) error {
+	const op = "service.email.UpdateSettings"
+
+	var address *string
+	if req.Email != "" {
+		normalized := strings.ToLower(strings.TrimSpace(req.Email))
+		address = &normalized
+	}
+
+	err := m.storage.User().UpdateEmailSettings(userID, address, req.OptOut)
+	if errors.Is(err, storage.ErrConflict) {
+		return errors.ErrBadRequest("email is already in use")
+	}
+	if err != nil {
+		m.log.Error("failed to update email settings:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// SendQueued renders and sends every due email. Emails of a batch are sent
+// in parallel and leased for twice the SMTP timeout.
+func (m *mail) SendQueued() error {
+	const op = "service.email.SendQueued"
+
+	timeout := m.cfg.Settings.Email.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmailTimeout
+	}
+
+	for {
+		emails, err := m.storage.Email().ClaimDue(emailClaimBatch, 2*timeout)
+		if err != nil {
+			m.log.Error("failed to claim emails:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		var wg sync.WaitGroup
+		for _, queued := range emails {
+			wg.Add(1)
+			go func(queued models.QueuedEmailDetails) {
+				defer wg.Done()
+				m.attempt(op, queued)
+			}(queued)
+		}
+		wg.Wait()
+
+		if len(emails) < emailClaimBatch {
+			return nil
+		}
+	}
+}
+
+func (m *mail) attempt(op string, queued models.QueuedEmailDetails) {
+	err := m.send(queued)
+	if err == nil {
+		if err = m.storage.Email().MarkSent(queued.ID); err != nil {
+			m.log.Error("failed to mark email as sent:",
+				zap.String("method", op),
+				zap.Int64("email_id", queued.ID),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	maxAttempts, retryDelay := m.cfg.Settings.Email.MaxAttempts, m.cfg.Settings.Email.RetryDelay
+	if maxAttempts <= 0 {
+		maxAttempts = defaultEmailMaxAttempts
+	}
+	if retryDelay <= 0 {
+		retryDelay = defaultEmailRetryDelay
+	}
+
+	var retryAt *time.Time
+	if queued.Attempts+1 < maxAttempts {
+		next := time.Now().Add(retryDelay)
+		retryAt = &next
+	}
+
+	m.log.Warn("failed to send email",
+		zap.String("method", op),
+		zap.Int64("email_id", queued.ID),
+		zap.String("template", queued.Template),
+		zap.Bool("failed", retryAt == nil),
+		zap.Error(err),
+	)
+
+	lastError := err.Error()
+	if len(lastError) > emailMaxErrorLength {
+		lastError = lastError[:emailMaxErrorLength]
+	}
+	if err = m.storage.Email().MarkFailed(queued.ID, lastError, retryAt); err != nil {
+		m.log.Error("failed to record email failure:",
+			zap.String("method", op),
+			zap.Int64("email_id", queued.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (m *mail) send(queued models.QueuedEmailDetails) error {
+	// Numbers are kept as written; float64 would print large amounts in
+	// exponent form.
+	var data map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(queued.Data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return err
+	}
+
+	msg, err := email.Render(queued.Template, data)
+	if err != nil {
+		return err
+	}
+	msg.To = queued.To
+
+	return m.sender.Send(msg)
+}
+
+// queueEmail queues an email for the user when email is enabled. Users
+// without an address or who opted out are skipped. Like notifications, a
+// failure is logged and never fails the operation that sent the email.
+func queueEmail(cfg *config.Config, log *logger.Logger, storage storage.IStorage, op string, userID int64, template string, data any) {
+	if !cfg.Settings.Email.Enabled {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err == nil {
+		_, err = storage.Email().Enqueue(userID, template, payload)
+	}
+	if err != nil {
+		log.Error("failed to queue email:",
+			zap.String("method", op),
+			zap.Int64("user_id", userID),
+			zap.String("template", template),
+			zap.Error(err),
+		)
+	}
+}
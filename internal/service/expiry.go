@@ -0,0 +1,73 @@
+package service
+
+import (
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const expiredUsersBatchSize = 100
+
+type IExpiry interface {
+	ExpireDue() error
+}
+
+type expiry struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newExpiry(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IExpiry {
+	return &expiry{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+// ExpireDue writes off expired coin lots, one user at a time, until none are
+// left. A failure for one user is logged and does not stop the others.
+func (e *expiry) ExpireDue() error {
+	const op = "service.expiry.ExpireDue"
+
+	now := time.Now().UTC()
+
+	for {
+		userIDs, err := e.storage.Coin().GetUsersWithExpiredLots(now, expiredUsersBatchSize)
+		if err != nil {
+			e.log.Error("failed to get users with expired coins:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		var expired int
+		for _, userID := range userIDs {
+			_, err := e.storage.Coin().ExpireLots(userID, now)
+			switch {
+			case err == nil:
+				e.events.balance(op, userID)
+				expired++
+			case errors.Is(err, storage.ErrNotFound):
+			default:
+				e.log.Error("failed to expire coins:",
+					zap.String("method", op),
+					zap.Int64("user_id", userID),
+					zap.Error(err),
+				)
+			}
+		}
+
+		if len(userIDs) < expiredUsersBatchSize || expired == 0 {
+			return nil
+		}
+	}
+}
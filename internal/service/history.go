@@ -0,0 +1,76 @@
+package service
+
+import (
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IHistory interface {
+	GetHistory(userID int64, query dto.HistoryQuery) (dto.HistoryResponse, error)
+}
+
+type history struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+}
+
+func newHistory(cfg *config.Config, log *logger.Logger, storage storage.IStorage) IHistory {
+	return &history{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+	}
+}
+
+func (h *history) GetHistory(userID int64, query dto.HistoryQuery) (dto.HistoryResponse, error) {
+	const op = "service.history.GetHistory"
+
+	transactions, err := h.storage.TransactionHistory().GetTransactions(userID, storage.TransactionFilter{
+		Query:    query.Query,
+		Type:     models.TransactionType(query.Type),
+		Category: models.TransferCategory(query.Category),
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+	if err != nil {
+		h.log.Error("failed to get transactions:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.HistoryResponse{}, errors.ErrInternal(err)
+	}
+
+	response := dto.HistoryResponse{
+		Transactions: make([]dto.HistoryEntry, 0, len(transactions)),
+	}
+
+	for _, tx := range transactions {
+		entry := dto.HistoryEntry{
+			ID:             tx.ID,
+			Type:           string(tx.Type),
+			FromUser:       tx.FromUsername,
+			ToUser:         tx.ToUsername,
+			Item:           tx.MerchName,
+			Amount:         tx.Amount,
+			Memo:           tx.Memo,
+			Category:       string(tx.Category),
+			Reason:         tx.Reason,
+			OriginalAmount: tx.OriginalAmount,
+			CreatedAt:      tx.CreatedAt,
+		}
+		if tx.Type == models.TransactionTypeRefund {
+			entry.RefundOf = tx.OriginalTransactionID
+		} else {
+			entry.ReversalOf = tx.OriginalTransactionID
+		}
+		response.Transactions = append(response.Transactions, entry)
+	}
+
+	return response, nil
+}
@@ -1,7 +1,13 @@
 package service
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/icoder-new/avito-shop/pkg/errors"
 	"github.com/icoder-new/avito-shop/pkg/logger"
@@ -9,52 +15,131 @@ import (
 )
 
 type IInventory interface {
-	BuyItem(userID int64, itemName string) error
+	BuyItem(userID int64, itemName string, query dto.BuyQuery) error
+	Gift(userID int64, req dto.GiftRequest) error
+	TransferItem(userID int64, req dto.ItemTransferRequest) error
+	GetVariants(itemName string) ([]dto.Variant, error)
+	AddVariant(itemName string, req dto.VariantRequest) (dto.Variant, error)
+	SetVariantStock(sku string, req dto.VariantStockRequest) error
+	CreateItem(req dto.MerchRequest) (dto.Merch, error)
+	SetAvailability(itemName string, req dto.AvailabilityRequest) error
 }
 
 type inventory struct {
 	cfg     *config.Config
 	log     *logger.Logger
 	storage storage.IStorage
-	items   map[string]itemInfo
-}
-
-type itemInfo struct {
-	id    int64
-	price int64
+	events  *events
 }
 
-func newInventory(cfg *config.Config, log *logger.Logger, storage storage.IStorage) IInventory {
-	items := map[string]itemInfo{
-		"t-shirt":    {id: 1, price: 80},
-		"cup":        {id: 2, price: 20},
-		"book":       {id: 3, price: 50},
-		"pen":        {id: 4, price: 10},
-		"powerbank":  {id: 5, price: 200},
-		"hoody":      {id: 6, price: 300},
-		"umbrella":   {id: 7, price: 200},
-		"socks":      {id: 8, price: 10},
-		"wallet":     {id: 9, price: 50},
-		"pink-hoody": {id: 10, price: 500},
-	}
-
+func newInventory(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IInventory {
 	return &inventory{
 		cfg:     cfg,
 		log:     log,
 		storage: storage,
-		items:   items,
+		events:  events,
 	}
 }
 
-func (i *inventory) BuyItem(userID int64, itemName string) error {
+// BuyItem buys one unit of an item. Items that come in several variants must
+// be bought by the SKU of one of them. The best running promotion, or the one
+// behind the given promo code, is applied before the funds check.
+func (i *inventory) BuyItem(userID int64, itemName string, query dto.BuyQuery) error {
 	const op = "service.inventory.BuyItem"
 
-	item, exists := i.items[itemName]
+	return i.checkout(op, models.Transaction{FromUserID: userID}, itemName, query)
+}
+
+// Gift buys an item for a colleague. The sender pays and the item, with its
+// order, goes to the recipient. Gifts go through the same checkout as a
+// purchase, promotions included.
+func (i *inventory) Gift(userID int64, req dto.GiftRequest) error {
+	const op = "service.inventory.Gift"
+
+	recipient, err := i.recipient(op, userID, req.ToUser)
+	if err != nil {
+		return err
+	}
+
+	return i.checkout(op, models.Transaction{
+		FromUserID: userID,
+		ToUserID:   recipient.ID,
+		Memo:       req.Memo,
+	}, req.Item, dto.BuyQuery{SKU: req.SKU, PromoCode: req.PromoCode})
+}
+
+// TransferItem hands one unit of an owned item to a colleague.
+func (i *inventory) TransferItem(userID int64, req dto.ItemTransferRequest) error {
+	const op = "service.inventory.TransferItem"
+
+	recipient, err := i.recipient(op, userID, req.ToUser)
+	if err != nil {
+		return err
+	}
+
+	item, exists, err := i.getItem(op, req.Item)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrBadRequest("invalid item name")
+	}
+
+	var variantID *int64
+	if req.SKU != "" {
+		variant, err := i.storage.Variant().GetBySKU(req.SKU)
+		if err != nil || variant.MerchID != item.ID {
+			return errors.ErrBadRequest("invalid sku for this item")
+		}
+		variantID = &variant.ID
+	}
+
+	_, err = i.storage.Inventory().TransferItem(models.Transaction{
+		FromUserID: userID,
+		ToUserID:   recipient.ID,
+		MerchID:    &item.ID,
+		VariantID:  variantID,
+		Memo:       req.Memo,
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrBadRequest("you do not own this item")
+	}
+	if err != nil {
+		i.log.Error("failed to transfer item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// checkout charges t.FromUserID for one unit of the item and delivers it to
+// the buyer, or to t.ToUserID when it is a gift.
+func (i *inventory) checkout(op string, t models.Transaction, itemName string, query dto.BuyQuery) error {
+	item, exists, err := i.getItem(op, itemName)
+	if err != nil {
+		return err
+	}
 	if !exists {
 		return errors.ErrBadRequest("invalid item name")
 	}
+	if err = saleError(item, time.Now()); err != nil {
+		return err
+	}
+
+	variantID, err := resolveVariant(i.log, i.storage, op, item, query.SKU)
+	if err != nil {
+		return err
+	}
 
-	user, err := i.storage.User().GetUserByID(userID)
+	promotionID, price, err := i.applyPromotion(op, t.FromUserID, item, strings.ToUpper(query.PromoCode))
+	if err != nil {
+		return err
+	}
+
+	user, err := i.storage.User().GetUserByID(t.FromUserID)
 	if err != nil {
 		i.log.Error("failed to get user:",
 			zap.String("method", op),
@@ -63,18 +148,365 @@ func (i *inventory) BuyItem(userID int64, itemName string) error {
 		return errors.ErrNotFound("user not found")
 	}
 
-	if user.Coins < item.price {
+	if user.Coins < price {
 		return errors.ErrBadRequest("insufficient funds")
 	}
 
-	err = i.storage.Inventory().BuyItem(userID, item.id)
-	if err != nil {
+	t.MerchID, t.VariantID, t.PromotionID = &item.ID, variantID, promotionID
+	if t.ToUserID != 0 {
+		_, err = i.storage.Inventory().GiftItem(t)
+	} else {
+		err = i.storage.Inventory().BuyItem(t.FromUserID, item.ID, variantID, promotionID)
+	}
+	switch {
+	case err == nil:
+		i.notifyPurchase(op, t, user.Username, itemLabel(item.Name, query.SKU), price)
+		i.events.balance(op, t.FromUserID)
+		return nil
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return errors.ErrBadRequest("insufficient funds")
+	case errors.Is(err, storage.ErrOutOfStock):
+		return errors.ErrBadRequest("item is out of stock")
+	case errors.Is(err, storage.ErrNotApplicable):
+		return errors.ErrBadRequest("promotion is no longer available")
+	case errors.Is(err, storage.ErrNotAvailable):
+		return errors.ErrBadRequest("item is not on sale")
+	case errors.Is(err, storage.ErrPurchaseLimit):
+		return errors.ErrBadRequest("purchase limit for this item reached")
+	default:
 		i.log.Error("failed to buy item:",
 			zap.String("method", op),
 			zap.Error(err),
 		)
 		return errors.ErrInternal(err)
 	}
+}
+
+// notifyPurchase tells the buyer about the purchase, or the recipient about
+// their gift.
+func (i *inventory) notifyPurchase(op string, t models.Transaction, payer, label string, price int64) {
+	notification := models.Notification{
+		UserID:  t.FromUserID,
+		Type:    models.NotificationTypeMerchPurchased,
+		Message: fmt.Sprintf("You bought %s for %d coins", label, price),
+		MerchID: t.MerchID,
+	}
+	if t.ToUserID != 0 {
+		notification.UserID = t.ToUserID
+		notification.Type = models.NotificationTypeGiftReceived
+		notification.Message = withMemo(fmt.Sprintf("%s sent you %s as a gift", payer, label), t.Memo)
+	}
+
+	notify(i.log, i.storage, op, notification)
+}
+
+// recipient looks up the colleague an item goes to.
+func (i *inventory) recipient(op string, userID int64, username string) (models.User, error) {
+	recipient, err := i.storage.User().GetUserByUsername(username)
+	if err == nil && recipient.Role == models.RoleSystem {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		i.log.Error("recipient not found:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return models.User{}, errors.ErrNotFound("recipient not found")
+	}
+	if recipient.ID == userID {
+		return models.User{}, errors.ErrBadRequest("cannot send an item to yourself")
+	}
+
+	return recipient, nil
+}
+
+// CreateItem adds an item to the shop. Items announced ahead of a drop get an
+// availability window and can take pre-orders until it opens.
+func (i *inventory) CreateItem(req dto.MerchRequest) (dto.Merch, error) {
+	const op = "service.inventory.CreateItem"
+
+	if err := validateAvailability(req.AvailabilityRequest); err != nil {
+		return dto.Merch{}, err
+	}
+
+	item, err := i.storage.Inventory().CreateMerch(models.Merch{
+		Name:           req.Name,
+		Price:          req.Price,
+		Stock:          req.Stock,
+		Category:       req.Category,
+		AvailableFrom:  req.AvailableFrom,
+		AvailableUntil: req.AvailableUntil,
+		PerUserLimit:   req.PerUserLimit,
+		PreordersOpen:  req.PreordersOpen,
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		return dto.Merch{}, errors.ErrBadRequest("item already exists")
+	}
+	if err != nil {
+		i.log.Error("failed to create item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Merch{}, errors.ErrInternal(err)
+	}
+
+	return convertMerch(item), nil
+}
+
+// SetAvailability replaces the sale window, the per-user cap and the
+// pre-order flag of an item.
+func (i *inventory) SetAvailability(itemName string, req dto.AvailabilityRequest) error {
+	const op = "service.inventory.SetAvailability"
+
+	if err := validateAvailability(req); err != nil {
+		return err
+	}
+
+	item, exists, err := i.getItem(op, itemName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrNotFound("item not found")
+	}
+
+	item.AvailableFrom, item.AvailableUntil = req.AvailableFrom, req.AvailableUntil
+	item.PerUserLimit, item.PreordersOpen = req.PerUserLimit, req.PreordersOpen
+
+	err = i.storage.Inventory().UpdateAvailability(item)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrBadRequest("item drop was cancelled")
+	}
+	if err != nil {
+		i.log.Error("failed to update availability:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+func (i *inventory) GetVariants(itemName string) ([]dto.Variant, error) {
+	const op = "service.inventory.GetVariants"
+
+	item, exists, err := i.getItem(op, itemName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.ErrNotFound("item not found")
+	}
+
+	variants, err := i.storage.Variant().GetByMerch(item.ID)
+	if err != nil {
+		i.log.Error("failed to get variants:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.Variant, 0, len(variants))
+	for _, variant := range variants {
+		response = append(response, convertVariant(itemName, variant))
+	}
+
+	return response, nil
+}
+
+func (i *inventory) AddVariant(itemName string, req dto.VariantRequest) (dto.Variant, error) {
+	const op = "service.inventory.AddVariant"
+
+	item, exists, err := i.getItem(op, itemName)
+	if err != nil {
+		return dto.Variant{}, err
+	}
+	if !exists {
+		return dto.Variant{}, errors.ErrNotFound("item not found")
+	}
+
+	if req.Size == "" && req.Color == "" {
+		return dto.Variant{}, errors.ErrBadRequest("variant needs a size or a color")
+	}
+
+	variant, err := i.storage.Variant().Create(models.MerchVariant{
+		MerchID: item.ID,
+		SKU:     req.SKU,
+		Size:    req.Size,
+		Color:   req.Color,
+		Stock:   req.Stock,
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		return dto.Variant{}, errors.ErrBadRequest("sku is already taken")
+	}
+	if err != nil {
+		i.log.Error("failed to create variant:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Variant{}, errors.ErrInternal(err)
+	}
+
+	return convertVariant(itemName, variant), nil
+}
+
+func (i *inventory) SetVariantStock(sku string, req dto.VariantStockRequest) error {
+	const op = "service.inventory.SetVariantStock"
+
+	variant, err := i.storage.Variant().GetBySKU(sku)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("variant not found")
+	}
+	if err == nil {
+		err = i.storage.Variant().SetStock(variant.ID, req.Stock)
+	}
+	if err != nil {
+		i.log.Error("failed to set variant stock:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
 
 	return nil
 }
+
+// resolveVariant finds the variant of the item with the given SKU. Items
+// without variants are bought as a whole and must not be given a SKU.
+func resolveVariant(
+	log *logger.Logger, storage storage.IStorage, op string, item models.Merch, sku string,
+) (*int64, error) {
+	variants, err := storage.Variant().GetByMerch(item.ID)
+	if err != nil {
+		log.Error("failed to get variants:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	if len(variants) == 0 {
+		if sku != "" {
+			return nil, errors.ErrBadRequest("item has no variants")
+		}
+		return nil, nil
+	}
+
+	if sku == "" {
+		return nil, errors.ErrBadRequest("item comes in several variants, choose one by sku")
+	}
+
+	for _, variant := range variants {
+		if variant.SKU == sku {
+			return &variant.ID, nil
+		}
+	}
+
+	return nil, errors.ErrBadRequest("invalid sku for this item")
+}
+
+// getItem looks an item up by name. A missing item is reported through the
+// second result so that each caller can pick its own error.
+func (i *inventory) getItem(op, name string) (models.Merch, bool, error) {
+	item, err := i.storage.Inventory().GetMerchByName(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.Merch{}, false, nil
+	}
+	if err != nil {
+		i.log.Error("failed to get item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return models.Merch{}, false, errors.ErrInternal(err)
+	}
+
+	return item, true, nil
+}
+
+// saleError explains why an item cannot be bought at the given time.
+func saleError(item models.Merch, at time.Time) error {
+	switch {
+	case item.OnSale(at):
+		return nil
+	case item.CancelledAt != nil:
+		return errors.ErrBadRequest("item drop was cancelled")
+	case item.AcceptsPreorders(at):
+		return errors.ErrBadRequest("item is not on sale yet, pre-order it instead")
+	case item.AvailableFrom != nil && at.Before(*item.AvailableFrom):
+		return errors.ErrBadRequest("item is not on sale yet")
+	default:
+		return errors.ErrBadRequest("item is no longer on sale")
+	}
+}
+
+func validateAvailability(req dto.AvailabilityRequest) error {
+	if req.AvailableFrom != nil && req.AvailableUntil != nil && !req.AvailableUntil.After(*req.AvailableFrom) {
+		return errors.ErrBadRequest("availableUntil must be after availableFrom")
+	}
+	if req.PreordersOpen && req.AvailableFrom == nil {
+		return errors.ErrBadRequest("pre-orders need an availableFrom date")
+	}
+	return nil
+}
+
+// applyPromotion picks the promotion that gives the largest discount on the
+// item and returns it with the price to pay. A promo code that does not apply
+// is an error rather than a silent full-price purchase.
+func (i *inventory) applyPromotion(op string, userID int64, item models.Merch, code string) (*int64, int64, error) {
+	promotions, err := i.storage.Promotion().GetApplicable(userID, item.ID, code)
+	if err != nil {
+		i.log.Error("failed to get promotions:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, 0, errors.ErrInternal(err)
+	}
+
+	if code != "" && len(promotions) == 0 {
+		return nil, 0, errors.ErrBadRequest("promo code is not valid for this item")
+	}
+
+	best, price := bestPromotion(promotions, item.Price)
+	return best, price, nil
+}
+
+// bestPromotion picks the promotion with the largest discount on the price
+// and returns it with the discounted price.
+func bestPromotion(promotions []models.Promotion, price int64) (*int64, int64) {
+	var (
+		best     *int64
+		discount int64
+	)
+	for _, promotion := range promotions {
+		if d := promotion.Discount(price); d > discount {
+			best, discount = &promotion.ID, d
+		}
+	}
+
+	return best, price - discount
+}
+
+func convertVariant(itemName string, variant models.MerchVariant) dto.Variant {
+	return dto.Variant{
+		Item:  itemName,
+		SKU:   variant.SKU,
+		Size:  variant.Size,
+		Color: variant.Color,
+		Stock: variant.Stock,
+	}
+}
+
+func convertMerch(item models.Merch) dto.Merch {
+	return dto.Merch{
+		Name:           item.Name,
+		Price:          item.Price,
+		Stock:          item.Stock,
+		Category:       item.Category,
+		AvailableFrom:  item.AvailableFrom,
+		AvailableUntil: item.AvailableUntil,
+		PerUserLimit:   item.PerUserLimit,
+		PreordersOpen:  item.PreordersOpen,
+	}
+}
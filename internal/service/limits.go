@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+)
+
+// transferLimits returns the limits configured for the role, falling back to
+// the defaults when the role has no override.
+func transferLimits(cfg *config.Config, role models.Role) models.TransferLimits {
+	settings := cfg.Settings.Service.TransferLimits
+	limit, ok := settings.Roles[string(role)]
+	if !ok {
+		limit = settings.Default
+	}
+
+	return models.TransferLimits{
+		PerTransfer: limit.PerTransfer,
+		Daily:       limit.Daily,
+		Monthly:     limit.Monthly,
+	}
+}
+
+// limitError converts a storage limit error into a client error that tells the
+// sender how much they can still send. It returns nil for any other error.
+func limitError(err error) *errors.AppError {
+	var limitErr *storage.LimitExceededError
+	if !errors.As(err, &limitErr) {
+		return nil
+	}
+
+	if limitErr.Limit == storage.LimitPerTransfer {
+		return errors.ErrBadRequest(fmt.Sprintf(
+			"amount exceeds the per-transfer limit of %d coins", limitErr.Max))
+	}
+
+	return errors.ErrBadRequest(fmt.Sprintf(
+		"%s transfer limit of %d coins exceeded, %d coins remaining",
+		limitErr.Limit, limitErr.Max, limitErr.Remaining))
+}
+
+func limitStatus(limit, used int64) dto.LimitStatus {
+	status := dto.LimitStatus{Used: used}
+	if limit > 0 {
+		remaining := max(limit-used, 0)
+		status.Limit, status.Remaining = &limit, &remaining
+	}
+	return status
+}
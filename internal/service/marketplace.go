@@ -0,0 +1,230 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IMarketplace interface {
+	CreateListing(userID int64, req dto.ListingRequest) (dto.Listing, error)
+	Search(query dto.ListingsQuery) ([]dto.Listing, error)
+	MyListings(userID int64) ([]dto.Listing, error)
+	Buy(userID, listingID int64) error
+	Cancel(userID, listingID int64) error
+}
+
+type marketplace struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newMarketplace(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IMarketplace {
+	return &marketplace{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+// CreateListing puts one unit of an owned item up for sale. The unit leaves
+// the seller's inventory until the listing is sold or cancelled. Prices are
+// capped at the approval threshold because sales are paid out immediately.
+func (m *marketplace) CreateListing(userID int64, req dto.ListingRequest) (dto.Listing, error) {
+	const op = "service.marketplace.CreateListing"
+
+	if threshold := m.cfg.Settings.Service.ApprovalThreshold; threshold > 0 && req.Price > threshold {
+		return dto.Listing{}, errors.ErrBadRequest(fmt.Sprintf(
+			"price must not exceed the approval threshold of %d coins", threshold))
+	}
+
+	item, err := m.storage.Inventory().GetMerchByName(req.Item)
+	if errors.Is(err, storage.ErrNotFound) {
+		return dto.Listing{}, errors.ErrBadRequest("invalid item name")
+	}
+	if err != nil {
+		m.log.Error("failed to get item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Listing{}, errors.ErrInternal(err)
+	}
+
+	var variantID *int64
+	if req.SKU != "" {
+		variant, err := m.storage.Variant().GetBySKU(req.SKU)
+		if err != nil || variant.MerchID != item.ID {
+			return dto.Listing{}, errors.ErrBadRequest("invalid sku for this item")
+		}
+		variantID = &variant.ID
+	}
+
+	created, err := m.storage.Marketplace().Create(models.Listing{
+		SellerID:  userID,
+		MerchID:   item.ID,
+		VariantID: variantID,
+		Price:     req.Price,
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return dto.Listing{}, errors.ErrBadRequest("you do not own this item")
+	}
+	if err != nil {
+		m.log.Error("failed to create listing:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Listing{}, errors.ErrInternal(err)
+	}
+
+	listing, err := m.storage.Marketplace().GetByID(created.ID)
+	if err != nil {
+		m.log.Error("failed to get listing:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Listing{}, errors.ErrInternal(err)
+	}
+
+	return convertListing(listing), nil
+}
+
+// Search browses the active listings, cheapest first.
+func (m *marketplace) Search(query dto.ListingsQuery) ([]dto.Listing, error) {
+	const op = "service.marketplace.Search"
+
+	if query.MaxPrice > 0 && query.MinPrice > query.MaxPrice {
+		return nil, errors.ErrBadRequest("minPrice must not exceed maxPrice")
+	}
+
+	listings, err := m.storage.Marketplace().Search(storage.ListingFilter{
+		Query:    query.Query,
+		Item:     query.Item,
+		Category: query.Category,
+		MinPrice: query.MinPrice,
+		MaxPrice: query.MaxPrice,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+	if err != nil {
+		m.log.Error("failed to search listings:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertListings(listings), nil
+}
+
+func (m *marketplace) MyListings(userID int64) ([]dto.Listing, error) {
+	const op = "service.marketplace.MyListings"
+
+	listings, err := m.storage.Marketplace().GetUserListings(userID)
+	if err != nil {
+		m.log.Error("failed to get listings:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertListings(listings), nil
+}
+
+// Buy purchases a listing. The buyer pays the listed price, which counts
+// against their transfer limits; the seller gets it less the configured
+// marketplace fee.
+func (m *marketplace) Buy(userID, listingID int64) error {
+	const op = "service.marketplace.Buy"
+
+	buyer, err := m.storage.User().GetUserByID(userID)
+	if err != nil {
+		m.log.Error("failed to get buyer:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	sale, err := m.storage.Marketplace().Buy(
+		listingID, userID, m.cfg.Settings.Service.MarketplaceFeePercent, transferLimits(m.cfg, buyer.Role),
+	)
+	switch {
+	case err == nil:
+		notify(m.log, m.storage, op, models.Notification{
+			UserID:  sale.ToUserID,
+			Type:    models.NotificationTypeCoinsReceived,
+			Message: fmt.Sprintf("%s bought your listing for %d coins", buyer.Username, sale.Amount),
+		})
+		m.events.balance(op, sale.FromUserID, sale.ToUserID)
+		m.events.transfer(sale, buyer.Username)
+		return nil
+	case limitError(err) != nil:
+		return limitError(err)
+	case errors.Is(err, storage.ErrNotFound):
+		return errors.ErrNotFound("listing not found or no longer available")
+	case errors.Is(err, storage.ErrConflict):
+		return errors.ErrBadRequest("cannot buy your own listing")
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return errors.ErrBadRequest("insufficient funds")
+	default:
+		m.log.Error("failed to buy listing:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+}
+
+// Cancel withdraws the seller's listing and returns the item to them.
+func (m *marketplace) Cancel(userID, listingID int64) error {
+	const op = "service.marketplace.Cancel"
+
+	err := m.storage.Marketplace().Cancel(listingID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("listing not found or already closed")
+	}
+	if err != nil {
+		m.log.Error("failed to cancel listing:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+func convertListing(l models.ListingDetails) dto.Listing {
+	return dto.Listing{
+		ID:        l.ID,
+		Seller:    l.Seller,
+		Item:      l.MerchName,
+		Category:  l.Category,
+		SKU:       l.SKU,
+		Size:      l.Size,
+		Color:     l.Color,
+		Price:     l.Price,
+		Status:    string(l.Status),
+		Buyer:     l.Buyer,
+		CreatedAt: l.CreatedAt,
+		ClosedAt:  l.ClosedAt,
+	}
+}
+
+func convertListings(listings []models.ListingDetails) []dto.Listing {
+	response := make([]dto.Listing, 0, len(listings))
+	for _, l := range listings {
+		response = append(response, convertListing(l))
+	}
+	return response
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type INotification interface {
+	List(userID int64, query dto.NotificationsQuery) (dto.NotificationsResponse, error)
+	MarkRead(userID int64, req dto.MarkNotificationsReadRequest) error
+	GetPreferences(userID int64) (dto.NotificationPreferences, error)
+	SetPreferences(userID int64, req dto.NotificationPreferences) error
+}
+
+type notification struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+}
+
+func newNotification(cfg *config.Config, log *logger.Logger, storage storage.IStorage) INotification {
+	return &notification{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+	}
+}
+
+// List returns the user's notifications, newest first, with the number of
+// unread ones.
+func (n *notification) List(userID int64, query dto.NotificationsQuery) (dto.NotificationsResponse, error) {
+	const op = "service.notification.List"
+
+	notifications, err := n.storage.Notification().GetUserNotifications(userID, storage.NotificationFilter{
+		UnreadOnly: query.Unread,
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+	})
+	if err != nil {
+		n.log.Error("failed to get notifications:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.NotificationsResponse{}, errors.ErrInternal(err)
+	}
+
+	unread, err := n.storage.Notification().CountUnread(userID)
+	if err != nil {
+		n.log.Error("failed to count unread notifications:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.NotificationsResponse{}, errors.ErrInternal(err)
+	}
+
+	response := dto.NotificationsResponse{
+		UnreadCount:   unread,
+		Notifications: make([]dto.Notification, 0, len(notifications)),
+	}
+	for _, m := range notifications {
+		response.Notifications = append(response.Notifications, dto.Notification{
+			ID:        m.ID,
+			Type:      string(m.Type),
+			Message:   m.Message,
+			CreatedAt: m.CreatedAt,
+			ReadAt:    m.ReadAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (n *notification) MarkRead(userID int64, req dto.MarkNotificationsReadRequest) error {
+	const op = "service.notification.MarkRead"
+
+	if req.All == (len(req.IDs) > 0) {
+		return errors.ErrBadRequest("either ids or all must be set")
+	}
+
+	if _, err := n.storage.Notification().MarkRead(userID, req.IDs); err != nil {
+		n.log.Error("failed to mark notifications as read:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+func (n *notification) GetPreferences(userID int64) (dto.NotificationPreferences, error) {
+	const op = "service.notification.GetPreferences"
+
+	types, err := n.storage.Notification().GetMutedTypes(userID)
+	if err != nil {
+		n.log.Error("failed to get muted notification types:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.NotificationPreferences{}, errors.ErrInternal(err)
+	}
+
+	response := dto.NotificationPreferences{Muted: make([]string, 0, len(types))}
+	for _, t := range types {
+		response.Muted = append(response.Muted, string(t))
+	}
+
+	return response, nil
+}
+
+// SetPreferences replaces the notification types the user muted. Muted
+// notifications are not stored at all.
+func (n *notification) SetPreferences(userID int64, req dto.NotificationPreferences) error {
+	const op = "service.notification.SetPreferences"
+
+	types := make([]models.NotificationType, 0, len(req.Muted))
+	for _, t := range req.Muted {
+		types = append(types, models.NotificationType(t))
+	}
+
+	if err := n.storage.Notification().SetMutedTypes(userID, types); err != nil {
+		n.log.Error("failed to set muted notification types:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// notify stores a notification for its user. Notifications are a side effect
+// of the operation that produced them: a failure is logged and never fails
+// the operation itself.
+func notify(log *logger.Logger, storage storage.IStorage, op string, notification models.Notification) {
+	if _, err := storage.Notification().Create(notification); err != nil {
+		log.Error("failed to create notification:",
+			zap.String("method", op),
+			zap.Int64("user_id", notification.UserID),
+			zap.String("type", string(notification.Type)),
+			zap.Error(err),
+		)
+	}
+}
+
+// withMemo appends the sender's memo to a notification message.
+func withMemo(message, memo string) string {
+	if memo == "" {
+		return message
+	}
+	return message + ": " + memo
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultOrdersLimit = 50
+
+// orderTransitions lists the status an order must be in before it can move to
+// the given status. Cancellation is handled separately because it refunds.
+var orderTransitions = map[models.OrderStatus]models.OrderStatus{
+	models.OrderStatusReadyForPickup: models.OrderStatusPlaced,
+	models.OrderStatusHandedOver:     models.OrderStatusReadyForPickup,
+}
+
+type IOrder interface {
+	List(userID int64) ([]dto.Order, error)
+	GetOrders(query dto.OrdersQuery) ([]dto.Order, error)
+	Cancel(userID, orderID int64) error
+	UpdateStatus(actorID, orderID int64, req dto.OrderStatusRequest) error
+}
+
+type order struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newOrder(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IOrder {
+	return &order{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+func (o *order) List(userID int64) ([]dto.Order, error) {
+	const op = "service.order.List"
+
+	orders, err := o.storage.Order().GetUserOrders(userID)
+	if err != nil {
+		o.log.Error("failed to get orders:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertOrders(orders), nil
+}
+
+// GetOrders returns the shop's order queue. Without a status filter it shows
+// orders that still need to be prepared.
+func (o *order) GetOrders(query dto.OrdersQuery) ([]dto.Order, error) {
+	const op = "service.order.GetOrders"
+
+	status := models.OrderStatus(query.Status)
+	if status == "" {
+		status = models.OrderStatusPlaced
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultOrdersLimit
+	}
+
+	orders, err := o.storage.Order().GetOrders(status, limit, query.Offset)
+	if err != nil {
+		o.log.Error("failed to get orders:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertOrders(orders), nil
+}
+
+// Cancel lets the buyer cancel an order that has not been handed over yet.
+func (o *order) Cancel(userID, orderID int64) error {
+	const op = "service.order.Cancel"
+
+	current, err := o.storage.Order().GetByID(orderID)
+	if err != nil {
+		return o.storageError(op, err)
+	}
+
+	if current.UserID != userID {
+		return errors.ErrNotFound("order not found")
+	}
+
+	return o.cancel(op, userID, orderID)
+}
+
+// UpdateStatus moves an order forward in its lifecycle on behalf of a shop
+// manager: placed -> ready_for_pickup -> handed_over. Orders that were not
+// handed over can also be cancelled, which refunds the buyer.
+func (o *order) UpdateStatus(actorID, orderID int64, req dto.OrderStatusRequest) error {
+	const op = "service.order.UpdateStatus"
+
+	to := models.OrderStatus(req.Status)
+	if to == models.OrderStatusCancelled {
+		return o.cancel(op, actorID, orderID)
+	}
+
+	from, ok := orderTransitions[to]
+	if !ok {
+		return errors.ErrBadRequest("unsupported order status")
+	}
+
+	if err := o.storage.Order().UpdateStatus(orderID, from, to); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return errors.ErrBadRequest("order not found or not in status " + string(from))
+		}
+		return o.storageError(op, err)
+	}
+
+	o.events.order(op, orderID)
+	if to == models.OrderStatusReadyForPickup {
+		o.emailReady(op, orderID)
+	}
+	return nil
+}
+
+// emailReady tells the owner their order can be picked up.
+func (o *order) emailReady(op string, orderID int64) {
+	details, err := o.storage.Order().GetByID(orderID)
+	if err != nil {
+		o.log.Error("failed to get order for email:",
+			zap.String("method", op),
+			zap.Int64("order_id", orderID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	queueEmail(o.cfg, o.log, o.storage, op, details.UserID, email.TemplateOrderReady, email.OrderReadyData{
+		Username: details.Username,
+		OrderID:  details.ID,
+		Item:     itemLabel(details.MerchName, details.SKU),
+	})
+}
+
+func (o *order) cancel(op string, actorID, orderID int64) error {
+	refund, err := o.storage.Order().Cancel(orderID, actorID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return errors.ErrBadRequest("order not found or already handed over or cancelled")
+		}
+		return o.storageError(op, err)
+	}
+
+	o.events.order(op, orderID)
+	o.events.balance(op, refund.ToUserID)
+	return nil
+}
+
+func (o *order) storageError(op string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return errors.ErrNotFound("order not found")
+	case errors.Is(err, storage.ErrConflict):
+		return errors.ErrBadRequest("the item is no longer in the inventory")
+	default:
+		o.log.Error("failed to process order:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+}
+
+func convertOrders(orders []models.OrderDetails) []dto.Order {
+	response := make([]dto.Order, 0, len(orders))
+	for _, o := range orders {
+		response = append(response, dto.Order{
+			ID:                  o.ID,
+			PurchaseID:          o.PurchaseTransactionID,
+			User:                o.Username,
+			Item:                o.MerchName,
+			SKU:                 o.SKU,
+			Size:                o.Size,
+			Color:               o.Color,
+			Amount:              o.Amount,
+			Status:              string(o.Status),
+			RefundTransactionID: o.RefundTransactionID,
+			CreatedAt:           o.CreatedAt,
+			UpdatedAt:           o.UpdatedAt,
+		})
+	}
+	return response
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultPaymentRequestTTL = 7 * 24 * time.Hour
+
+type IPaymentRequest interface {
+	Create(requesterID int64, req dto.CreatePaymentRequest) (dto.PaymentRequest, error)
+	List(userID int64) (dto.PaymentRequests, error)
+	Accept(userID, requestID int64) (dto.SendCoinResponse, error)
+	Decline(userID, requestID int64) error
+	Cancel(userID, requestID int64) error
+	ExpireDue() error
+}
+
+type paymentRequest struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newPaymentRequest(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IPaymentRequest {
+	return &paymentRequest{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+func (p *paymentRequest) Create(requesterID int64, req dto.CreatePaymentRequest) (dto.PaymentRequest, error) {
+	const op = "service.paymentRequest.Create"
+
+	payer, err := p.storage.User().GetUserByUsername(req.FromUser)
+	if err == nil && payer.Role == models.RoleSystem {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		p.log.Error("payer not found:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.PaymentRequest{}, errors.ErrNotFound("payer not found")
+	}
+
+	if payer.ID == requesterID {
+		return dto.PaymentRequest{}, errors.ErrBadRequest("cannot request coins from yourself")
+	}
+
+	requester, err := p.storage.User().GetUserByID(requesterID)
+	if err != nil {
+		p.log.Error("failed to get requester:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.PaymentRequest{}, errors.ErrInternal(err)
+	}
+
+	ttl := p.cfg.Settings.Service.PaymentRequestTTL
+	if ttl <= 0 {
+		ttl = defaultPaymentRequestTTL
+	}
+
+	request, err := p.storage.PaymentRequest().Create(models.PaymentRequest{
+		RequesterID: requesterID,
+		PayerID:     payer.ID,
+		Amount:      req.Amount,
+		Memo:        req.Memo,
+		ExpiresAt:   time.Now().Add(ttl),
+	})
+	if err != nil {
+		p.log.Error("failed to create payment request:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.PaymentRequest{}, errors.ErrInternal(err)
+	}
+
+	return convertPaymentRequest(models.PaymentRequestDetails{
+		PaymentRequest:    request,
+		RequesterUsername: requester.Username,
+		PayerUsername:     payer.Username,
+	}), nil
+}
+
+func (p *paymentRequest) List(userID int64) (dto.PaymentRequests, error) {
+	const op = "service.paymentRequest.List"
+
+	requests, err := p.storage.PaymentRequest().GetUserRequests(userID)
+	if err != nil {
+		p.log.Error("failed to get payment requests:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.PaymentRequests{}, errors.ErrInternal(err)
+	}
+
+	response := dto.PaymentRequests{
+		Incoming: make([]dto.PaymentRequest, 0),
+		Outgoing: make([]dto.PaymentRequest, 0),
+	}
+
+	for _, request := range requests {
+		if request.PayerID == userID {
+			response.Incoming = append(response.Incoming, convertPaymentRequest(request))
+		} else {
+			response.Outgoing = append(response.Outgoing, convertPaymentRequest(request))
+		}
+	}
+
+	return response, nil
+}
+
+// Accept pays the request like a direct transfer: payments above the approval
+// threshold wait for a manager or treasurer.
+func (p *paymentRequest) Accept(userID, requestID int64) (dto.SendCoinResponse, error) {
+	const op = "service.paymentRequest.Accept"
+
+	payer, err := p.storage.User().GetUserByID(userID)
+	if err != nil {
+		p.log.Error("failed to get payer:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	accepted, err := p.storage.PaymentRequest().Accept(
+		requestID, userID, transferLimits(p.cfg, payer.Role), p.cfg.Settings.Service.ApprovalThreshold,
+	)
+	switch {
+	case err == nil:
+	case limitError(err) != nil:
+		return dto.SendCoinResponse{}, limitError(err)
+	case errors.Is(err, storage.ErrNotFound):
+		return dto.SendCoinResponse{}, errors.ErrNotFound("payment request not found")
+	case errors.Is(err, storage.ErrExpired):
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("payment request has expired")
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return dto.SendCoinResponse{}, errors.ErrBadRequest("insufficient funds")
+	default:
+		p.log.Error("failed to accept payment request:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SendCoinResponse{}, errors.ErrInternal(err)
+	}
+
+	if accepted.PendingTransferID != nil {
+		p.events.balance(op, accepted.PayerID)
+		return dto.SendCoinResponse{
+			Status:            sendStatusPendingApproval,
+			PendingTransferID: *accepted.PendingTransferID,
+		}, nil
+	}
+
+	p.events.balance(op, accepted.PayerID, accepted.RequesterID)
+	p.events.transfer(models.Transaction{
+		ToUserID: accepted.RequesterID,
+		Amount:   accepted.Amount,
+		Memo:     accepted.Memo,
+	}, payer.Username)
+
+	requester, err := p.storage.User().GetUserByID(accepted.RequesterID)
+	if err != nil {
+		p.log.Error("failed to get requester:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SendCoinResponse{Status: sendStatusCompleted}, nil
+	}
+	notify(p.log, p.storage, op, models.Notification{
+		UserID:  requester.ID,
+		Type:    models.NotificationTypeCoinsReceived,
+		Message: withMemo(fmt.Sprintf("%s paid your request for %d coins", payer.Username, accepted.Amount), accepted.Memo),
+	})
+	queueEmail(p.cfg, p.log, p.storage, op, requester.ID, email.TemplateCoinsReceived, email.CoinsReceivedData{
+		Username: requester.Username,
+		From:     payer.Username,
+		Amount:   accepted.Amount,
+		Memo:     accepted.Memo,
+	})
+
+	return dto.SendCoinResponse{Status: sendStatusCompleted}, nil
+}
+
+func (p *paymentRequest) Decline(userID, requestID int64) error {
+	const op = "service.paymentRequest.Decline"
+
+	return p.resolveError(op, p.storage.PaymentRequest().Decline(requestID, userID))
+}
+
+func (p *paymentRequest) Cancel(userID, requestID int64) error {
+	const op = "service.paymentRequest.Cancel"
+
+	return p.resolveError(op, p.storage.PaymentRequest().Cancel(requestID, userID))
+}
+
+func (p *paymentRequest) resolveError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("pending payment request not found")
+	}
+
+	p.log.Error("failed to resolve payment request:",
+		zap.String("method", op),
+		zap.Error(err),
+	)
+	return errors.ErrInternal(err)
+}
+
+func convertPaymentRequest(request models.PaymentRequestDetails) dto.PaymentRequest {
+	return dto.PaymentRequest{
+		ID:        request.ID,
+		FromUser:  request.PayerUsername,
+		ToUser:    request.RequesterUsername,
+		Amount:    request.Amount,
+		Memo:      request.Memo,
+		Status:    string(request.Status),
+		CreatedAt: request.CreatedAt,
+		ExpiresAt: request.ExpiresAt,
+	}
+}
+
+// ExpireDue stores the expired status of pending requests past their deadline.
+func (p *paymentRequest) ExpireDue() error {
+	const op = "service.paymentRequest.ExpireDue"
+
+	expired, err := p.storage.PaymentRequest().ExpireDue()
+	if err != nil {
+		p.log.Error("failed to expire payment requests:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if expired > 0 {
+		p.log.Info("payment requests expired",
+			zap.String("method", op),
+			zap.Int64("requests", expired),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IPreorder interface {
+	Create(userID int64, req dto.PreorderRequest) (dto.Preorder, error)
+	List(userID int64) ([]dto.Preorder, error)
+	Cancel(userID, preorderID int64) error
+	CancelDrop(itemName string) error
+	FulfilDue() error
+}
+
+type preorder struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newPreorder(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IPreorder {
+	return &preorder{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+// Create pre-orders an announced item. The price is reserved right away and
+// charged when the item goes on sale.
+func (p *preorder) Create(userID int64, req dto.PreorderRequest) (dto.Preorder, error) {
+	const op = "service.preorder.Create"
+
+	item, err := p.storage.Inventory().GetMerchByName(req.Item)
+	if errors.Is(err, storage.ErrNotFound) {
+		return dto.Preorder{}, errors.ErrBadRequest("invalid item name")
+	}
+	if err != nil {
+		p.log.Error("failed to get item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Preorder{}, errors.ErrInternal(err)
+	}
+	if !item.AcceptsPreorders(time.Now()) {
+		return dto.Preorder{}, errors.ErrBadRequest("item does not take pre-orders")
+	}
+
+	variantID, err := resolveVariant(p.log, p.storage, op, item, req.SKU)
+	if err != nil {
+		return dto.Preorder{}, err
+	}
+
+	created, err := p.storage.Preorder().Create(models.Preorder{
+		UserID:    userID,
+		MerchID:   item.ID,
+		VariantID: variantID,
+	})
+	switch {
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return dto.Preorder{}, errors.ErrBadRequest("insufficient funds")
+	case errors.Is(err, storage.ErrNotAvailable):
+		return dto.Preorder{}, errors.ErrBadRequest("item does not take pre-orders")
+	case errors.Is(err, storage.ErrPurchaseLimit):
+		return dto.Preorder{}, errors.ErrBadRequest("purchase limit for this item reached")
+	case err != nil:
+		p.log.Error("failed to create pre-order:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Preorder{}, errors.ErrInternal(err)
+	}
+
+	p.events.balance(op, userID)
+
+	return convertPreorder(models.PreorderDetails{
+		Preorder:      created,
+		MerchName:     item.Name,
+		SKU:           req.SKU,
+		AvailableFrom: item.AvailableFrom,
+	}), nil
+}
+
+func (p *preorder) List(userID int64) ([]dto.Preorder, error) {
+	const op = "service.preorder.List"
+
+	preorders, err := p.storage.Preorder().GetUserPreorders(userID)
+	if err != nil {
+		p.log.Error("failed to get pre-orders:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.Preorder, 0, len(preorders))
+	for _, d := range preorders {
+		response = append(response, convertPreorder(d))
+	}
+
+	return response, nil
+}
+
+func (p *preorder) Cancel(userID, preorderID int64) error {
+	const op = "service.preorder.Cancel"
+
+	err := p.storage.Preorder().Cancel(preorderID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("pre-order not found or already resolved")
+	}
+	if err != nil {
+		p.log.Error("failed to cancel pre-order:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	p.events.balance(op, userID)
+
+	return nil
+}
+
+// CancelDrop calls off an item for good and refunds everyone who pre-ordered
+// it.
+func (p *preorder) CancelDrop(itemName string) error {
+	const op = "service.preorder.CancelDrop"
+
+	item, err := p.storage.Inventory().GetMerchByName(itemName)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("item not found")
+	}
+	if err != nil {
+		p.log.Error("failed to get item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	refunds, err := p.storage.Preorder().CancelDrop(item.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrBadRequest("item drop is already cancelled")
+	}
+	if err != nil {
+		p.log.Error("failed to cancel drop:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	for _, refund := range refunds {
+		p.events.balance(op, refund.UserID)
+	}
+
+	p.log.Info("item drop cancelled",
+		zap.String("method", op),
+		zap.String("item", itemName),
+		zap.Int("refunded", len(refunds)),
+	)
+
+	return nil
+}
+
+// FulfilDue turns the pre-orders of items that went on sale into purchases.
+// Pre-orders that find the item sold out are refunded. A failing pre-order
+// does not stop the others; it is retried on the next run.
+func (p *preorder) FulfilDue() error {
+	const op = "service.preorder.FulfilDue"
+
+	ids, err := p.storage.Preorder().GetDue(time.Now())
+	if err != nil {
+		p.log.Error("failed to get due pre-orders:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	var fulfilled, refunded int
+	for _, id := range ids {
+		resolved, err := p.storage.Preorder().Fulfil(id)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				p.log.Error("failed to fulfil pre-order:",
+					zap.String("method", op),
+					zap.Int64("preorder_id", id),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+
+		p.events.balance(op, resolved.UserID)
+
+		if resolved.Status == models.PreorderStatusFulfilled {
+			fulfilled++
+		} else {
+			refunded++
+		}
+	}
+
+	if fulfilled > 0 || refunded > 0 {
+		p.log.Info("pre-orders processed",
+			zap.String("method", op),
+			zap.Int("fulfilled", fulfilled),
+			zap.Int("refunded", refunded),
+		)
+	}
+
+	return nil
+}
+
+func convertPreorder(d models.PreorderDetails) dto.Preorder {
+	return dto.Preorder{
+		ID:            d.ID,
+		Item:          d.MerchName,
+		SKU:           d.SKU,
+		Amount:        d.Amount,
+		Status:        string(d.Status),
+		PurchaseID:    d.PurchaseTransactionID,
+		AvailableFrom: d.AvailableFrom,
+		CreatedAt:     d.CreatedAt,
+		ResolvedAt:    d.ResolvedAt,
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IPromotion interface {
+	Create(actorID int64, req dto.PromotionRequest) (dto.Promotion, error)
+	List() ([]dto.Promotion, error)
+	GetActive() ([]dto.Promotion, error)
+	End(promotionID int64) error
+}
+
+type promotion struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+}
+
+func newPromotion(cfg *config.Config, log *logger.Logger, storage storage.IStorage) IPromotion {
+	return &promotion{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+	}
+}
+
+// Create sets up a discount rule. Codes are case-insensitive and stored in
+// upper case; a promotion without a start time starts right away.
+func (p *promotion) Create(actorID int64, req dto.PromotionRequest) (dto.Promotion, error) {
+	const op = "service.promotion.Create"
+
+	if req.Kind == string(models.PromotionKindPercent) && req.Value > 100 {
+		return dto.Promotion{}, errors.ErrBadRequest("percentage discount cannot exceed 100")
+	}
+
+	startsAt := time.Now()
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		return dto.Promotion{}, errors.ErrBadRequest("promotion must end after it starts")
+	}
+
+	var merchID *int64
+	if req.Item != "" {
+		merch, err := p.storage.Inventory().GetMerchByName(req.Item)
+		if errors.Is(err, storage.ErrNotFound) {
+			return dto.Promotion{}, errors.ErrBadRequest("invalid item name")
+		}
+		if err != nil {
+			p.log.Error("failed to get merch:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return dto.Promotion{}, errors.ErrInternal(err)
+		}
+		merchID = &merch.ID
+	}
+
+	created, err := p.storage.Promotion().Create(models.Promotion{
+		Name:         req.Name,
+		Code:         strings.ToUpper(req.Code),
+		Kind:         models.PromotionKind(req.Kind),
+		Value:        req.Value,
+		MerchID:      merchID,
+		Category:     req.Category,
+		StartsAt:     startsAt,
+		EndsAt:       req.EndsAt,
+		PerUserLimit: req.PerUserLimit,
+		MaxUses:      req.MaxUses,
+		CreatedBy:    actorID,
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		return dto.Promotion{}, errors.ErrBadRequest("promo code is already taken")
+	}
+	if err != nil {
+		p.log.Error("failed to create promotion:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Promotion{}, errors.ErrInternal(err)
+	}
+
+	created.MerchName = req.Item
+	return convertPromotion(created), nil
+}
+
+func (p *promotion) List() ([]dto.Promotion, error) {
+	const op = "service.promotion.List"
+
+	promotions, err := p.storage.Promotion().GetAll()
+	if err != nil {
+		p.log.Error("failed to get promotions:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertPromotions(promotions), nil
+}
+
+// GetActive lists the running promotions that apply without a code. Promo
+// codes are handed out privately and are never listed to users.
+func (p *promotion) GetActive() ([]dto.Promotion, error) {
+	const op = "service.promotion.GetActive"
+
+	promotions, err := p.storage.Promotion().GetActive()
+	if err != nil {
+		p.log.Error("failed to get active promotions:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertPromotions(promotions), nil
+}
+
+func (p *promotion) End(promotionID int64) error {
+	const op = "service.promotion.End"
+
+	err := p.storage.Promotion().End(promotionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("promotion not found or already ended")
+	}
+	if err != nil {
+		p.log.Error("failed to end promotion:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+func convertPromotions(promotions []models.Promotion) []dto.Promotion {
+	response := make([]dto.Promotion, 0, len(promotions))
+	for _, p := range promotions {
+		response = append(response, convertPromotion(p))
+	}
+	return response
+}
+
+func convertPromotion(p models.Promotion) dto.Promotion {
+	return dto.Promotion{
+		ID:           p.ID,
+		Name:         p.Name,
+		Code:         p.Code,
+		Kind:         string(p.Kind),
+		Value:        p.Value,
+		Item:         p.MerchName,
+		Category:     p.Category,
+		StartsAt:     p.StartsAt,
+		EndsAt:       p.EndsAt,
+		PerUserLimit: p.PerUserLimit,
+		MaxUses:      p.MaxUses,
+		Uses:         p.Uses,
+	}
+}
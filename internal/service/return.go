@@ -0,0 +1,162 @@
+package service
+
+import (
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IReturn interface {
+	Create(userID, purchaseID int64, req dto.ReturnRequest) (dto.Return, error)
+	List(userID int64) ([]dto.Return, error)
+	GetPending() ([]dto.Return, error)
+	Approve(actorID, returnID int64) error
+	Reject(actorID, returnID int64) error
+}
+
+type returns struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newReturn(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IReturn {
+	return &returns{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+// Create opens a return for a purchase within the return window. Unless shop
+// manager approval is required, the refund is issued right away.
+func (r *returns) Create(userID, purchaseID int64, req dto.ReturnRequest) (dto.Return, error) {
+	const op = "service.return.Create"
+
+	settings := r.cfg.Settings.Service
+	created, err := r.storage.Return().Create(models.Return{
+		UserID:                userID,
+		PurchaseTransactionID: purchaseID,
+		Reason:                req.Reason,
+	}, time.Now().Add(-settings.ReturnWindow), !settings.ReturnRequiresApproval)
+	if err != nil {
+		return dto.Return{}, r.storageError(op, err)
+	}
+	if created.Status == models.ReturnStatusApproved {
+		r.events.balance(op, userID)
+	}
+
+	details, err := r.storage.Return().GetByID(created.ID)
+	if err != nil {
+		r.log.Error("failed to get return:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Return{}, errors.ErrInternal(err)
+	}
+
+	return convertReturn(details), nil
+}
+
+func (r *returns) List(userID int64) ([]dto.Return, error) {
+	const op = "service.return.List"
+
+	returns, err := r.storage.Return().GetUserReturns(userID)
+	if err != nil {
+		r.log.Error("failed to get returns:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertReturns(returns), nil
+}
+
+func (r *returns) GetPending() ([]dto.Return, error) {
+	const op = "service.return.GetPending"
+
+	returns, err := r.storage.Return().GetPending()
+	if err != nil {
+		r.log.Error("failed to get pending returns:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	return convertReturns(returns), nil
+}
+
+func (r *returns) Approve(actorID, returnID int64) error {
+	const op = "service.return.Approve"
+
+	refund, err := r.storage.Return().Approve(returnID, actorID)
+	if err != nil {
+		return r.storageError(op, err)
+	}
+
+	r.events.balance(op, refund.ToUserID)
+
+	return nil
+}
+
+func (r *returns) Reject(actorID, returnID int64) error {
+	const op = "service.return.Reject"
+
+	if err := r.storage.Return().Reject(returnID, actorID); err != nil {
+		return r.storageError(op, err)
+	}
+
+	return nil
+}
+
+func (r *returns) storageError(op string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return errors.ErrNotFound("purchase or pending return not found")
+	case errors.Is(err, storage.ErrExpired):
+		return errors.ErrBadRequest("the return window for this purchase has closed")
+	case errors.Is(err, storage.ErrConflict):
+		return errors.ErrBadRequest(
+			"purchase is already returned, not handed over yet or the item is no longer in the inventory")
+	default:
+		r.log.Error("failed to process return:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+}
+
+func convertReturns(returns []models.ReturnDetails) []dto.Return {
+	response := make([]dto.Return, 0, len(returns))
+	for _, ret := range returns {
+		response = append(response, convertReturn(ret))
+	}
+	return response
+}
+
+func convertReturn(ret models.ReturnDetails) dto.Return {
+	return dto.Return{
+		ID:                  ret.ID,
+		PurchaseID:          ret.PurchaseTransactionID,
+		User:                ret.Username,
+		Item:                ret.MerchName,
+		SKU:                 ret.SKU,
+		Amount:              ret.Amount,
+		Reason:              ret.Reason,
+		Status:              string(ret.Status),
+		RefundTransactionID: ret.RefundTransactionID,
+		CreatedAt:           ret.CreatedAt,
+		ResolvedAt:          ret.ResolvedAt,
+	}
+}
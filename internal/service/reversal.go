@@ -0,0 +1,222 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type IReversal interface {
+	Open(userID, transactionID int64, req dto.ReversalRequest) (dto.Reversal, error)
+	List(userID int64) (dto.Reversals, error)
+	GetPending() ([]dto.Reversal, error)
+	Approve(actorID int64, role models.Role, reversalID int64) error
+	Reject(actorID int64, role models.Role, reversalID int64) error
+}
+
+type reversal struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newReversal(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) IReversal {
+	return &reversal{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+func (r *reversal) Open(userID, transactionID int64, req dto.ReversalRequest) (dto.Reversal, error) {
+	const op = "service.reversal.Open"
+
+	created, err := r.storage.Reversal().Create(transactionID, userID, req.Reason)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return dto.Reversal{}, errors.ErrNotFound("transfer sent by you not found")
+	case errors.Is(err, storage.ErrConflict):
+		return dto.Reversal{}, errors.ErrBadRequest("transfer already has an open or approved reversal")
+	case err != nil:
+		r.log.Error("failed to create reversal:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Reversal{}, errors.ErrInternal(err)
+	}
+
+	details, err := r.storage.Reversal().GetByID(created.ID)
+	if err != nil {
+		r.log.Error("failed to get reversal:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Reversal{}, errors.ErrInternal(err)
+	}
+
+	return convertReversal(details), nil
+}
+
+func (r *reversal) List(userID int64) (dto.Reversals, error) {
+	const op = "service.reversal.List"
+
+	reversals, err := r.storage.Reversal().GetUserReversals(userID)
+	if err != nil {
+		r.log.Error("failed to get reversals:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Reversals{}, errors.ErrInternal(err)
+	}
+
+	response := dto.Reversals{
+		Opened:   make([]dto.Reversal, 0),
+		Received: make([]dto.Reversal, 0),
+	}
+
+	for _, reversal := range reversals {
+		if reversal.SenderID == userID {
+			response.Opened = append(response.Opened, convertReversal(reversal))
+		} else {
+			response.Received = append(response.Received, convertReversal(reversal))
+		}
+	}
+
+	return response, nil
+}
+
+func (r *reversal) GetPending() ([]dto.Reversal, error) {
+	const op = "service.reversal.GetPending"
+
+	reversals, err := r.storage.Reversal().GetPending()
+	if err != nil {
+		r.log.Error("failed to get pending reversals:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.Reversal, 0, len(reversals))
+	for _, reversal := range reversals {
+		response = append(response, convertReversal(reversal))
+	}
+
+	return response, nil
+}
+
+func (r *reversal) Approve(actorID int64, role models.Role, reversalID int64) error {
+	const op = "service.reversal.Approve"
+
+	if err := r.authorize(op, actorID, role, reversalID); err != nil {
+		return err
+	}
+
+	reversed, err := r.storage.Reversal().Approve(reversalID, actorID)
+	switch {
+	case err == nil:
+		r.events.balance(op, reversed.FromUserID, reversed.ToUserID)
+		r.returned(op, reversed)
+		return nil
+	case errors.Is(err, storage.ErrNotFound):
+		return errors.ErrNotFound("pending reversal not found")
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return errors.ErrBadRequest("recipient no longer has enough coins to reverse the transfer")
+	default:
+		r.log.Error("failed to approve reversal:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+}
+
+// returned tells the original sender that the coins came back. A failed
+// lookup is logged and does not fail the committed reversal.
+func (r *reversal) returned(op string, reversed models.Transaction) {
+	recipient, err := r.storage.User().GetUserByID(reversed.FromUserID)
+	if err != nil {
+		r.log.Error("failed to get recipient:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return
+	}
+
+	notify(r.log, r.storage, op, models.Notification{
+		UserID:  reversed.ToUserID,
+		Type:    models.NotificationTypeCoinsReceived,
+		Message: fmt.Sprintf("%s returned %d coins from a reversed transfer", recipient.Username, reversed.Amount),
+	})
+}
+
+func (r *reversal) Reject(actorID int64, role models.Role, reversalID int64) error {
+	const op = "service.reversal.Reject"
+
+	if err := r.authorize(op, actorID, role, reversalID); err != nil {
+		return err
+	}
+
+	err := r.storage.Reversal().Reject(reversalID, actorID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("pending reversal not found")
+	}
+	if err != nil {
+		r.log.Error("failed to reject reversal:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// authorize lets the recipient of the original transfer or a treasurer
+// resolve a reversal. The sender who opened it cannot resolve it, even as a
+// treasurer.
+func (r *reversal) authorize(op string, actorID int64, role models.Role, reversalID int64) error {
+	reversal, err := r.storage.Reversal().GetByID(reversalID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("reversal not found")
+	}
+	if err != nil {
+		r.log.Error("failed to get reversal:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	if reversal.SenderID == actorID {
+		return errors.ErrForbidden("only the recipient or a treasurer can resolve a reversal")
+	}
+	if reversal.RecipientID != actorID && role != models.RoleTreasurer {
+		return errors.ErrNotFound("reversal not found")
+	}
+
+	return nil
+}
+
+func convertReversal(r models.TransferReversalDetails) dto.Reversal {
+	return dto.Reversal{
+		ID:                    r.ID,
+		TransactionID:         r.TransactionID,
+		FromUser:              r.SenderUsername,
+		ToUser:                r.RecipientUsername,
+		Amount:                r.Amount,
+		Reason:                r.Reason,
+		Status:                string(r.Status),
+		ReversalTransactionID: r.ReversalTransactionID,
+		CreatedAt:             r.CreatedAt,
+		ResolvedAt:            r.ResolvedAt,
+	}
+}
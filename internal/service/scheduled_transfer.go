@@ -0,0 +1,264 @@
+package service
+
+import (
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/cron"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const dueTransfersBatchSize = 100
+
+type IScheduledTransfer interface {
+	Create(userID int64, req dto.CreateScheduledTransfer) (dto.ScheduledTransfer, error)
+	List(userID int64) ([]dto.ScheduledTransfer, error)
+	Cancel(userID, transferID int64) error
+	GetRuns(userID, transferID int64) ([]dto.ScheduledTransferRun, error)
+	RunDue() error
+}
+
+type scheduledTransfer struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	coin    ICoin
+}
+
+func newScheduledTransfer(
+	cfg *config.Config,
+	log *logger.Logger,
+	storage storage.IStorage,
+	coin ICoin,
+) IScheduledTransfer {
+	return &scheduledTransfer{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		coin:    coin,
+	}
+}
+
+func (s *scheduledTransfer) Create(userID int64, req dto.CreateScheduledTransfer) (dto.ScheduledTransfer, error) {
+	const op = "service.scheduledTransfer.Create"
+
+	if (req.RunAt == nil) == (req.Schedule == "") {
+		return dto.ScheduledTransfer{}, errors.ErrBadRequest("exactly one of runAt and schedule is required")
+	}
+
+	var nextRunAt time.Time
+	if req.RunAt != nil {
+		if !req.RunAt.After(time.Now()) {
+			return dto.ScheduledTransfer{}, errors.ErrBadRequest("runAt must be in the future")
+		}
+		nextRunAt = *req.RunAt
+	} else {
+		schedule, err := cron.Parse(req.Schedule)
+		if err != nil {
+			return dto.ScheduledTransfer{}, errors.ErrBadRequest("invalid schedule: " + err.Error())
+		}
+		nextRunAt = schedule.Next(time.Now().UTC())
+		if nextRunAt.IsZero() {
+			return dto.ScheduledTransfer{}, errors.ErrBadRequest("schedule never fires")
+		}
+	}
+
+	toUser, err := s.storage.User().GetUserByUsername(req.ToUser)
+	if err == nil && toUser.Role == models.RoleSystem {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		s.log.Error("recipient not found:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.ScheduledTransfer{}, errors.ErrNotFound("recipient not found")
+	}
+
+	if toUser.ID == userID {
+		return dto.ScheduledTransfer{}, errors.ErrBadRequest("cannot send coins to yourself")
+	}
+
+	transfer, err := s.storage.ScheduledTransfer().Create(models.ScheduledTransfer{
+		FromUserID: userID,
+		ToUserID:   toUser.ID,
+		Amount:     req.Amount,
+		Memo:       req.Memo,
+		Category:   models.TransferCategory(req.Category),
+		Schedule:   req.Schedule,
+		NextRunAt:  &nextRunAt,
+	})
+	if err != nil {
+		s.log.Error("failed to create scheduled transfer:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.ScheduledTransfer{}, errors.ErrInternal(err)
+	}
+
+	return convertScheduledTransfer(models.ScheduledTransferDetails{
+		ScheduledTransfer: transfer,
+		ToUsername:        toUser.Username,
+	}), nil
+}
+
+func (s *scheduledTransfer) List(userID int64) ([]dto.ScheduledTransfer, error) {
+	const op = "service.scheduledTransfer.List"
+
+	transfers, err := s.storage.ScheduledTransfer().GetUserTransfers(userID)
+	if err != nil {
+		s.log.Error("failed to get scheduled transfers:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.ScheduledTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		response = append(response, convertScheduledTransfer(transfer))
+	}
+
+	return response, nil
+}
+
+func (s *scheduledTransfer) Cancel(userID, transferID int64) error {
+	const op = "service.scheduledTransfer.Cancel"
+
+	err := s.storage.ScheduledTransfer().Cancel(transferID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("active scheduled transfer not found")
+	}
+	if err != nil {
+		s.log.Error("failed to cancel scheduled transfer:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+func (s *scheduledTransfer) GetRuns(userID, transferID int64) ([]dto.ScheduledTransferRun, error) {
+	const op = "service.scheduledTransfer.GetRuns"
+
+	runs, err := s.storage.ScheduledTransfer().GetRuns(transferID, userID)
+	if err != nil {
+		s.log.Error("failed to get scheduled transfer runs:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.ScheduledTransferRun, 0, len(runs))
+	for _, run := range runs {
+		response = append(response, dto.ScheduledTransferRun{
+			Status:       string(run.Status),
+			Error:        run.Error,
+			ScheduledFor: run.ScheduledFor,
+			CreatedAt:    run.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+// RunDue executes every transfer whose time has come through coin.Send, so
+// scheduled transfers obey the same checks as manual ones. Each attempt is
+// recorded as a run, including failures such as insufficient funds and
+// transfers held for approval.
+func (s *scheduledTransfer) RunDue() error {
+	const op = "service.scheduledTransfer.RunDue"
+
+	now := time.Now().UTC()
+
+	due, err := s.storage.ScheduledTransfer().GetDue(now, dueTransfersBatchSize)
+	if err != nil {
+		s.log.Error("failed to get due transfers:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	for _, transfer := range due {
+		if err := s.run(transfer, now); err != nil {
+			s.log.Error("failed to run scheduled transfer:",
+				zap.String("method", op),
+				zap.Int64("scheduled_transfer_id", transfer.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (s *scheduledTransfer) run(transfer models.ScheduledTransferDetails, now time.Time) error {
+	runAt := *transfer.NextRunAt
+
+	// Recurring transfers that were missed while no worker was running are
+	// executed once and then move on to the next future activation.
+	var nextRunAt *time.Time
+	if transfer.Schedule != "" {
+		schedule, err := cron.Parse(transfer.Schedule)
+		if err != nil {
+			return err
+		}
+		if next := schedule.Next(now); !next.IsZero() {
+			nextRunAt = &next
+		}
+	}
+
+	run, err := s.storage.ScheduledTransfer().Claim(transfer.ID, runAt, nextRunAt)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.coin.Send(transfer.FromUserID, dto.SendCoinRequest{
+		ToUser:   transfer.ToUsername,
+		Amount:   transfer.Amount,
+		Memo:     transfer.Memo,
+		Category: string(transfer.Category),
+	})
+	switch {
+	case err != nil:
+		run.Status = models.ScheduledTransferRunStatusFailed
+		run.Error = err.Error()
+
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) {
+			run.Error = appErr.Message
+		}
+	case resp.Status == sendStatusPendingApproval:
+		run.Status = models.ScheduledTransferRunStatusPendingApproval
+	default:
+		run.Status = models.ScheduledTransferRunStatusSucceeded
+	}
+
+	return s.storage.ScheduledTransfer().FinishRun(run)
+}
+
+func convertScheduledTransfer(transfer models.ScheduledTransferDetails) dto.ScheduledTransfer {
+	return dto.ScheduledTransfer{
+		ID:        transfer.ID,
+		ToUser:    transfer.ToUsername,
+		Amount:    transfer.Amount,
+		Memo:      transfer.Memo,
+		Category:  string(transfer.Category),
+		Schedule:  transfer.Schedule,
+		NextRunAt: transfer.NextRunAt,
+		Status:    string(transfer.Status),
+		CreatedAt: transfer.CreatedAt,
+	}
+}
@@ -2,9 +2,11 @@ package service
 
 import (
 	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/email"
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/icoder-new/avito-shop/pkg/jwt"
 	"github.com/icoder-new/avito-shop/pkg/logger"
+	"github.com/icoder-new/avito-shop/pkg/pubsub"
 )
 
 type IService interface {
@@ -12,21 +14,94 @@ type IService interface {
 	User() IUser
 	Coin() ICoin
 	Inventory() IInventory
+	History() IHistory
+	PaymentRequest() IPaymentRequest
+	ScheduledTransfer() IScheduledTransfer
+	Treasury() ITreasury
+	Reversal() IReversal
+	Approval() IApproval
+	Expiry() IExpiry
+	Snapshot() ISnapshot
+	Team() ITeam
+	Allowance() IAllowance
+	Return() IReturn
+	Order() IOrder
+	Promotion() IPromotion
+	Preorder() IPreorder
+	Marketplace() IMarketplace
+	Wishlist() IWishlist
+	Notification() INotification
+	Stream() IStream
+	Webhook() IWebhook
+	Email() IEmail
+	Slack() ISlack
 }
 
 type service struct {
-	auth      IAuth
-	user      IUser
-	coin      ICoin
-	inventory IInventory
+	auth              IAuth
+	user              IUser
+	coin              ICoin
+	inventory         IInventory
+	history           IHistory
+	paymentRequest    IPaymentRequest
+	scheduledTransfer IScheduledTransfer
+	treasury          ITreasury
+	reversal          IReversal
+	approval          IApproval
+	expiry            IExpiry
+	snapshot          ISnapshot
+	team              ITeam
+	allowance         IAllowance
+	ret               IReturn
+	order             IOrder
+	promotion         IPromotion
+	preorder          IPreorder
+	marketplace       IMarketplace
+	wishlist          IWishlist
+	notification      INotification
+	stream            IStream
+	webhook           IWebhook
+	email             IEmail
+	slack             ISlack
 }
 
-func NewService(cfg *config.Config, log *logger.Logger, storage storage.IStorage, manager *jwt.TokenManager) IService {
+func NewService(
+	cfg *config.Config,
+	log *logger.Logger,
+	storage storage.IStorage,
+	manager *jwt.TokenManager,
+	broker *pubsub.Broker,
+	sender email.Sender,
+) IService {
+	events := newEvents(log, storage, broker)
+	coin := newCoin(cfg, log, storage, events)
+
 	return &service{
-		auth:      newAuth(cfg, log, storage, manager),
-		user:      newUser(cfg, log, storage),
-		coin:      newCoin(cfg, log, storage),
-		inventory: newInventory(cfg, log, storage),
+		auth:              newAuth(cfg, log, storage, manager),
+		user:              newUser(cfg, log, storage),
+		coin:              coin,
+		inventory:         newInventory(cfg, log, storage, events),
+		history:           newHistory(cfg, log, storage),
+		paymentRequest:    newPaymentRequest(cfg, log, storage, events),
+		scheduledTransfer: newScheduledTransfer(cfg, log, storage, coin),
+		treasury:          newTreasury(cfg, log, storage, events),
+		reversal:          newReversal(cfg, log, storage, events),
+		approval:          newApproval(cfg, log, storage, events),
+		expiry:            newExpiry(cfg, log, storage, events),
+		snapshot:          newSnapshot(cfg, log, storage),
+		team:              newTeam(cfg, log, storage, events),
+		allowance:         newAllowance(cfg, log, storage, events),
+		ret:               newReturn(cfg, log, storage, events),
+		order:             newOrder(cfg, log, storage, events),
+		promotion:         newPromotion(cfg, log, storage),
+		preorder:          newPreorder(cfg, log, storage, events),
+		marketplace:       newMarketplace(cfg, log, storage, events),
+		wishlist:          newWishlist(cfg, log, storage),
+		notification:      newNotification(cfg, log, storage),
+		stream:            newStream(broker),
+		webhook:           newWebhook(cfg, log, storage),
+		email:             newEmail(cfg, log, storage, sender),
+		slack:             newSlack(cfg, log, storage, coin),
 	}
 }
 
@@ -45,3 +120,87 @@ func (s *service) Coin() ICoin {
 func (s *service) Inventory() IInventory {
 	return s.inventory
 }
+
+func (s *service) History() IHistory {
+	return s.history
+}
+
+func (s *service) PaymentRequest() IPaymentRequest {
+	return s.paymentRequest
+}
+
+func (s *service) ScheduledTransfer() IScheduledTransfer {
+	return s.scheduledTransfer
+}
+
+func (s *service) Treasury() ITreasury {
+	return s.treasury
+}
+
+func (s *service) Reversal() IReversal {
+	return s.reversal
+}
+
+func (s *service) Approval() IApproval {
+	return s.approval
+}
+
+func (s *service) Expiry() IExpiry {
+	return s.expiry
+}
+
+func (s *service) Snapshot() ISnapshot {
+	return s.snapshot
+}
+
+func (s *service) Team() ITeam {
+	return s.team
+}
+
+func (s *service) Allowance() IAllowance {
+	return s.allowance
+}
+
+func (s *service) Return() IReturn {
+	return s.ret
+}
+
+func (s *service) Order() IOrder {
+	return s.order
+}
+
+func (s *service) Promotion() IPromotion {
+	return s.promotion
+}
+
+func (s *service) Preorder() IPreorder {
+	return s.preorder
+}
+
+func (s *service) Marketplace() IMarketplace {
+	return s.marketplace
+}
+
+func (s *service) Wishlist() IWishlist {
+	return s.wishlist
+}
+
+func (s *service) Notification() INotification {
+	return s.notification
+}
+
+func (s *service) Stream() IStream {
+	return s.stream
+}
+
+func (s *service) Webhook() IWebhook {
+	return s.webhook
+}
+
+func (s *service) Email() IEmail {
+	return s.email
+}
+
+func (s *service) Slack() ISlack {
+	return s.slack
+}
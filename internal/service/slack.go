@@ -0,0 +1,240 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	slackResponseEphemeral = "ephemeral"
+	slackResponseInChannel = "in_channel"
+
+	slackLinkCodeLength     = 8
+	slackLinkCodeAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	slackMemoMaxLength      = 140
+	defaultSlackLinkCodeTTL = 10 * time.Minute
+
+	slackUsage = "Usage:\n" +
+		"• `/coins @user 50 thanks for the help` sends coins\n" +
+		"• `/coins link CODE` links your account with a code from POST /api/slack/link\n" +
+		"• `/coins unlink` removes the link"
+)
+
+// slackMention matches an escaped user mention such as <@U024BE7LH|bob>.
+var slackMention = regexp.MustCompile(`^<@([A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+type ISlack interface {
+	CreateLinkCode(userID int64) (dto.SlackLinkCode, error)
+	HandleCommand(cmd dto.SlackCommand) (dto.SlackResponse, error)
+}
+
+type slack struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	coin    ICoin
+}
+
+func newSlack(
+	cfg *config.Config,
+	log *logger.Logger,
+	storage storage.IStorage,
+	coin ICoin,
+) ISlack {
+	return &slack{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		coin:    coin,
+	}
+}
+
+// CreateLinkCode issues a one-time code the user redeems in Slack with
+// `/coins link CODE`.
+func (s *slack) CreateLinkCode(userID int64) (dto.SlackLinkCode, error) {
+	const op = "service.slack.CreateLinkCode"
+
+	random := make([]byte, slackLinkCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		s.log.Error("failed to generate link code:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SlackLinkCode{}, errors.ErrInternal(err)
+	}
+
+	code := make([]byte, slackLinkCodeLength)
+	for i, b := range random {
+		code[i] = slackLinkCodeAlphabet[int(b)%len(slackLinkCodeAlphabet)]
+	}
+
+	ttl := s.cfg.Settings.Service.SlackLinkCodeTTL
+	if ttl <= 0 {
+		ttl = defaultSlackLinkCodeTTL
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	if err := s.storage.Slack().CreateLinkCode(userID, string(code), expiresAt); err != nil {
+		s.log.Error("failed to create link code:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SlackLinkCode{}, errors.ErrInternal(err)
+	}
+
+	return dto.SlackLinkCode{Code: string(code), ExpiresAt: expiresAt}, nil
+}
+
+// HandleCommand runs a slash command. Mistakes of the caller are answered
+// with an ephemeral message; only internal failures are returned as errors.
+func (s *slack) HandleCommand(cmd dto.SlackCommand) (dto.SlackResponse, error) {
+	fields := strings.Fields(cmd.Text)
+	if len(fields) == 0 || strings.EqualFold(fields[0], "help") {
+		return ephemeral(slackUsage), nil
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "link":
+		if len(fields) != 2 {
+			return ephemeral("Usage: `/coins link CODE`"), nil
+		}
+		return s.link(cmd, fields[1])
+	case "unlink":
+		return s.unlink(cmd)
+	}
+
+	return s.send(cmd, fields)
+}
+
+func (s *slack) link(cmd dto.SlackCommand, code string) (dto.SlackResponse, error) {
+	const op = "service.slack.link"
+
+	userID, err := s.storage.Slack().Link(strings.ToUpper(code), cmd.TeamID, cmd.UserID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ephemeral("The code is invalid or has expired."), nil
+	}
+	if err != nil {
+		s.log.Error("failed to link slack user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SlackResponse{}, errors.ErrInternal(err)
+	}
+
+	user, err := s.storage.User().GetUserByID(userID)
+	if err != nil {
+		s.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SlackResponse{}, errors.ErrInternal(err)
+	}
+
+	return ephemeral(fmt.Sprintf("Linked to *%s*. You can now send coins with `/coins @user 10`.", user.Username)), nil
+}
+
+func (s *slack) unlink(cmd dto.SlackCommand) (dto.SlackResponse, error) {
+	const op = "service.slack.unlink"
+
+	err := s.storage.Slack().Unlink(cmd.TeamID, cmd.UserID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ephemeral("Your account is not linked."), nil
+	}
+	if err != nil {
+		s.log.Error("failed to unlink slack user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SlackResponse{}, errors.ErrInternal(err)
+	}
+
+	return ephemeral("Your account has been unlinked."), nil
+}
+
+// send handles `<recipient> <amount> [memo]`. The recipient is either a
+// Slack mention of a linked user or an avito-shop username.
+func (s *slack) send(cmd dto.SlackCommand, fields []string) (dto.SlackResponse, error) {
+	const op = "service.slack.send"
+
+	if len(fields) < 2 {
+		return ephemeral(slackUsage), nil
+	}
+
+	amount, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || amount <= 0 {
+		return ephemeral("The amount must be a positive whole number."), nil
+	}
+
+	memo := strings.Join(fields[2:], " ")
+	if utf8.RuneCountInString(memo) > slackMemoMaxLength {
+		return ephemeral(fmt.Sprintf("The memo must be at most %d characters.", slackMemoMaxLength)), nil
+	}
+
+	sender, err := s.storage.Slack().GetLinkedUser(cmd.TeamID, cmd.UserID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ephemeral("Link your account first: get a code from POST /api/slack/link and run `/coins link CODE`."), nil
+	}
+	if err != nil {
+		s.log.Error("failed to get linked sender:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.SlackResponse{}, errors.ErrInternal(err)
+	}
+
+	recipient := strings.TrimPrefix(fields[0], "@")
+	if match := slackMention.FindStringSubmatch(fields[0]); match != nil {
+		user, err := s.storage.Slack().GetLinkedUser(cmd.TeamID, match[1])
+		if errors.Is(err, storage.ErrNotFound) {
+			return ephemeral(fmt.Sprintf("<@%s> has not linked an account yet.", match[1])), nil
+		}
+		if err != nil {
+			s.log.Error("failed to get linked recipient:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return dto.SlackResponse{}, errors.ErrInternal(err)
+		}
+		recipient = user.Username
+	}
+
+	resp, err := s.coin.Send(sender.ID, dto.SendCoinRequest{
+		ToUser: recipient,
+		Amount: amount,
+		Memo:   memo,
+	})
+	if err != nil {
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) && appErr.Code != errors.InternalServerError {
+			return ephemeral("Transfer failed: " + appErr.Message + "."), nil
+		}
+		return dto.SlackResponse{}, err
+	}
+
+	if resp.Status == sendStatusPendingApproval {
+		return ephemeral(fmt.Sprintf(
+			"Your transfer of %d coins to *%s* is waiting for approval.", amount, recipient,
+		)), nil
+	}
+
+	return dto.SlackResponse{
+		ResponseType: slackResponseInChannel,
+		Text:         withMemo(fmt.Sprintf(":coin: <@%s> sent %d coins to *%s*", cmd.UserID, amount, recipient), memo),
+	}, nil
+}
+
+func ephemeral(text string) dto.SlackResponse {
+	return dto.SlackResponse{ResponseType: slackResponseEphemeral, Text: text}
+}
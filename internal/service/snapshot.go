@@ -0,0 +1,57 @@
+package service
+
+import (
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// snapshotDelay keeps snapshots behind the clock. A transaction is stamped
+// when it starts, so one still running at the snapshot moment could commit
+// with an earlier created_at and be missed by every later lookup.
+const snapshotDelay = time.Minute
+
+type ISnapshot interface {
+	TakeDue() error
+}
+
+type snapshot struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+}
+
+func newSnapshot(cfg *config.Config, log *logger.Logger, storage storage.IStorage) ISnapshot {
+	return &snapshot{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+	}
+}
+
+// TakeDue checkpoints the balances of users who moved coins since their last
+// snapshot, so balance lookups do not scan the whole history.
+func (s *snapshot) TakeDue() error {
+	const op = "service.snapshot.TakeDue"
+
+	taken, err := s.storage.Balance().TakeSnapshots(time.Now().Add(-snapshotDelay))
+	if err != nil {
+		s.log.Error("failed to take balance snapshots:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if taken > 0 {
+		s.log.Info("balance snapshots taken",
+			zap.String("method", op),
+			zap.Int64("users", taken),
+		)
+	}
+
+	return nil
+}
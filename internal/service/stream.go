@@ -0,0 +1,101 @@
+package service
+
+import (
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"github.com/icoder-new/avito-shop/pkg/pubsub"
+	"go.uber.org/zap"
+)
+
+const (
+	streamEventBalance  = "balance"
+	streamEventTransfer = "transfer"
+	streamEventOrder    = "order"
+)
+
+type IStream interface {
+	Subscribe(userID int64) *pubsub.Subscription
+	Unsubscribe(sub *pubsub.Subscription)
+}
+
+type stream struct {
+	broker *pubsub.Broker
+}
+
+func newStream(broker *pubsub.Broker) IStream {
+	return &stream{
+		broker: broker,
+	}
+}
+
+func (s *stream) Subscribe(userID int64) *pubsub.Subscription {
+	return s.broker.Subscribe(userID)
+}
+
+func (s *stream) Unsubscribe(sub *pubsub.Subscription) {
+	s.broker.Unsubscribe(sub)
+}
+
+// events publishes stream events once a change is committed. Like
+// notifications, a failure is logged and never fails the operation itself.
+type events struct {
+	log     *logger.Logger
+	storage storage.IStorage
+	broker  *pubsub.Broker
+}
+
+func newEvents(log *logger.Logger, storage storage.IStorage, broker *pubsub.Broker) *events {
+	return &events{
+		log:     log,
+		storage: storage,
+		broker:  broker,
+	}
+}
+
+// balance sends the current balance to each of the users.
+func (e *events) balance(op string, userIDs ...int64) {
+	for _, userID := range userIDs {
+		user, err := e.storage.User().GetUserByID(userID)
+		if err != nil {
+			e.log.Error("failed to get user for balance event:",
+				zap.String("method", op),
+				zap.Int64("user_id", userID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		e.broker.Publish(userID, streamEventBalance, dto.BalanceEvent{
+			Coins:         user.Coins,
+			GiftableCoins: user.GiftableCoins,
+			PendingCoins:  user.ReservedCoins,
+			PreorderCoins: user.PreorderCoins,
+		})
+	}
+}
+
+// transfer tells the recipient about incoming coins.
+func (e *events) transfer(t models.Transaction, fromUsername string) {
+	e.broker.Publish(t.ToUserID, streamEventTransfer, dto.TransferEvent{
+		FromUser: fromUsername,
+		Amount:   t.Amount,
+		Memo:     t.Memo,
+	})
+}
+
+// order sends the order's current state to its owner.
+func (e *events) order(op string, orderID int64) {
+	details, err := e.storage.Order().GetByID(orderID)
+	if err != nil {
+		e.log.Error("failed to get order for order event:",
+			zap.String("method", op),
+			zap.Int64("order_id", orderID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	e.broker.Publish(details.UserID, streamEventOrder, convertOrders([]models.OrderDetails{details})[0])
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ITeam interface {
+	Create(req dto.CreateTeamRequest) (dto.Team, error)
+	AddMembers(teamID int64, req dto.TeamMembersRequest) (dto.Team, error)
+	GetUserTeam(userID int64) (dto.Team, error)
+	Grant(leadID int64, req dto.TeamGrantRequest) error
+}
+
+type team struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newTeam(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) ITeam {
+	return &team{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+func (t *team) Create(req dto.CreateTeamRequest) (dto.Team, error) {
+	const op = "service.team.Create"
+
+	lead, err := t.storage.User().GetUserByUsername(req.Lead)
+	if err != nil {
+		t.log.Error("lead not found:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrNotFound("lead not found")
+	}
+
+	created, err := t.storage.Team().Create(models.Team{
+		Name:          req.Name,
+		LeadID:        &lead.ID,
+		MonthlyBudget: req.MonthlyBudget,
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		return dto.Team{}, errors.ErrBadRequest("team name is taken or the lead already leads a team")
+	}
+	if err != nil {
+		t.log.Error("failed to create team:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+
+	return t.convert(op, created)
+}
+
+func (t *team) AddMembers(teamID int64, req dto.TeamMembersRequest) (dto.Team, error) {
+	const op = "service.team.AddMembers"
+
+	existing, err := t.storage.Team().GetByID(teamID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return dto.Team{}, errors.ErrNotFound("team not found")
+	}
+	if err != nil {
+		t.log.Error("failed to get team:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+
+	users, err := t.storage.User().GetUsersByUsernames(req.Usernames)
+	if err != nil {
+		t.log.Error("failed to get users:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+	if len(users) != len(uniqueStrings(req.Usernames)) {
+		return dto.Team{}, errors.ErrNotFound("some users were not found")
+	}
+
+	userIDs := make([]int64, 0, len(users))
+	for _, user := range users {
+		led, err := t.storage.Team().GetByLead(user.ID)
+		switch {
+		case err == nil && led.ID != teamID:
+			return dto.Team{}, errors.ErrBadRequest(user.Username + " leads another team")
+		case err != nil && !errors.Is(err, storage.ErrNotFound):
+			t.log.Error("failed to get team:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return dto.Team{}, errors.ErrInternal(err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	if err = t.storage.Team().AddMembers(teamID, userIDs); err != nil {
+		t.log.Error("failed to add team members:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+
+	return t.convert(op, existing)
+}
+
+func (t *team) GetUserTeam(userID int64) (dto.Team, error) {
+	const op = "service.team.GetUserTeam"
+
+	user, err := t.storage.User().GetUserByID(userID)
+	if err != nil {
+		t.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+	if user.TeamID == nil {
+		return dto.Team{}, errors.ErrNotFound("you are not in a team")
+	}
+
+	existing, err := t.storage.Team().GetByID(*user.TeamID)
+	if err != nil {
+		t.log.Error("failed to get team:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+
+	return t.convert(op, existing)
+}
+
+// Grant hands out coins from the team budget of the team the caller leads.
+// The coins land on the member's spendable balance.
+func (t *team) Grant(leadID int64, req dto.TeamGrantRequest) error {
+	const op = "service.team.Grant"
+
+	led, err := t.storage.Team().GetByLead(leadID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrForbidden("only team leads can distribute the team budget")
+	}
+	if err != nil {
+		t.log.Error("failed to get team:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	member, err := t.storage.User().GetUserByUsername(req.ToUser)
+	if err != nil || member.Role == models.RoleSystem {
+		return errors.ErrNotFound("recipient not found")
+	}
+	if member.ID == leadID {
+		return errors.ErrBadRequest("cannot grant the team budget to yourself")
+	}
+	if member.TeamID == nil || *member.TeamID != led.ID {
+		return errors.ErrBadRequest("recipient is not a member of your team")
+	}
+
+	_, err = t.storage.Team().Grant(led.ID, leadID, member.ID, req.Amount, req.Memo)
+	switch {
+	case err == nil:
+		t.received(op, leadID, member, req)
+		return nil
+	case errors.Is(err, storage.ErrNotFound):
+		return errors.ErrBadRequest("recipient is not a member of your team")
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return errors.ErrBadRequest("team budget is too low")
+	default:
+		t.log.Error("failed to grant team budget:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+}
+
+// received tells the member about a committed grant. A failed lookup of the
+// lead is logged and does not fail the grant.
+func (t *team) received(op string, leadID int64, member models.User, req dto.TeamGrantRequest) {
+	t.events.balance(op, member.ID)
+
+	lead, err := t.storage.User().GetUserByID(leadID)
+	if err != nil {
+		t.log.Error("failed to get team lead:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return
+	}
+
+	t.events.transfer(models.Transaction{ToUserID: member.ID, Amount: req.Amount, Memo: req.Memo}, lead.Username)
+	notify(t.log, t.storage, op, models.Notification{
+		UserID:  member.ID,
+		Type:    models.NotificationTypeCoinsReceived,
+		Message: withMemo(fmt.Sprintf("%s granted you %d coins from the team budget", lead.Username, req.Amount), req.Memo),
+	})
+	queueEmail(t.cfg, t.log, t.storage, op, member.ID, email.TemplateCoinsReceived, email.CoinsReceivedData{
+		Username: member.Username,
+		From:     lead.Username,
+		Amount:   req.Amount,
+		Memo:     req.Memo,
+	})
+}
+
+func (t *team) convert(op string, existing models.Team) (dto.Team, error) {
+	members, err := t.storage.Team().GetMembers(existing.ID)
+	if err != nil {
+		t.log.Error("failed to get team members:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Team{}, errors.ErrInternal(err)
+	}
+
+	response := dto.Team{
+		ID:            existing.ID,
+		Name:          existing.Name,
+		Budget:        existing.Budget,
+		MonthlyBudget: existing.MonthlyBudget,
+		Members:       make([]string, 0, len(members)),
+	}
+	for _, member := range members {
+		if existing.LeadID != nil && member.ID == *existing.LeadID {
+			response.Lead = member.Username
+		}
+		response.Members = append(response.Members, member.Username)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultAdjustmentsLimit = 50
+
+type ITreasury interface {
+	Grant(actorID int64, req dto.GrantRequest) ([]dto.Adjustment, error)
+	Clawback(actorID int64, req dto.ClawbackRequest) (dto.Adjustment, error)
+	GetAdjustments(query dto.AdjustmentsQuery) ([]dto.Adjustment, error)
+}
+
+type treasury struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	events  *events
+}
+
+func newTreasury(cfg *config.Config, log *logger.Logger, storage storage.IStorage, events *events) ITreasury {
+	return &treasury{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		events:  events,
+	}
+}
+
+func (t *treasury) Grant(actorID int64, req dto.GrantRequest) ([]dto.Adjustment, error) {
+	const op = "service.treasury.Grant"
+
+	users, err := t.storage.User().GetUsersByUsernames(req.Usernames)
+	if err != nil {
+		t.log.Error("failed to get users:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	usernames := make(map[int64]string, len(users))
+	userIDs := make([]int64, 0, len(users))
+	for _, user := range users {
+		if user.Role == models.RoleSystem {
+			continue
+		}
+		usernames[user.ID] = user.Username
+		userIDs = append(userIDs, user.ID)
+	}
+
+	if len(userIDs) != len(uniqueStrings(req.Usernames)) {
+		return nil, errors.ErrNotFound("some users were not found")
+	}
+
+	grants, err := t.storage.Coin().Grant(actorID, userIDs, req.Amount, req.Reason)
+	if err != nil {
+		t.log.Error("failed to grant coins:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	t.log.Info("coins granted",
+		zap.String("method", op),
+		zap.Int64("actor_id", actorID),
+		zap.Int64s("user_ids", userIDs),
+		zap.Int64("amount", req.Amount),
+		zap.String("reason", req.Reason),
+	)
+
+	response := make([]dto.Adjustment, 0, len(grants))
+	for _, grant := range grants {
+		notify(t.log, t.storage, op, models.Notification{
+			UserID:  grant.ToUserID,
+			Type:    models.NotificationTypeCoinsGranted,
+			Message: fmt.Sprintf("You were granted %d coins: %s", grant.Amount, req.Reason),
+		})
+		t.events.balance(op, grant.ToUserID)
+
+		response = append(response, convertAdjustment(models.TransactionDetails{
+			Transaction: grant,
+			ToUsername:  usernames[grant.ToUserID],
+		}))
+	}
+
+	return response, nil
+}
+
+func (t *treasury) Clawback(actorID int64, req dto.ClawbackRequest) (dto.Adjustment, error) {
+	const op = "service.treasury.Clawback"
+
+	user, err := t.storage.User().GetUserByUsername(req.Username)
+	if err != nil {
+		t.log.Error("user not found:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Adjustment{}, errors.ErrNotFound("user not found")
+	}
+
+	clawback, err := t.storage.Coin().Clawback(actorID, user.ID, req.Amount, req.Reason)
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		return dto.Adjustment{}, errors.ErrBadRequest("clawback exceeds user balance")
+	}
+	if err != nil {
+		t.log.Error("failed to claw back coins:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Adjustment{}, errors.ErrInternal(err)
+	}
+
+	t.log.Info("coins clawed back",
+		zap.String("method", op),
+		zap.Int64("actor_id", actorID),
+		zap.Int64("user_id", user.ID),
+		zap.Int64("amount", req.Amount),
+		zap.String("reason", req.Reason),
+	)
+
+	return convertAdjustment(models.TransactionDetails{
+		Transaction:  clawback,
+		FromUsername: user.Username,
+	}), nil
+}
+
+func (t *treasury) GetAdjustments(query dto.AdjustmentsQuery) ([]dto.Adjustment, error) {
+	const op = "service.treasury.GetAdjustments"
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultAdjustmentsLimit
+	}
+
+	adjustments, err := t.storage.Coin().GetAdjustments(limit, query.Offset)
+	if err != nil {
+		t.log.Error("failed to get adjustments:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.Adjustment, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		response = append(response, convertAdjustment(adjustment))
+	}
+
+	return response, nil
+}
+
+func convertAdjustment(t models.TransactionDetails) dto.Adjustment {
+	username := t.ToUsername
+	if t.Type == models.TransactionTypeClawback {
+		username = t.FromUsername
+	}
+
+	return dto.Adjustment{
+		TransactionID: t.ID,
+		Type:          string(t.Type),
+		Username:      username,
+		Amount:        t.Amount,
+		Reason:        t.Reason,
+		CreatedBy:     t.CreatedByUsername,
+		CreatedAt:     t.CreatedAt,
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; !ok {
+			seen[value] = struct{}{}
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -1,7 +1,8 @@
 package service
 
 import (
-	"fmt"
+	"time"
+
 	"github.com/icoder-new/avito-shop/internal/config"
 	"github.com/icoder-new/avito-shop/internal/dto"
 	"github.com/icoder-new/avito-shop/internal/models"
@@ -13,6 +14,8 @@ import (
 
 type IUser interface {
 	GetInfo(userID int64) (dto.UserInfo, error)
+	GetBalanceAt(userID int64, at time.Time) (dto.BalanceResponse, error)
+	GetBalanceAtByUsername(username string, at time.Time) (dto.BalanceResponse, error)
 }
 
 type user struct {
@@ -59,17 +62,37 @@ func (u *user) GetInfo(userID int64) (dto.UserInfo, error) {
 		return dto.UserInfo{}, errors.ErrInternal(err)
 	}
 
+	expiring, err := u.storage.Coin().GetExpiringLots(userID, time.Now().Add(u.cfg.Settings.Service.CoinExpiryWarning))
+	if err != nil {
+		u.log.Error("failed to get expiring coins:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.UserInfo{}, errors.ErrInternal(err)
+	}
+
 	response := dto.UserInfo{
-		Coins:     user.Coins,
-		Inventory: u.convertInventory(inventory),
+		Coins:         user.Coins,
+		GiftableCoins: user.GiftableCoins,
+		PendingCoins:  user.ReservedCoins,
+		PreorderCoins: user.PreorderCoins,
+		ExpiringSoon:  make([]dto.ExpiringCoins, 0, len(expiring)),
+		Inventory:     u.convertInventory(inventory),
 		CoinHistory: dto.CoinHistory{
 			Received: make([]dto.CoinTransfer, 0),
 			Sent:     make([]dto.CoinTransfer, 0),
 		},
 	}
 
+	for _, lot := range expiring {
+		response.ExpiringSoon = append(response.ExpiringSoon, dto.ExpiringCoins{
+			Amount:    lot.Remaining,
+			ExpiresAt: lot.ExpiresAt,
+		})
+	}
+
 	for _, tx := range transactions {
-		if tx.Type == models.TransactionTypeTransfer {
+		if tx.Type == models.TransactionTypeTransfer || tx.Type == models.TransactionTypeReversal {
 			transfer := u.processCoinTransfer(tx, userID)
 			if tx.ToUserID == userID {
 				response.CoinHistory.Received = append(response.CoinHistory.Received, transfer)
@@ -82,29 +105,67 @@ func (u *user) GetInfo(userID int64) (dto.UserInfo, error) {
 	return response, nil
 }
 
-func (u *user) convertInventory(inventory []models.UserInventory) []dto.InventoryItem {
-	items := make([]dto.InventoryItem, len(inventory))
-	merchTypes := map[int64]string{
-		1:  "t-shirt",
-		2:  "cup",
-		3:  "book",
-		4:  "pen",
-		5:  "powerbank",
-		6:  "hoody",
-		7:  "umbrella",
-		8:  "socks",
-		9:  "wallet",
-		10: "pink-hoody",
+func (u *user) GetBalanceAt(userID int64, at time.Time) (dto.BalanceResponse, error) {
+	const op = "service.user.GetBalanceAt"
+
+	user, err := u.storage.User().GetUserByID(userID)
+	if err != nil {
+		u.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.BalanceResponse{}, errors.ErrNotFound("user not found")
 	}
 
-	for i, item := range inventory {
-		itemType := merchTypes[item.MerchID]
-		if itemType == "" {
-			itemType = fmt.Sprintf("item_%d", item.MerchID)
-		}
+	return u.balanceAt(user, at)
+}
+
+func (u *user) GetBalanceAtByUsername(username string, at time.Time) (dto.BalanceResponse, error) {
+	const op = "service.user.GetBalanceAtByUsername"
 
+	user, err := u.storage.User().GetUserByUsername(username)
+	if err != nil {
+		u.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.BalanceResponse{}, errors.ErrNotFound("user not found")
+	}
+
+	return u.balanceAt(user, at)
+}
+
+func (u *user) balanceAt(user models.User, at time.Time) (dto.BalanceResponse, error) {
+	const op = "service.user.balanceAt"
+
+	if at.After(time.Now()) {
+		return dto.BalanceResponse{}, errors.ErrBadRequest("timestamp must not be in the future")
+	}
+
+	coins, err := u.storage.Balance().GetBalanceAt(user.ID, at)
+	if err != nil {
+		u.log.Error("failed to get historical balance:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.BalanceResponse{}, errors.ErrInternal(err)
+	}
+
+	return dto.BalanceResponse{
+		Username: user.Username,
+		Coins:    coins,
+		At:       at,
+	}, nil
+}
+
+func (u *user) convertInventory(inventory []models.UserInventory) []dto.InventoryItem {
+	items := make([]dto.InventoryItem, len(inventory))
+	for i, item := range inventory {
 		items[i] = dto.InventoryItem{
-			Type:     itemType,
+			Type:     item.MerchName,
+			SKU:      item.SKU,
+			Size:     item.Size,
+			Color:    item.Color,
 			Quantity: item.Quantity,
 		}
 	}
@@ -118,26 +179,38 @@ func (u *user) processCoinTransfer(tx models.Transaction, userID int64) dto.Coin
 		if err != nil {
 			u.log.Error("failed to get sender info:", zap.Error(err))
 			return dto.CoinTransfer{
-				FromUser: "unknown",
-				Amount:   tx.Amount,
+				FromUser:   "unknown",
+				Amount:     tx.Amount,
+				Memo:       tx.Memo,
+				Category:   string(tx.Category),
+				ReversalOf: tx.OriginalTransactionID,
 			}
 		}
 		return dto.CoinTransfer{
-			FromUser: fromUser.Username,
-			Amount:   tx.Amount,
+			FromUser:   fromUser.Username,
+			Amount:     tx.Amount,
+			Memo:       tx.Memo,
+			Category:   string(tx.Category),
+			ReversalOf: tx.OriginalTransactionID,
 		}
 	} else {
 		toUser, err := u.storage.User().GetUserByID(tx.ToUserID)
 		if err != nil {
 			u.log.Error("failed to get receiver info:", zap.Error(err))
 			return dto.CoinTransfer{
-				ToUser: "unknown",
-				Amount: tx.Amount,
+				ToUser:     "unknown",
+				Amount:     tx.Amount,
+				Memo:       tx.Memo,
+				Category:   string(tx.Category),
+				ReversalOf: tx.OriginalTransactionID,
 			}
 		}
 		return dto.CoinTransfer{
-			ToUser: toUser.Username,
-			Amount: tx.Amount,
+			ToUser:     toUser.Username,
+			Amount:     tx.Amount,
+			Memo:       tx.Memo,
+			Category:   string(tx.Category),
+			ReversalOf: tx.OriginalTransactionID,
 		}
 	}
 }
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookSecretPrefix   = "whsec_"
+	webhookSecretBytes    = 32
+	webhookClaimBatch     = 20
+	webhookMaxErrorLength = 500
+
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookRetryBase   = 30 * time.Second
+	defaultWebhookRetryMax    = 6 * time.Hour
+)
+
+type IWebhook interface {
+	Create(actorID int64, req dto.WebhookRequest) (dto.Webhook, error)
+	List() ([]dto.Webhook, error)
+	Delete(webhookID int64) error
+	GetDeliveries(query dto.WebhookDeliveriesQuery) ([]dto.WebhookDelivery, error)
+	Retry(deliveryID int64) error
+	Deliver() error
+}
+
+type webhook struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+	client  *http.Client
+}
+
+func newWebhook(cfg *config.Config, log *logger.Logger, storage storage.IStorage) IWebhook {
+	timeout := cfg.Settings.Service.Webhooks.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &webhook{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Create registers an endpoint. The signing secret is returned only here.
+func (w *webhook) Create(actorID int64, req dto.WebhookRequest) (dto.Webhook, error) {
+	const op = "service.webhook.Create"
+
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		w.log.Error("failed to generate webhook secret:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Webhook{}, errors.ErrInternal(err)
+	}
+
+	created, err := w.storage.Webhook().Create(models.Webhook{
+		URL:        req.URL,
+		Secret:     webhookSecretPrefix + hex.EncodeToString(secret),
+		EventTypes: uniqueStrings(req.EventTypes),
+		CreatedBy:  actorID,
+	})
+	if err != nil {
+		w.log.Error("failed to create webhook:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.Webhook{}, errors.ErrInternal(err)
+	}
+
+	response := convertWebhook(created)
+	response.Secret = created.Secret
+	return response, nil
+}
+
+func (w *webhook) List() ([]dto.Webhook, error) {
+	const op = "service.webhook.List"
+
+	webhooks, err := w.storage.Webhook().GetAll()
+	if err != nil {
+		w.log.Error("failed to get webhooks:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.Webhook, 0, len(webhooks))
+	for _, m := range webhooks {
+		response = append(response, convertWebhook(m))
+	}
+	return response, nil
+}
+
+// Delete deactivates the webhook. Its history stays available.
+func (w *webhook) Delete(webhookID int64) error {
+	const op = "service.webhook.Delete"
+
+	err := w.storage.Webhook().Deactivate(webhookID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("webhook not found")
+	}
+	if err != nil {
+		w.log.Error("failed to deactivate webhook:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// GetDeliveries lists deliveries, newest first. Filtering by the dead status
+// gives the dead-letter view.
+func (w *webhook) GetDeliveries(query dto.WebhookDeliveriesQuery) ([]dto.WebhookDelivery, error) {
+	const op = "service.webhook.GetDeliveries"
+
+	deliveries, err := w.storage.Webhook().GetDeliveries(storage.WebhookDeliveryFilter{
+		WebhookID: query.WebhookID,
+		Status:    models.WebhookDeliveryStatus(query.Status),
+		Limit:     query.Limit,
+		Offset:    query.Offset,
+	})
+	if err != nil {
+		w.log.Error("failed to get webhook deliveries:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		delivery := dto.WebhookDelivery{
+			ID:             d.ID,
+			WebhookID:      d.WebhookID,
+			EventID:        d.EventID,
+			Event:          string(d.EventType),
+			Payload:        d.Payload,
+			Status:         string(d.Status),
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			LastError:      d.LastError,
+			CreatedAt:      d.CreatedAt,
+			DeliveredAt:    d.DeliveredAt,
+		}
+		if d.Status == models.WebhookDeliveryStatusPending {
+			delivery.NextAttemptAt = &d.NextAttemptAt
+		}
+		response = append(response, delivery)
+	}
+	return response, nil
+}
+
+// Retry queues a dead delivery again.
+func (w *webhook) Retry(deliveryID int64) error {
+	const op = "service.webhook.Retry"
+
+	err := w.storage.Webhook().Retry(deliveryID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("dead delivery not found")
+	}
+	if err != nil {
+		w.log.Error("failed to retry webhook delivery:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// Deliver sends every due delivery. Deliveries of a batch are sent in
+// parallel and each is leased for twice the request timeout, so a crashed
+// replica's claims become due again soon.
+func (w *webhook) Deliver() error {
+	const op = "service.webhook.Deliver"
+
+	for {
+		deliveries, err := w.storage.Webhook().ClaimDue(webhookClaimBatch, 2*w.client.Timeout)
+		if err != nil {
+			w.log.Error("failed to claim webhook deliveries:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func(d models.WebhookDeliveryDetails) {
+				defer wg.Done()
+				w.attempt(op, d)
+			}(d)
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookClaimBatch {
+			return nil
+		}
+	}
+}
+
+func (w *webhook) attempt(op string, d models.WebhookDeliveryDetails) {
+	responseStatus, err := w.send(d)
+	if err == nil {
+		if err = w.storage.Webhook().MarkDelivered(d.ID, *responseStatus); err != nil {
+			w.log.Error("failed to mark webhook delivery as delivered:",
+				zap.String("method", op),
+				zap.Int64("delivery_id", d.ID),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if attempts := d.Attempts + 1; attempts < w.maxAttempts() {
+		next := time.Now().Add(w.backoff(attempts))
+		retryAt = &next
+	}
+
+	w.log.Warn("webhook delivery failed",
+		zap.String("method", op),
+		zap.Int64("delivery_id", d.ID),
+		zap.Int64("webhook_id", d.WebhookID),
+		zap.Bool("dead", retryAt == nil),
+		zap.Error(err),
+	)
+
+	lastError := err.Error()
+	if len(lastError) > webhookMaxErrorLength {
+		lastError = lastError[:webhookMaxErrorLength]
+	}
+	if err = w.storage.Webhook().MarkFailed(d.ID, responseStatus, lastError, retryAt); err != nil {
+		w.log.Error("failed to record webhook delivery failure:",
+			zap.String("method", op),
+			zap.Int64("delivery_id", d.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// send posts the delivery. The signature is an HMAC-SHA256 of the timestamp
+// and the body, so that receivers can reject replayed requests.
+func (w *webhook) send(d models.WebhookDeliveryDetails) (*int, error) {
+	body, err := json.Marshal(dto.WebhookPayload{
+		ID:        d.EventID,
+		Event:     string(d.EventType),
+		CreatedAt: d.CreatedAt,
+		Data:      d.Payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", w.cfg.Settings.App.Name+"-webhooks")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(d.EventID, 10))
+	req.Header.Set("X-Webhook-Event", string(d.EventType))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(d.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return &resp.StatusCode, nil
+}
+
+func (w *webhook) maxAttempts() int {
+	if attempts := w.cfg.Settings.Service.Webhooks.MaxAttempts; attempts > 0 {
+		return attempts
+	}
+	return defaultWebhookMaxAttempts
+}
+
+// backoff returns the delay before the attempt after the given number of
+// failed ones: RetryBase, doubling each time, capped at RetryMax.
+func (w *webhook) backoff(attempts int) time.Duration {
+	base, limit := w.cfg.Settings.Service.Webhooks.RetryBase, w.cfg.Settings.Service.Webhooks.RetryMax
+	if base <= 0 {
+		base = defaultWebhookRetryBase
+	}
+	if limit <= 0 {
+		limit = defaultWebhookRetryMax
+	}
+
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func convertWebhook(m models.Webhook) dto.Webhook {
+	return dto.Webhook{
+		ID:         m.ID,
+		URL:        m.URL,
+		EventTypes: m.EventTypes,
+		Active:     m.Active,
+		CreatedAt:  m.CreatedAt,
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/icoder-new/avito-shop/internal/config"
+	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/icoder-new/avito-shop/pkg/errors"
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const wishlistBatchSize = 500
+
+type IWishlist interface {
+	Add(userID int64, req dto.WishlistRequest) (dto.WishlistItem, error)
+	List(userID int64) ([]dto.WishlistItem, error)
+	Remove(userID, itemID int64) error
+	Evaluate() error
+}
+
+type wishlist struct {
+	cfg     *config.Config
+	log     *logger.Logger
+	storage storage.IStorage
+}
+
+func newWishlist(cfg *config.Config, log *logger.Logger, storage storage.IStorage) IWishlist {
+	return &wishlist{
+		cfg:     cfg,
+		log:     log,
+		storage: storage,
+	}
+}
+
+// Add saves an item to the user's wishlist. The current price, stock and
+// balance are recorded so that only later changes are reported.
+func (w *wishlist) Add(userID int64, req dto.WishlistRequest) (dto.WishlistItem, error) {
+	const op = "service.wishlist.Add"
+
+	item, err := w.storage.Inventory().GetMerchByName(req.Item)
+	if errors.Is(err, storage.ErrNotFound) {
+		return dto.WishlistItem{}, errors.ErrBadRequest("invalid item name")
+	}
+	if err != nil {
+		w.log.Error("failed to get item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.WishlistItem{}, errors.ErrInternal(err)
+	}
+
+	variantID, err := resolveVariant(w.log, w.storage, op, item, req.SKU)
+	if err != nil {
+		return dto.WishlistItem{}, err
+	}
+
+	user, err := w.storage.User().GetUserByID(userID)
+	if err != nil {
+		w.log.Error("failed to get user:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.WishlistItem{}, errors.ErrNotFound("user not found")
+	}
+
+	details := models.WishlistItemDetails{
+		WishlistItem: models.WishlistItem{
+			UserID:    userID,
+			MerchID:   item.ID,
+			VariantID: variantID,
+		},
+		MerchName: item.Name,
+		Price:     item.Price,
+		Stock:     item.Stock,
+		Coins:     user.Coins,
+	}
+	if variantID != nil {
+		variant, err := w.storage.Variant().GetBySKU(req.SKU)
+		if err != nil {
+			w.log.Error("failed to get variant:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return dto.WishlistItem{}, errors.ErrInternal(err)
+		}
+		details.SKU, details.Size, details.Color, details.Stock = variant.SKU, variant.Size, variant.Color, variant.Stock
+	}
+
+	price, err := w.currentPrice(op, details)
+	if err != nil {
+		return dto.WishlistItem{}, errors.ErrInternal(err)
+	}
+
+	details.LastPrice = price
+	details.InStock = details.Available()
+	details.AffordableNotified = user.Coins >= price
+
+	created, err := w.storage.Wishlist().Add(details.WishlistItem)
+	if errors.Is(err, storage.ErrConflict) {
+		return dto.WishlistItem{}, errors.ErrBadRequest("item is already on your wishlist")
+	}
+	if err != nil {
+		w.log.Error("failed to add wishlist item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return dto.WishlistItem{}, errors.ErrInternal(err)
+	}
+
+	details.WishlistItem = created
+	return convertWishlistItem(details, price), nil
+}
+
+func (w *wishlist) List(userID int64) ([]dto.WishlistItem, error) {
+	const op = "service.wishlist.List"
+
+	items, err := w.storage.Wishlist().GetUserWishlist(userID)
+	if err != nil {
+		w.log.Error("failed to get wishlist:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return nil, errors.ErrInternal(err)
+	}
+
+	response := make([]dto.WishlistItem, 0, len(items))
+	for _, item := range items {
+		price, err := w.currentPrice(op, item)
+		if err != nil {
+			return nil, errors.ErrInternal(err)
+		}
+		response = append(response, convertWishlistItem(item, price))
+	}
+
+	return response, nil
+}
+
+func (w *wishlist) Remove(userID, itemID int64) error {
+	const op = "service.wishlist.Remove"
+
+	err := w.storage.Wishlist().Remove(itemID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.ErrNotFound("wishlist item not found")
+	}
+	if err != nil {
+		w.log.Error("failed to remove wishlist item:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return errors.ErrInternal(err)
+	}
+
+	return nil
+}
+
+// Evaluate checks every wishlist item and notifies its owner when the balance
+// first covers the price, when the price drops or when a sold-out item is back
+// in stock. A failing item does not stop the others; it is checked again on
+// the next run.
+func (w *wishlist) Evaluate() error {
+	const op = "service.wishlist.Evaluate"
+
+	var (
+		afterID int64
+		sent    int
+	)
+	for {
+		items, err := w.storage.Wishlist().GetBatch(afterID, wishlistBatchSize)
+		if err != nil {
+			w.log.Error("failed to get wishlist items:",
+				zap.String("method", op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		for _, item := range items {
+			afterID = item.ID
+
+			price, err := w.currentPrice(op, item)
+			if err != nil {
+				continue
+			}
+
+			next, notifications := evaluateWishlistItem(item, price)
+			if next == item.WishlistItem {
+				continue
+			}
+
+			err = w.storage.Wishlist().SaveEvaluation(item.WishlistItem, next, notifications)
+			switch {
+			case err == nil:
+				sent += len(notifications)
+			case errors.Is(err, storage.ErrConflict):
+			default:
+				w.log.Error("failed to save wishlist evaluation:",
+					zap.String("method", op),
+					zap.Int64("wishlist_item_id", item.ID),
+					zap.Error(err),
+				)
+			}
+		}
+
+		if len(items) < wishlistBatchSize {
+			break
+		}
+	}
+
+	if sent > 0 {
+		w.log.Info("wishlist notifications created",
+			zap.String("method", op),
+			zap.Int("count", sent),
+		)
+	}
+
+	return nil
+}
+
+// currentPrice is what the user would pay for the item now, with the best
+// running promotion they are eligible for.
+func (w *wishlist) currentPrice(op string, item models.WishlistItemDetails) (int64, error) {
+	promotions, err := w.storage.Promotion().GetApplicable(item.UserID, item.MerchID, "")
+	if err != nil {
+		w.log.Error("failed to get promotions:",
+			zap.String("method", op),
+			zap.Error(err),
+		)
+		return 0, err
+	}
+
+	_, price := bestPromotion(promotions, item.Price)
+	return price, nil
+}
+
+// evaluateWishlistItem compares the item as it is now with what was last
+// seen and returns the new state with a notification for each change.
+func evaluateWishlistItem(item models.WishlistItemDetails, price int64) (models.WishlistItem, []models.Notification) {
+	var (
+		next          = item.WishlistItem
+		notifications []models.Notification
+		label         = itemLabel(item.MerchName, item.SKU)
+	)
+
+	notify := func(kind models.NotificationType, message string) {
+		notifications = append(notifications, models.Notification{
+			UserID:  item.UserID,
+			Type:    kind,
+			Message: message,
+			MerchID: &item.MerchID,
+		})
+	}
+
+	if inStock := item.Available(); inStock != item.InStock {
+		if inStock {
+			notify(models.NotificationTypeWishlistRestocked, fmt.Sprintf("%s is back in stock", label))
+		}
+		next.InStock = inStock
+	}
+
+	if price != item.LastPrice {
+		if price < item.LastPrice {
+			notify(models.NotificationTypeWishlistPriceDrop,
+				fmt.Sprintf("%s now costs %d coins instead of %d", label, price, item.LastPrice))
+		}
+		next.LastPrice = price
+	}
+
+	if !item.AffordableNotified && item.Coins >= price {
+		notify(models.NotificationTypeWishlistAffordable,
+			fmt.Sprintf("You can now afford %s for %d coins", label, price))
+		next.AffordableNotified = true
+	}
+
+	return next, notifications
+}
+
+// itemLabel names an item in messages, with the SKU of the variant if any.
+func itemLabel(name, sku string) string {
+	if sku != "" {
+		return fmt.Sprintf("%s (%s)", name, sku)
+	}
+	return name
+}
+
+func convertWishlistItem(item models.WishlistItemDetails, price int64) dto.WishlistItem {
+	return dto.WishlistItem{
+		ID:         item.ID,
+		Item:       item.MerchName,
+		SKU:        item.SKU,
+		Size:       item.Size,
+		Color:      item.Color,
+		Price:      price,
+		InStock:    item.Available(),
+		Affordable: item.Coins >= price,
+		CreatedAt:  item.CreatedAt,
+	}
+}
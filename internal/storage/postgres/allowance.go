@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type allowanceRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newAllowanceRepo(ctx context.Context, pool *pgxpool.Pool) *allowanceRepo {
+	return &allowanceRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Allowance() storage.IAllowance {
+	return s.allowance
+}
+
+// TopUpUsers refills every user's giftable balance up to the allowance for the
+// period. The system account gets no allowance. Unused allowance does not roll over. The amount actually added is
+// recorded as an allowance transaction so the ledger explains the balance. It
+// returns the users whose allowance grew.
+func (a *allowanceRepo) TopUpUsers(period time.Time, amount int64) ([]int64, error) {
+	rows, err := a.pool.Query(a.ctx, `
+		WITH due AS (
+			SELECT id, giftable_coins
+			FROM users
+			WHERE allowance_period IS DISTINCT FROM $2::DATE AND role <> $4
+			FOR UPDATE
+		), topped_up AS (
+			UPDATE users u
+			SET giftable_coins = GREATEST(due.giftable_coins, $1), allowance_period = $2::DATE
+			FROM due
+			WHERE u.id = due.id
+			RETURNING u.id, GREATEST($1 - due.giftable_coins, 0) AS added
+		)
+		INSERT INTO transactions (to_user_id, amount, type, created_at)
+		SELECT id, added, $3, NOW()
+		FROM topped_up
+		WHERE added > 0
+		RETURNING to_user_id
+	`, amount, period, models.TransactionTypeAllowance, models.RoleSystem)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// TopUpTeams resets every team budget to its monthly amount for the period.
+func (a *allowanceRepo) TopUpTeams(period time.Time) (int64, error) {
+	tag, err := a.pool.Exec(a.ctx, `
+		UPDATE teams
+		SET budget = monthly_budget, budget_period = $1::DATE
+		WHERE budget_period IS DISTINCT FROM $1::DATE
+	`, period)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
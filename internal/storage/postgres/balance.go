@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type balanceRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newBalanceRepo(ctx context.Context, pool *pgxpool.Pool) *balanceRepo {
+	return &balanceRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Balance() storage.IBalance {
+	return s.balance
+}
+
+func (b *balanceRepo) CreateSnapshot(userID int64, balance int64) error {
+	_, err := b.pool.Exec(b.ctx, `
+		INSERT INTO balance_snapshots (user_id, balance, taken_at)
+		VALUES ($1, $2, NOW())
+	`, userID, balance)
+	return err
+}
+
+// TakeSnapshots applies the same arithmetic as GetBalanceAt to every user
+// that has moved coins since their latest snapshot, so later lookups only
+// scan the transactions after the new checkpoint. Users without activity keep
+// their old snapshot, which is still exact.
+func (b *balanceRepo) TakeSnapshots(at time.Time) (int64, error) {
+	tag, err := b.pool.Exec(b.ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (user_id) user_id, balance, taken_at
+			FROM balance_snapshots
+			WHERE taken_at <= $1
+			ORDER BY user_id, taken_at DESC
+		)
+		INSERT INTO balance_snapshots (user_id, balance, taken_at)
+		SELECT u.id, COALESCE(l.balance, 0) + COALESCE(received.sum, 0) - COALESCE(sent.sum, 0), $1
+		FROM users u
+		LEFT JOIN latest l ON l.user_id = u.id
+		LEFT JOIN LATERAL (
+			SELECT SUM(amount) AS sum FROM transactions
+			WHERE to_user_id = u.id AND type NOT IN ($2, $3)
+			  AND created_at > COALESCE(l.taken_at, '-infinity') AND created_at <= $1
+		) received ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT SUM(amount - giftable_amount) AS sum FROM transactions
+			WHERE from_user_id = u.id
+			  AND created_at > COALESCE(l.taken_at, '-infinity') AND created_at <= $1
+		) sent ON TRUE
+		WHERE EXISTS (
+			SELECT 1 FROM transactions t
+			WHERE (t.from_user_id = u.id OR t.to_user_id = u.id)
+			  AND t.created_at > COALESCE(l.taken_at, '-infinity') AND t.created_at <= $1
+		)
+		ON CONFLICT (user_id, taken_at) DO NOTHING
+	`, at, models.TransactionTypeGift, models.TransactionTypeAllowance)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// GetBalanceAt starts from the latest snapshot taken at or before the given
+// moment and applies the transactions that follow it through the
+// (user_id, created_at) indexes; the snapshot worker keeps that tail short.
+// The result is the spendable balance recorded in the ledger: a gift moves
+// coins from the sender to the shop, so it counts only against the sender,
+// and the giftable allowance is left out both when it is topped up and when a
+// transfer spends it. Coins held for a transfer awaiting approval or for a
+// pre-order have no ledger entry until the hold settles, so they still count
+// here while users.coins no longer includes them.
+func (b *balanceRepo) GetBalanceAt(userID int64, at time.Time) (int64, error) {
+	var balance int64
+	err := b.pool.QueryRow(b.ctx, `
+		WITH snapshot AS (
+			SELECT balance, taken_at
+			FROM balance_snapshots
+			WHERE user_id = $1 AND taken_at <= $2
+			ORDER BY taken_at DESC
+			LIMIT 1
+		), since AS (
+			SELECT COALESCE((SELECT taken_at FROM snapshot), '-infinity'::timestamptz) AS taken_at
+		)
+		SELECT (COALESCE((SELECT balance FROM snapshot), 0)
+			+ COALESCE((
+				SELECT SUM(amount) FROM transactions
+				WHERE to_user_id = $1 AND type NOT IN ($3, $4)
+				  AND created_at > (SELECT taken_at FROM since) AND created_at <= $2
+			), 0)
+			- COALESCE((
+				SELECT SUM(amount - giftable_amount) FROM transactions
+				WHERE from_user_id = $1 AND created_at > (SELECT taken_at FROM since) AND created_at <= $2
+			), 0))::BIGINT
+	`, userID, at, models.TransactionTypeGift, models.TransactionTypeAllowance).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+
 	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -25,37 +27,321 @@ func (s *store) Coin() storage.ICoin {
 	return s.coin
 }
 
-func (c *coinRepo) TransferCoins(fromUserID, toUserID int64, amount int64) error {
+func (c *coinRepo) TransferCoins(transfer models.Transaction, limits models.TransferLimits) error {
 	tx, err := c.pool.Begin(c.ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(c.ctx)
 
-	_, err = tx.Exec(c.ctx, `UPDATE users SET coins = coins - $1 WHERE id = $2 AND coins >= $1`, amount, fromUserID)
-	if err != nil {
+	if err = checkLimitsTx(c.ctx, tx, transfer.FromUserID, limits, transfer.Amount); err != nil {
+		return err
+	}
+
+	if _, err = transferTx(c.ctx, tx, transfer); err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(c.ctx, `UPDATE users SET coins = coins + $1 WHERE id = $2`, amount, toUserID)
+	return tx.Commit(c.ctx)
+}
+
+// TransferBatch executes all transfers in one database transaction: either
+// every recipient is paid or none is. All involved rows are locked up front in
+// id order so that concurrent batches cannot deadlock each other.
+func (c *coinRepo) TransferBatch(transfers []models.Transaction, limits models.TransferLimits) ([]models.Transaction, error) {
+	tx, err := c.pool.Begin(c.ctx)
 	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(c.ctx)
+
+	userIDs := make([]int64, 0, 2*len(transfers))
+	for _, transfer := range transfers {
+		userIDs = append(userIDs, transfer.FromUserID, transfer.ToUserID)
+	}
+	if _, err = tx.Exec(c.ctx, `SELECT id FROM users WHERE id = ANY($1) ORDER BY id FOR UPDATE`, userIDs); err != nil {
+		return nil, err
+	}
+
+	amounts := make([]int64, 0, len(transfers))
+	for _, transfer := range transfers {
+		amounts = append(amounts, transfer.Amount)
+	}
+	if len(transfers) > 0 {
+		if err = checkLimitsTx(c.ctx, tx, transfers[0].FromUserID, limits, amounts...); err != nil {
+			return nil, err
+		}
+	}
+
+	completed := make([]models.Transaction, 0, len(transfers))
+	for _, transfer := range transfers {
+		transfer, err = transferTx(c.ctx, tx, transfer)
+		if err != nil {
+			return nil, err
+		}
+		completed = append(completed, transfer)
+	}
+
+	if err = tx.Commit(c.ctx); err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+func (c *coinRepo) GetTransferUsage(userID int64) (models.TransferUsage, error) {
+	return transferUsage(c.ctx, c.pool, userID)
+}
+
+// checkLimitsTx locks the sender and verifies that the given amounts fit the
+// sender's limits. The lock serialises concurrent transfers from the same
+// user, so two requests cannot both pass the check against the same usage.
+func checkLimitsTx(ctx context.Context, tx pgx.Tx, userID int64, limits models.TransferLimits, amounts ...int64) error {
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(c.ctx, `
-		INSERT INTO transactions (from_user_id, to_user_id, amount, type, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
-	`, fromUserID, toUserID, amount, models.TransactionTypeTransfer)
+	var total int64
+	for _, amount := range amounts {
+		if limits.PerTransfer > 0 && amount > limits.PerTransfer {
+			return &storage.LimitExceededError{
+				Limit:     storage.LimitPerTransfer,
+				Max:       limits.PerTransfer,
+				Remaining: limits.PerTransfer,
+			}
+		}
+		total += amount
+	}
+
+	if limits.Daily <= 0 && limits.Monthly <= 0 {
+		return nil
+	}
+
+	usage, err := transferUsage(ctx, tx, userID)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit(c.ctx)
+	if limits.Daily > 0 && usage.Daily+total > limits.Daily {
+		return &storage.LimitExceededError{
+			Limit:     storage.LimitDaily,
+			Max:       limits.Daily,
+			Remaining: max(limits.Daily-usage.Daily, 0),
+		}
+	}
+	if limits.Monthly > 0 && usage.Monthly+total > limits.Monthly {
+		return &storage.LimitExceededError{
+			Limit:     storage.LimitMonthly,
+			Max:       limits.Monthly,
+			Remaining: max(limits.Monthly-usage.Monthly, 0),
+		}
+	}
+
+	return nil
+}
+
+// transferUsage sums the user's outgoing peer transfers and marketplace
+// purchases for the current day and month, including transfers still waiting
+// for approval. Grants, clawbacks, reversals and shop purchases do not count.
+func transferUsage(ctx context.Context, q querier, userID int64) (models.TransferUsage, error) {
+	var usage models.TransferUsage
+	err := q.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE created_at >= date_trunc('day', NOW())), 0)::BIGINT,
+			COALESCE(SUM(amount), 0)::BIGINT
+		FROM (
+			SELECT amount, created_at
+			FROM transactions
+			WHERE from_user_id = $1 AND type IN ($2, $4) AND created_at >= date_trunc('month', NOW())
+			UNION ALL
+			SELECT amount, created_at
+			FROM pending_transfers
+			WHERE from_user_id = $1 AND status = $3 AND created_at >= date_trunc('month', NOW())
+		) sent
+	`, userID, models.TransactionTypeTransfer, models.PendingTransferStatusPending, models.TransactionTypeMarketplaceSale).
+		Scan(&usage.Daily, &usage.Monthly)
+	if err != nil {
+		return models.TransferUsage{}, err
+	}
+
+	return usage, nil
+}
+
+// transferTx moves coins between two users inside an existing transaction so
+// that other repositories can combine a transfer with their own writes.
+// Peer transfers are paid from the giftable allowance first; other types, such
+// as reversals, only move spendable coins. A reversal puts the coins back into
+// the lots the original transfer took them from.
+func transferTx(ctx context.Context, tx pgx.Tx, transfer models.Transaction) (models.Transaction, error) {
+	if transfer.Type == "" {
+		transfer.Type = models.TransactionTypeTransfer
+	}
+
+	var debits lotDebits
+	var err error
+	if transfer.Type == models.TransactionTypeTransfer {
+		transfer.GiftableAmount, debits, err = debitGiftableFirstTx(ctx, tx, transfer.FromUserID, transfer.Amount)
+	} else {
+		debits, err = debitTx(ctx, tx, transfer.FromUserID, transfer.Amount)
+	}
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if transfer.Type == models.TransactionTypeReversal && transfer.OriginalTransactionID != nil {
+		err = restoreLotsTx(ctx, tx, transfer.ToUserID, transfer.Amount, debitOfTransaction, *transfer.OriginalTransactionID)
+	} else {
+		err = creditTx(ctx, tx, transfer.ToUserID, transfer.Amount)
+	}
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	transfer, err = insertTransactionTx(ctx, tx, transfer)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = recordLotDebitsTx(ctx, tx, debits, debitOfTransaction, transfer.ID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if transfer.Type == models.TransactionTypeTransfer {
+		if err = recordEventTx(ctx, tx, models.EventCoinTransferred, transfer); err != nil {
+			return models.Transaction{}, err
+		}
+	}
+
+	return transfer, nil
+}
+
+// insertTransactionTx records a ledger entry. Zero user ids are stored as NULL
+// for entries that have only one side, such as purchases and grants.
+func insertTransactionTx(ctx context.Context, tx pgx.Tx, t models.Transaction) (models.Transaction, error) {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO transactions (
+			from_user_id, to_user_id, amount, giftable_amount, type, merch_id, variant_id, original_amount,
+			promotion_id, memo, category, reason, created_by, original_transaction_id, created_at
+		)
+		VALUES (
+			NULLIF($1::BIGINT, 0), NULLIF($2::BIGINT, 0), $3, $4, $5, $6, $7, $8,
+			$9, NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), $13, $14, NOW()
+		)
+		RETURNING id, created_at
+	`, t.FromUserID, t.ToUserID, t.Amount, t.GiftableAmount, t.Type, t.MerchID, t.VariantID, t.OriginalAmount,
+		t.PromotionID, t.Memo, string(t.Category), t.Reason, t.CreatedBy, t.OriginalTransactionID,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	return t, nil
+}
+
+// Grant mints coins for every user in one transaction and records who did it
+// and why.
+func (c *coinRepo) Grant(actorID int64, userIDs []int64, amount int64, reason string) ([]models.Transaction, error) {
+	tx, err := c.pool.Begin(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(c.ctx)
+
+	grants := make([]models.Transaction, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if err := creditTx(c.ctx, tx, userID, amount); err != nil {
+			return nil, err
+		}
+
+		grant, err := insertTransactionTx(c.ctx, tx, models.Transaction{
+			ToUserID:  userID,
+			Amount:    amount,
+			Type:      models.TransactionTypeGrant,
+			Reason:    reason,
+			CreatedBy: &actorID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+
+	if err = tx.Commit(c.ctx); err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+// Clawback debits coins credited by mistake. It never takes a balance below
+// zero.
+func (c *coinRepo) Clawback(actorID, userID int64, amount int64, reason string) (models.Transaction, error) {
+	tx, err := c.pool.Begin(c.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(c.ctx)
+
+	if _, err = debitTx(c.ctx, tx, userID, amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	clawback, err := insertTransactionTx(c.ctx, tx, models.Transaction{
+		FromUserID: userID,
+		Amount:     amount,
+		Type:       models.TransactionTypeClawback,
+		Reason:     reason,
+		CreatedBy:  &actorID,
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(c.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return clawback, nil
+}
+
+func (c *coinRepo) GetAdjustments(limit, offset int) ([]models.TransactionDetails, error) {
+	rows, err := c.pool.Query(c.ctx, `
+		SELECT t.id, COALESCE(t.from_user_id, 0), COALESCE(t.to_user_id, 0), t.amount, t.type,
+		       COALESCE(t.reason, ''), t.created_by, t.created_at,
+		       COALESCE(fu.username, ''), COALESCE(tu.username, ''), COALESCE(cb.username, '')
+		FROM transactions t
+		LEFT JOIN users fu ON fu.id = t.from_user_id
+		LEFT JOIN users tu ON tu.id = t.to_user_id
+		LEFT JOIN users cb ON cb.id = t.created_by
+		WHERE t.type IN ('grant', 'clawback')
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var adjustments []models.TransactionDetails
+	for rows.Next() {
+		var t models.TransactionDetails
+		if err := rows.Scan(
+			&t.ID, &t.FromUserID, &t.ToUserID, &t.Amount, &t.Type,
+			&t.Reason, &t.CreatedBy, &t.CreatedAt,
+			&t.FromUsername, &t.ToUsername, &t.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, t)
+	}
+
+	return adjustments, rows.Err()
 }
 
 func (c *coinRepo) GetUserTransactions(userID int64) ([]models.Transaction, error) {
 	rows, err := c.pool.Query(c.ctx, `
-        SELECT id, from_user_id, to_user_id, amount, type, merch_id, created_at 
+        SELECT id, COALESCE(from_user_id, 0), to_user_id, amount, type, merch_id,
+               COALESCE(memo, ''), COALESCE(category, ''), original_transaction_id, created_at
         FROM transactions 
         WHERE from_user_id = $1 OR to_user_id = $1 
         ORDER BY created_at DESC
@@ -77,6 +363,9 @@ func (c *coinRepo) GetUserTransactions(userID int64) ([]models.Transaction, erro
 			&t.Amount,
 			&t.Type,
 			&t.MerchID,
+			&t.Memo,
+			&t.Category,
+			&t.OriginalTransactionID,
 			&t.CreatedAt,
 		)
 		if err != nil {
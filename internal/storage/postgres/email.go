@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type emailRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newEmailRepo(ctx context.Context, pool *pgxpool.Pool) *emailRepo {
+	return &emailRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Email() storage.IEmail {
+	return s.email
+}
+
+func (e *emailRepo) Enqueue(userID int64, template string, data []byte) (bool, error) {
+	tag, err := e.pool.Exec(e.ctx, `
+		INSERT INTO emails (user_id, template, data)
+		SELECT id, $2, $3::JSONB
+		FROM users
+		WHERE id = $1 AND email IS NOT NULL AND NOT email_opt_out
+	`, userID, template, string(data))
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimDue skips emails of users who opted out after they were queued; those
+// stay pending until the user opts in again.
+func (e *emailRepo) ClaimDue(limit int, lease time.Duration) ([]models.QueuedEmailDetails, error) {
+	rows, err := e.pool.Query(e.ctx, `
+		WITH due AS (
+			SELECT m.id
+			FROM emails m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.status = $1 AND m.next_attempt_at <= NOW() AND u.email IS NOT NULL AND NOT u.email_opt_out
+			ORDER BY m.next_attempt_at
+			LIMIT $2
+			FOR UPDATE OF m SKIP LOCKED
+		)
+		UPDATE emails m
+		SET next_attempt_at = $3
+		FROM due, users u
+		WHERE m.id = due.id AND u.id = m.user_id
+		RETURNING m.id, m.user_id, m.template, m.data, m.status, m.attempts, m.next_attempt_at,
+			COALESCE(m.last_error, ''), m.created_at, m.sent_at, u.email
+	`, models.EmailStatusPending, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []models.QueuedEmailDetails
+	for rows.Next() {
+		var m models.QueuedEmailDetails
+		err := rows.Scan(&m.ID, &m.UserID, &m.Template, &m.Data, &m.Status, &m.Attempts, &m.NextAttemptAt,
+			&m.LastError, &m.CreatedAt, &m.SentAt, &m.To)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, m)
+	}
+
+	return emails, rows.Err()
+}
+
+func (e *emailRepo) MarkSent(emailID int64) error {
+	_, err := e.pool.Exec(e.ctx, `
+		UPDATE emails
+		SET status = $1, attempts = attempts + 1, last_error = NULL, sent_at = NOW()
+		WHERE id = $2
+	`, models.EmailStatusSent, emailID)
+	return err
+}
+
+func (e *emailRepo) MarkFailed(emailID int64, lastError string, retryAt *time.Time) error {
+	status := models.EmailStatusPending
+	if retryAt == nil {
+		status = models.EmailStatusFailed
+	}
+
+	_, err := e.pool.Exec(e.ctx, `
+		UPDATE emails
+		SET status = $1, attempts = attempts + 1, last_error = $2, next_attempt_at = COALESCE($3, next_attempt_at)
+		WHERE id = $4
+	`, status, lastError, retryAt, emailID)
+	return err
+}
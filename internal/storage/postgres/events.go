@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const eventsChannel = "avito_shop_events"
+
+type eventsRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newEventsRepo(ctx context.Context, pool *pgxpool.Pool) *eventsRepo {
+	return &eventsRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Events() storage.IEvents {
+	return s.events
+}
+
+func (e *eventsRepo) Publish(payload []byte) error {
+	_, err := e.pool.Exec(e.ctx, `SELECT pg_notify($1, $2)`, eventsChannel, string(payload))
+	return err
+}
+
+// Listen takes a connection out of the pool for good, so that a LISTEN never
+// leaks to other queries, and closes it when done.
+func (e *eventsRepo) Listen(ctx context.Context, handle func(payload []byte)) error {
+	pooled, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle([]byte(notification.Payload))
+	}
+}
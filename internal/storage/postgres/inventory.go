@@ -3,13 +3,20 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const merchQuery = `
+	SELECT id, name, price, stock, COALESCE(category, ''),
+	       available_from, available_until, per_user_limit, preorders_open, cancelled_at
+	FROM merch`
+
 type inventoryRepo struct {
 	ctx  context.Context
 	pool *pgxpool.Pool
@@ -26,61 +33,183 @@ func (s *store) Inventory() storage.IInventory {
 	return s.inventory
 }
 
-func (i *inventoryRepo) BuyItem(userID, merchID int64) error {
+// BuyItem buys one unit of a merch item. Items with variants are bought by
+// variant, and the stock of the variant is used instead of the item's own.
+// When a promotion is given it is checked again under lock and the discounted
+// price is charged; ErrNotApplicable means it no longer applies. Items outside
+// their sale window fail with ErrNotAvailable and buyers over the item's cap
+// with ErrPurchaseLimit.
+func (i *inventoryRepo) BuyItem(userID, merchID int64, variantID, promotionID *int64) error {
+	_, err := i.checkout(models.Transaction{
+		FromUserID:  userID,
+		Type:        models.TransactionTypePurchase,
+		MerchID:     &merchID,
+		VariantID:   variantID,
+		PromotionID: promotionID,
+	})
+	return err
+}
+
+// GiftItem buys an item on behalf of another user: the sender pays and the
+// item lands in the recipient's inventory, with an order in their name. It
+// fails the same way BuyItem does.
+func (i *inventoryRepo) GiftItem(gift models.Transaction) (models.Transaction, error) {
+	gift.Type = models.TransactionTypeGift
+	return i.checkout(gift)
+}
+
+// TransferItem hands one owned unit of an item to another user. It returns
+// ErrNotFound when the sender does not hold the item.
+func (i *inventoryRepo) TransferItem(transfer models.Transaction) (models.Transaction, error) {
 	tx, err := i.pool.Begin(i.ctx)
 	if err != nil {
-		return err
+		return models.Transaction{}, err
 	}
 	defer tx.Rollback(i.ctx)
 
-	var price int64
-	err = tx.QueryRow(i.ctx, "SELECT price FROM merch WHERE id = $1", merchID).Scan(&price)
+	if err = takeInventoryTx(i.ctx, tx, transfer.FromUserID, *transfer.MerchID, transfer.VariantID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = addInventoryTx(i.ctx, tx, transfer.ToUserID, *transfer.MerchID, transfer.VariantID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	transfer.Type = models.TransactionTypeItemTransfer
+	transfer.Amount = 0
+	transfer, err = insertTransactionTx(i.ctx, tx, transfer)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return errors.New("merch not found")
-		}
-		return err
+		return models.Transaction{}, err
 	}
 
-	var userCoins int64
-	err = tx.QueryRow(i.ctx, "SELECT coins FROM users WHERE id = $1", userID).Scan(&userCoins)
+	if err = tx.Commit(i.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return transfer, nil
+}
+
+// checkout charges t.FromUserID for one unit of the item and delivers it to
+// the owner of the purchase. The per-user cap applies to the owner.
+func (i *inventoryRepo) checkout(t models.Transaction) (models.Transaction, error) {
+	tx, err := i.pool.Begin(i.ctx)
 	if err != nil {
-		return err
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(i.ctx)
+
+	merch, err := lockMerchTx(i.ctx, tx, *t.MerchID)
+	if err != nil {
+		return models.Transaction{}, err
 	}
-	if userCoins < price {
-		return errors.New("not enough coins")
+	if !merch.OnSale(time.Now()) {
+		return models.Transaction{}, storage.ErrNotAvailable
+	}
+	if err = checkPurchaseLimitTx(i.ctx, tx, t.Owner(), merch); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = takeStockTx(i.ctx, tx, merch, t.VariantID); err != nil {
+		return models.Transaction{}, err
 	}
 
-	_, err = tx.Exec(i.ctx, "UPDATE users SET coins = coins - $1 WHERE id = $2", price, userID)
+	var discount int64
+	t.Amount = merch.Price
+	if t.PromotionID != nil {
+		discount, err = applyPromotionTx(i.ctx, tx, t.FromUserID, merch.ID, *t.PromotionID, merch.Price)
+		if err != nil {
+			return models.Transaction{}, err
+		}
+		t.Amount, t.OriginalAmount = merch.Price-discount, &merch.Price
+	}
+
+	debits, err := debitTx(i.ctx, tx, t.FromUserID, t.Amount)
 	if err != nil {
-		return err
+		return models.Transaction{}, err
 	}
 
-	_, err = tx.Exec(i.ctx, `
-		INSERT INTO user_inventory (user_id, merch_id, quantity)
-		VALUES ($1, $2, 1)
-		ON CONFLICT (user_id, merch_id) DO UPDATE SET quantity = user_inventory.quantity + 1
-	`, userID, merchID)
+	t, err = placePurchaseTx(i.ctx, tx, t)
 	if err != nil {
-		return err
+		return models.Transaction{}, err
+	}
+	if err = recordLotDebitsTx(i.ctx, tx, debits, debitOfTransaction, t.ID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if discount > 0 {
+		_, err = tx.Exec(i.ctx, `
+			INSERT INTO promotion_redemptions (promotion_id, user_id, transaction_id, discount)
+			VALUES ($1, $2, $3, $4)
+		`, *t.PromotionID, t.FromUserID, t.ID, discount)
+		if err != nil {
+			return models.Transaction{}, err
+		}
+	}
+
+	if err = tx.Commit(i.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return t, nil
+}
+
+func (i *inventoryRepo) GetMerchByName(name string) (models.Merch, error) {
+	merch, err := scanMerch(i.pool.QueryRow(i.ctx, merchQuery+` WHERE name = $1`, name))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Merch{}, storage.ErrNotFound
+	}
+
+	return merch, err
+}
+
+// CreateMerch adds an item to the shop. It returns ErrConflict when the name
+// is already taken.
+func (i *inventoryRepo) CreateMerch(merch models.Merch) (models.Merch, error) {
+	err := i.pool.QueryRow(i.ctx, `
+		INSERT INTO merch (name, price, stock, category, available_from, available_until, per_user_limit, preorders_open)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8)
+		RETURNING id
+	`, merch.Name, merch.Price, merch.Stock, merch.Category, merch.AvailableFrom, merch.AvailableUntil,
+		merch.PerUserLimit, merch.PreordersOpen,
+	).Scan(&merch.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return models.Merch{}, storage.ErrConflict
+		}
+		return models.Merch{}, err
 	}
 
-	_, err = tx.Exec(i.ctx, `
-		INSERT INTO transactions (from_user_id, to_user_id, amount, type, merch_id)
-		VALUES ($1, NULL, $2, 'purchase', $3)
-	`, userID, price, merchID)
+	return merch, nil
+}
+
+// UpdateAvailability replaces the sale window, the per-user cap and the
+// pre-order flag of an item that has not been cancelled.
+func (i *inventoryRepo) UpdateAvailability(merch models.Merch) error {
+	tag, err := i.pool.Exec(i.ctx, `
+		UPDATE merch
+		SET available_from = $1, available_until = $2, per_user_limit = $3, preorders_open = $4
+		WHERE id = $5 AND cancelled_at IS NULL
+	`, merch.AvailableFrom, merch.AvailableUntil, merch.PerUserLimit, merch.PreordersOpen, merch.ID)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
 
-	return tx.Commit(i.ctx)
+	return nil
 }
 
 func (i *inventoryRepo) GetUserInventory(userID int64) ([]models.UserInventory, error) {
 	rows, err := i.pool.Query(i.ctx, `
-		SELECT id, user_id, merch_id, quantity, created_at 
-		FROM user_inventory 
-		WHERE user_id = $1
+		SELECT i.id, i.user_id, i.merch_id, i.variant_id, i.quantity, i.created_at, m.name,
+		       COALESCE(v.sku, ''), COALESCE(v.size, ''), COALESCE(v.color, '')
+		FROM user_inventory i
+		JOIN merch m ON m.id = i.merch_id
+		LEFT JOIN merch_variants v ON v.id = i.variant_id
+		WHERE i.user_id = $1 AND i.quantity > 0
+		ORDER BY i.merch_id, v.sku
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -90,7 +219,10 @@ func (i *inventoryRepo) GetUserInventory(userID int64) ([]models.UserInventory,
 	var inventory []models.UserInventory
 	for rows.Next() {
 		var item models.UserInventory
-		if err := rows.Scan(&item.ID, &item.UserID, &item.MerchID, &item.Quantity, &item.CreatedAt); err != nil {
+		if err := rows.Scan(
+			&item.ID, &item.UserID, &item.MerchID, &item.VariantID, &item.Quantity, &item.CreatedAt, &item.MerchName,
+			&item.SKU, &item.Size, &item.Color,
+		); err != nil {
 			return nil, err
 		}
 		inventory = append(inventory, item)
@@ -98,3 +230,146 @@ func (i *inventoryRepo) GetUserInventory(userID int64) ([]models.UserInventory,
 
 	return inventory, nil
 }
+
+func lockMerchTx(ctx context.Context, tx pgx.Tx, merchID int64) (models.Merch, error) {
+	merch, err := scanMerch(tx.QueryRow(ctx, merchQuery+` WHERE id = $1 FOR UPDATE`, merchID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Merch{}, errors.New("merch not found")
+	}
+
+	return merch, err
+}
+
+// checkPurchaseLimitTx counts the user's orders and open pre-orders of the
+// item against its per-user cap. The item row must be locked by the caller.
+func checkPurchaseLimitTx(ctx context.Context, tx pgx.Tx, userID int64, merch models.Merch) error {
+	if merch.PerUserLimit == nil {
+		return nil
+	}
+
+	var bought int64
+	err := tx.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM orders WHERE user_id = $1 AND merch_id = $2 AND status <> $3)
+		     + (SELECT COUNT(*) FROM preorders WHERE user_id = $1 AND merch_id = $2 AND status = $4)
+	`, userID, merch.ID, models.OrderStatusCancelled, models.PreorderStatusReserved).Scan(&bought)
+	if err != nil {
+		return err
+	}
+	if bought >= *merch.PerUserLimit {
+		return storage.ErrPurchaseLimit
+	}
+
+	return nil
+}
+
+// takeStockTx takes one unit of the item, or of its variant, out of stock. It
+// returns ErrOutOfStock when none is left.
+func takeStockTx(ctx context.Context, tx pgx.Tx, merch models.Merch, variantID *int64) error {
+	stock := merch.Stock
+	if variantID != nil {
+		err := tx.QueryRow(ctx, `
+			SELECT stock FROM merch_variants WHERE id = $1 AND merch_id = $2 FOR UPDATE
+		`, *variantID, merch.ID).Scan(&stock)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New("merch variant not found")
+			}
+			return err
+		}
+	}
+	if stock == nil {
+		return nil
+	}
+	if *stock == 0 {
+		return storage.ErrOutOfStock
+	}
+
+	var err error
+	if variantID != nil {
+		_, err = tx.Exec(ctx, "UPDATE merch_variants SET stock = stock - 1 WHERE id = $1", *variantID)
+	} else {
+		_, err = tx.Exec(ctx, "UPDATE merch SET stock = stock - 1 WHERE id = $1", merch.ID)
+	}
+	return err
+}
+
+// restockTx puts one unit of an item or of its variant back into stock.
+// Items without a stock limit are left as they are.
+func restockTx(ctx context.Context, tx pgx.Tx, merchID int64, variantID *int64) error {
+	var err error
+	if variantID != nil {
+		_, err = tx.Exec(ctx, `UPDATE merch_variants SET stock = stock + 1 WHERE id = $1 AND stock IS NOT NULL`, *variantID)
+	} else {
+		_, err = tx.Exec(ctx, `UPDATE merch SET stock = stock + 1 WHERE id = $1 AND stock IS NOT NULL`, merchID)
+	}
+	return err
+}
+
+// placePurchaseTx hands a paid item to its owner, the buyer or the recipient
+// of a gift: it adds the item to their inventory, records the purchase and
+// opens an order for it. The coins must already be taken by the caller.
+func placePurchaseTx(ctx context.Context, tx pgx.Tx, purchase models.Transaction) (models.Transaction, error) {
+	ownerID := purchase.Owner()
+	if err := addInventoryTx(ctx, tx, ownerID, *purchase.MerchID, purchase.VariantID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if purchase.Type == "" {
+		purchase.Type = models.TransactionTypePurchase
+	}
+	purchase, err := insertTransactionTx(ctx, tx, purchase)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO orders (user_id, merch_id, variant_id, purchase_transaction_id, status)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ownerID, purchase.MerchID, purchase.VariantID, purchase.ID, models.OrderStatusPlaced)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = recordEventTx(ctx, tx, models.EventMerchPurchased, purchase); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return purchase, nil
+}
+
+func addInventoryTx(ctx context.Context, tx pgx.Tx, userID, merchID int64, variantID *int64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO user_inventory (user_id, merch_id, variant_id, quantity)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (user_id, merch_id, COALESCE(variant_id, 0))
+			DO UPDATE SET quantity = user_inventory.quantity + 1
+	`, userID, merchID, variantID)
+	return err
+}
+
+// takeInventoryTx removes one unit of an item from a user's inventory. It
+// returns ErrNotFound when the user does not hold the item.
+func takeInventoryTx(ctx context.Context, tx pgx.Tx, userID, merchID int64, variantID *int64) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_inventory
+		SET quantity = quantity - 1
+		WHERE user_id = $1 AND merch_id = $2 AND variant_id IS NOT DISTINCT FROM $3 AND quantity > 0
+	`, userID, merchID, variantID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanMerch(row pgx.Row) (models.Merch, error) {
+	var m models.Merch
+	err := row.Scan(
+		&m.ID, &m.Name, &m.Price, &m.Stock, &m.Category,
+		&m.AvailableFrom, &m.AvailableUntil, &m.PerUserLimit, &m.PreordersOpen, &m.CancelledAt,
+	)
+	return m, err
+}
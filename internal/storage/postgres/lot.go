@@ -0,0 +1,302 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// Every coin on a balance belongs to a lot created when it was received, and
+// users.coins always equals the sum of the user's remaining lot amounts.
+// Each helper updates the user row first, so the row lock serialises all lot
+// changes for that user.
+
+// creditTx adds coins to a user's balance as a new lot. Lots expire after
+// the period set by the coin_lots.expires_at default.
+func creditTx(ctx context.Context, tx pgx.Tx, userID, amount int64) error {
+	tag, err := tx.Exec(ctx, `UPDATE users SET coins = coins + $1 WHERE id = $2`, amount, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO coin_lots (user_id, amount, remaining) VALUES ($1, $2, $2)`, userID, amount)
+	return err
+}
+
+// lotDebits lists the lot shares taken by one debit so that the coins can be
+// put back into the same lots if the operation is undone.
+type lotDebits struct {
+	lotIDs  []int64
+	amounts []int64
+}
+
+// Debits are recorded against the row that consumed them. The column names
+// are constants and never user input.
+const (
+	debitOfTransaction     = "transaction_id"
+	debitOfPendingTransfer = "pending_transfer_id"
+	debitOfPreorder        = "preorder_id"
+)
+
+// debitTx takes coins from a user's oldest unexpired lots first. Lots that
+// are past their expiry but not yet processed by the expiry job cannot be
+// spent, so a balance made up of them counts as insufficient funds.
+func debitTx(ctx context.Context, tx pgx.Tx, userID, amount int64) (lotDebits, error) {
+	tag, err := tx.Exec(ctx, `UPDATE users SET coins = coins - $1 WHERE id = $2 AND coins >= $1`, amount, userID)
+	if err != nil {
+		return lotDebits{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return lotDebits{}, storage.ErrInsufficientFunds
+	}
+
+	rows, err := tx.Query(ctx, `
+		WITH ordered AS (
+			SELECT id, remaining,
+			       SUM(remaining) OVER (ORDER BY received_at, id) - remaining AS spent_before
+			FROM coin_lots
+			WHERE user_id = $1 AND remaining > 0 AND expires_at > NOW()
+		)
+		UPDATE coin_lots l
+		SET remaining = l.remaining - LEAST(o.remaining, $2 - o.spent_before)
+		FROM ordered o
+		WHERE l.id = o.id AND o.spent_before < $2
+		RETURNING l.id, LEAST(o.remaining, $2 - o.spent_before)
+	`, userID, amount)
+	if err != nil {
+		return lotDebits{}, err
+	}
+	defer rows.Close()
+
+	var debits lotDebits
+	var taken int64
+	for rows.Next() {
+		var lotID, share int64
+		if err := rows.Scan(&lotID, &share); err != nil {
+			return lotDebits{}, err
+		}
+		debits.lotIDs = append(debits.lotIDs, lotID)
+		debits.amounts = append(debits.amounts, share)
+		taken += share
+	}
+	if err = rows.Err(); err != nil {
+		return lotDebits{}, err
+	}
+	if taken < amount {
+		return lotDebits{}, storage.ErrInsufficientFunds
+	}
+
+	return debits, nil
+}
+
+// recordLotDebitsTx links the lot shares of a debit to the row that consumed
+// them, given by one of the debitOf columns.
+func recordLotDebitsTx(ctx context.Context, tx pgx.Tx, debits lotDebits, column string, id int64) error {
+	if len(debits.lotIDs) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO coin_lot_debits (lot_id, amount, `+column+`)
+		SELECT lot_id, amount, $3
+		FROM UNNEST($1::BIGINT[], $2::BIGINT[]) AS d (lot_id, amount)
+	`, debits.lotIDs, debits.amounts, id)
+	return err
+}
+
+// moveLotDebitsTx hands the recorded debits of a reservation over to the
+// transaction that settled it.
+func moveLotDebitsTx(ctx context.Context, tx pgx.Tx, column string, id, transactionID int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE coin_lot_debits
+		SET transaction_id = $2, `+column+` = NULL
+		WHERE `+column+` = $1 AND restored_at IS NULL
+	`, id, transactionID)
+	return err
+}
+
+// restoreLotsTx gives coins back to a user by putting the recorded debits of
+// the given row back into the lots they came from, so the coins keep their
+// original expiry. Whatever was not recorded, such as coins taken from the
+// giftable allowance, is credited as a new lot.
+func restoreLotsTx(ctx context.Context, tx pgx.Tx, userID, amount int64, column string, id int64) error {
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	var restored int64
+	err := tx.QueryRow(ctx, `
+		WITH debits AS (
+			UPDATE coin_lot_debits d
+			SET restored_at = NOW()
+			FROM coin_lots l
+			WHERE d.`+column+` = $1 AND d.restored_at IS NULL AND l.id = d.lot_id AND l.user_id = $2
+			RETURNING d.lot_id, d.amount
+		), refilled AS (
+			UPDATE coin_lots l
+			SET remaining = l.remaining + d.amount
+			FROM (SELECT lot_id, SUM(amount) AS amount FROM debits GROUP BY lot_id) d
+			WHERE l.id = d.lot_id
+		)
+		SELECT COALESCE(SUM(amount), 0)::BIGINT FROM debits
+	`, id, userID).Scan(&restored)
+	if err != nil {
+		return err
+	}
+
+	if restored > 0 {
+		if _, err = tx.Exec(ctx, `UPDATE users SET coins = coins + $1 WHERE id = $2`, restored, userID); err != nil {
+			return err
+		}
+	}
+
+	if rest := amount - restored; rest > 0 {
+		return creditTx(ctx, tx, userID, rest)
+	}
+
+	return nil
+}
+
+func (c *coinRepo) GetExpiringLots(userID int64, before time.Time) ([]models.CoinLot, error) {
+	rows, err := c.pool.Query(c.ctx, `
+		SELECT id, user_id, amount, remaining, received_at, expires_at
+		FROM coin_lots
+		WHERE user_id = $1 AND remaining > 0 AND expires_at <= $2
+		ORDER BY expires_at
+	`, userID, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.CoinLot
+	for rows.Next() {
+		var lot models.CoinLot
+		if err := rows.Scan(
+			&lot.ID, &lot.UserID, &lot.Amount, &lot.Remaining, &lot.ReceivedAt, &lot.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+
+	return lots, rows.Err()
+}
+
+func (c *coinRepo) GetUsersWithExpiredLots(now time.Time, limit int) ([]int64, error) {
+	rows, err := c.pool.Query(c.ctx, `
+		SELECT DISTINCT user_id
+		FROM coin_lots
+		WHERE remaining > 0 AND expires_at <= $1
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// ExpireLots writes off what remains of the user's expired lots and posts a
+// single expiry transaction for the total. It returns ErrNotFound when there
+// is nothing left to expire, e.g. because the lots were spent meanwhile.
+func (c *coinRepo) ExpireLots(userID int64, now time.Time) (models.Transaction, error) {
+	tx, err := c.pool.Begin(c.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(c.ctx)
+
+	if _, err = tx.Exec(c.ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	var amount int64
+	err = tx.QueryRow(c.ctx, `
+		WITH expired AS (
+			SELECT id, remaining
+			FROM coin_lots
+			WHERE user_id = $1 AND remaining > 0 AND expires_at <= $2
+		), written_off AS (
+			UPDATE coin_lots l
+			SET remaining = 0
+			FROM expired e
+			WHERE l.id = e.id
+		)
+		SELECT COALESCE(SUM(remaining), 0)::BIGINT FROM expired
+	`, userID, now).Scan(&amount)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if amount == 0 {
+		return models.Transaction{}, storage.ErrNotFound
+	}
+
+	if _, err = tx.Exec(c.ctx, `UPDATE users SET coins = coins - $1 WHERE id = $2`, amount, userID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	expiry, err := insertTransactionTx(c.ctx, tx, models.Transaction{
+		FromUserID: userID,
+		Amount:     amount,
+		Type:       models.TransactionTypeExpiry,
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(c.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return expiry, nil
+}
+
+// debitGiftableFirstTx pays a peer transfer from the sender's giftable
+// allowance first and takes only the rest from spendable coins. It returns the
+// part paid from the allowance and the lot shares of the rest.
+func debitGiftableFirstTx(ctx context.Context, tx pgx.Tx, userID, amount int64) (int64, lotDebits, error) {
+	var giftable, coins int64
+	err := tx.QueryRow(ctx, `SELECT giftable_coins, coins FROM users WHERE id = $1 FOR UPDATE`, userID).
+		Scan(&giftable, &coins)
+	if err != nil {
+		return 0, lotDebits{}, err
+	}
+
+	fromGiftable := min(giftable, amount)
+	if amount-fromGiftable > coins {
+		return 0, lotDebits{}, storage.ErrInsufficientFunds
+	}
+
+	if fromGiftable > 0 {
+		_, err = tx.Exec(ctx, `UPDATE users SET giftable_coins = giftable_coins - $1 WHERE id = $2`, fromGiftable, userID)
+		if err != nil {
+			return 0, lotDebits{}, err
+		}
+	}
+
+	var debits lotDebits
+	if rest := amount - fromGiftable; rest > 0 {
+		if debits, err = debitTx(ctx, tx, userID, rest); err != nil {
+			return 0, lotDebits{}, err
+		}
+	}
+
+	return fromGiftable, debits, nil
+}
@@ -0,0 +1,306 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultListingsLimit = 50
+
+const listingDetailsQuery = `
+	SELECT l.id, l.seller_id, l.merch_id, l.variant_id, l.price, l.status, l.buyer_id, l.sale_transaction_id,
+	       l.created_at, l.closed_at, s.username, COALESCE(b.username, ''), m.name, COALESCE(m.category, ''),
+	       COALESCE(v.sku, ''), COALESCE(v.size, ''), COALESCE(v.color, '')
+	FROM listings l
+	JOIN users s ON s.id = l.seller_id
+	LEFT JOIN users b ON b.id = l.buyer_id
+	JOIN merch m ON m.id = l.merch_id
+	LEFT JOIN merch_variants v ON v.id = l.variant_id`
+
+type marketplaceRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newMarketplaceRepo(ctx context.Context, pool *pgxpool.Pool) *marketplaceRepo {
+	return &marketplaceRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Marketplace() storage.IMarketplace {
+	return s.marketplace
+}
+
+func (m *marketplaceRepo) Create(listing models.Listing) (models.Listing, error) {
+	tx, err := m.pool.Begin(m.ctx)
+	if err != nil {
+		return models.Listing{}, err
+	}
+	defer tx.Rollback(m.ctx)
+
+	if err = takeInventoryTx(m.ctx, tx, listing.SellerID, listing.MerchID, listing.VariantID); err != nil {
+		return models.Listing{}, err
+	}
+
+	err = tx.QueryRow(m.ctx, `
+		INSERT INTO listings (seller_id, merch_id, variant_id, price, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at
+	`, listing.SellerID, listing.MerchID, listing.VariantID, listing.Price, models.ListingStatusActive,
+	).Scan(&listing.ID, &listing.Status, &listing.CreatedAt)
+	if err != nil {
+		return models.Listing{}, err
+	}
+
+	if err = tx.Commit(m.ctx); err != nil {
+		return models.Listing{}, err
+	}
+
+	return listing, nil
+}
+
+func (m *marketplaceRepo) GetByID(listingID int64) (models.ListingDetails, error) {
+	rows, err := m.pool.Query(m.ctx, listingDetailsQuery+` WHERE l.id = $1`, listingID)
+	if err != nil {
+		return models.ListingDetails{}, err
+	}
+
+	listings, err := scanListings(rows)
+	if err != nil {
+		return models.ListingDetails{}, err
+	}
+	if len(listings) == 0 {
+		return models.ListingDetails{}, storage.ErrNotFound
+	}
+
+	return listings[0], nil
+}
+
+// Search lists active listings, cheapest first.
+func (m *marketplaceRepo) Search(filter storage.ListingFilter) ([]models.ListingDetails, error) {
+	var (
+		conditions = []string{"l.status = $1"}
+		args       = []interface{}{models.ListingStatusActive}
+	)
+
+	if filter.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf("(m.name ILIKE $%d OR s.username ILIKE $%d)", n, n))
+	}
+	if filter.Item != "" {
+		args = append(args, filter.Item)
+		conditions = append(conditions, fmt.Sprintf("m.name = $%d", len(args)))
+	}
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		conditions = append(conditions, fmt.Sprintf("m.category = $%d", len(args)))
+	}
+	if filter.MinPrice > 0 {
+		args = append(args, filter.MinPrice)
+		conditions = append(conditions, fmt.Sprintf("l.price >= $%d", len(args)))
+	}
+	if filter.MaxPrice > 0 {
+		args = append(args, filter.MaxPrice)
+		conditions = append(conditions, fmt.Sprintf("l.price <= $%d", len(args)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListingsLimit
+	}
+	args = append(args, limit, filter.Offset)
+
+	rows, err := m.pool.Query(m.ctx, fmt.Sprintf(listingDetailsQuery+`
+		WHERE %s
+		ORDER BY l.price, l.created_at
+		LIMIT $%d OFFSET $%d
+	`, strings.Join(conditions, " AND "), len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanListings(rows)
+}
+
+func (m *marketplaceRepo) GetUserListings(userID int64) ([]models.ListingDetails, error) {
+	rows, err := m.pool.Query(m.ctx, listingDetailsQuery+`
+		WHERE l.seller_id = $1
+		ORDER BY l.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanListings(rows)
+}
+
+// Cancel withdraws an active listing and returns the item to the seller.
+func (m *marketplaceRepo) Cancel(listingID, sellerID int64) error {
+	tx, err := m.pool.Begin(m.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(m.ctx)
+
+	var listing models.Listing
+	err = tx.QueryRow(m.ctx, `
+		UPDATE listings
+		SET status = $1, closed_at = NOW()
+		WHERE id = $2 AND seller_id = $3 AND status = $4
+		RETURNING merch_id, variant_id
+	`, models.ListingStatusCancelled, listingID, sellerID, models.ListingStatusActive,
+	).Scan(&listing.MerchID, &listing.VariantID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+
+	if err = addInventoryTx(m.ctx, tx, sellerID, listing.MerchID, listing.VariantID); err != nil {
+		return err
+	}
+
+	return tx.Commit(m.ctx)
+}
+
+// Buy sells an active listing to the buyer: the coins go to the seller, less
+// the fee that goes to the system account, and the item goes to the buyer. The
+// price counts against the buyer's transfer limits, and the sale is recorded in
+// the outbox as a coin transfer. It returns ErrNotFound
+// when the listing is no longer active and ErrConflict when the buyer is the
+// seller.
+func (m *marketplaceRepo) Buy(listingID, buyerID, feePercent int64, limits models.TransferLimits) (models.Transaction, error) {
+	tx, err := m.pool.Begin(m.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(m.ctx)
+
+	var listing models.Listing
+	err = tx.QueryRow(m.ctx, `
+		SELECT id, seller_id, merch_id, variant_id, price
+		FROM listings
+		WHERE id = $1 AND status = $2
+		FOR UPDATE
+	`, listingID, models.ListingStatusActive).
+		Scan(&listing.ID, &listing.SellerID, &listing.MerchID, &listing.VariantID, &listing.Price)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, storage.ErrNotFound
+		}
+		return models.Transaction{}, err
+	}
+	if listing.SellerID == buyerID {
+		return models.Transaction{}, storage.ErrConflict
+	}
+
+	if err = checkLimitsTx(m.ctx, tx, buyerID, limits, listing.Price); err != nil {
+		return models.Transaction{}, err
+	}
+
+	debits, err := debitTx(m.ctx, tx, buyerID, listing.Price)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	fee := listing.Fee(feePercent)
+	if proceeds := listing.Price - fee; proceeds > 0 {
+		if err = creditTx(m.ctx, tx, listing.SellerID, proceeds); err != nil {
+			return models.Transaction{}, err
+		}
+	}
+
+	sale, err := insertTransactionTx(m.ctx, tx, models.Transaction{
+		FromUserID: buyerID,
+		ToUserID:   listing.SellerID,
+		Amount:     listing.Price,
+		Type:       models.TransactionTypeMarketplaceSale,
+		MerchID:    &listing.MerchID,
+		VariantID:  listing.VariantID,
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = recordLotDebitsTx(m.ctx, tx, debits, debitOfTransaction, sale.ID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = recordEventTx(m.ctx, tx, models.EventCoinTransferred, sale); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if fee > 0 {
+		var systemID int64
+		err = tx.QueryRow(m.ctx, `SELECT id FROM users WHERE role = $1 ORDER BY id LIMIT 1`, models.RoleSystem).
+			Scan(&systemID)
+		if err != nil {
+			return models.Transaction{}, fmt.Errorf("system account: %w", err)
+		}
+
+		if err = creditTx(m.ctx, tx, systemID, fee); err != nil {
+			return models.Transaction{}, err
+		}
+
+		_, err = insertTransactionTx(m.ctx, tx, models.Transaction{
+			FromUserID:            listing.SellerID,
+			ToUserID:              systemID,
+			Amount:                fee,
+			Type:                  models.TransactionTypeMarketplaceFee,
+			MerchID:               &listing.MerchID,
+			OriginalTransactionID: &sale.ID,
+		})
+		if err != nil {
+			return models.Transaction{}, err
+		}
+	}
+
+	if err = addInventoryTx(m.ctx, tx, buyerID, listing.MerchID, listing.VariantID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(m.ctx, `
+		UPDATE listings
+		SET status = $1, buyer_id = $2, sale_transaction_id = $3, closed_at = NOW()
+		WHERE id = $4
+	`, models.ListingStatusSold, buyerID, sale.ID, listing.ID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(m.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return sale, nil
+}
+
+func scanListings(rows pgx.Rows) ([]models.ListingDetails, error) {
+	defer rows.Close()
+
+	var listings []models.ListingDetails
+	for rows.Next() {
+		var d models.ListingDetails
+		if err := rows.Scan(
+			&d.ID, &d.SellerID, &d.MerchID, &d.VariantID, &d.Price, &d.Status, &d.BuyerID, &d.SaleTransactionID,
+			&d.CreatedAt, &d.ClosedAt, &d.Seller, &d.Buyer, &d.MerchName, &d.Category,
+			&d.SKU, &d.Size, &d.Color,
+		); err != nil {
+			return nil, err
+		}
+		listings = append(listings, d)
+	}
+
+	return listings, rows.Err()
+}
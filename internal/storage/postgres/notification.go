@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultNotificationsLimit = 50
+
+type notificationRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newNotificationRepo(ctx context.Context, pool *pgxpool.Pool) *notificationRepo {
+	return &notificationRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Notification() storage.INotification {
+	return s.notification
+}
+
+func (n *notificationRepo) Create(notification models.Notification) (models.Notification, error) {
+	return insertNotification(n.ctx, n.pool, notification)
+}
+
+func (n *notificationRepo) GetUserNotifications(
+	userID int64,
+	filter storage.NotificationFilter,
+) ([]models.Notification, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultNotificationsLimit
+	}
+
+	rows, err := n.pool.Query(n.ctx, `
+		SELECT id, user_id, type, message, merch_id, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, filter.UnreadOnly, limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var m models.Notification
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Type, &m.Message, &m.MerchID, &m.CreatedAt, &m.ReadAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, m)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (n *notificationRepo) CountUnread(userID int64) (int64, error) {
+	var count int64
+	err := n.pool.QueryRow(n.ctx, `
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+	`, userID).Scan(&count)
+	return count, err
+}
+
+func (n *notificationRepo) MarkRead(userID int64, ids []int64) (int64, error) {
+	tag, err := n.pool.Exec(n.ctx, `
+		UPDATE notifications
+		SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL AND (cardinality($2::BIGINT[]) = 0 OR id = ANY($2))
+	`, userID, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (n *notificationRepo) GetMutedTypes(userID int64) ([]models.NotificationType, error) {
+	rows, err := n.pool.Query(n.ctx, `SELECT type FROM notification_mutes WHERE user_id = $1 ORDER BY type`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var types []models.NotificationType
+	for rows.Next() {
+		var t models.NotificationType
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+
+	return types, rows.Err()
+}
+
+// SetMutedTypes replaces the set of notification types the user muted.
+func (n *notificationRepo) SetMutedTypes(userID int64, types []models.NotificationType) error {
+	tx, err := n.pool.Begin(n.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(n.ctx)
+
+	if _, err = tx.Exec(n.ctx, `DELETE FROM notification_mutes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, t := range types {
+		_, err = tx.Exec(n.ctx, `
+			INSERT INTO notification_mutes (user_id, type) VALUES ($1, $2) ON CONFLICT DO NOTHING
+		`, userID, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(n.ctx)
+}
+
+// insertNotification stores a notification unless the user muted its type.
+// Called with a transaction, the notification exists exactly when the change
+// it reports is committed.
+func insertNotification(ctx context.Context, q querier, notification models.Notification) (models.Notification, error) {
+	err := q.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, type, message, merch_id)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM notification_mutes WHERE user_id = $1 AND type = $2)
+		RETURNING id, created_at
+	`, notification.UserID, notification.Type, notification.Message, notification.MerchID,
+	).Scan(&notification.ID, &notification.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Notification{}, nil
+	}
+	return notification, err
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const orderDetailsQuery = `
+	SELECT o.id, o.user_id, o.merch_id, o.variant_id, o.purchase_transaction_id, o.status, o.refund_transaction_id,
+	       o.created_at, o.updated_at, t.amount, u.username, m.name,
+	       COALESCE(v.sku, ''), COALESCE(v.size, ''), COALESCE(v.color, '')
+	FROM orders o
+	JOIN transactions t ON t.id = o.purchase_transaction_id
+	JOIN users u ON u.id = o.user_id
+	JOIN merch m ON m.id = o.merch_id
+	LEFT JOIN merch_variants v ON v.id = o.variant_id`
+
+type orderRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newOrderRepo(ctx context.Context, pool *pgxpool.Pool) *orderRepo {
+	return &orderRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Order() storage.IOrder {
+	return s.order
+}
+
+func (o *orderRepo) GetByID(orderID int64) (models.OrderDetails, error) {
+	rows, err := o.pool.Query(o.ctx, orderDetailsQuery+` WHERE o.id = $1`, orderID)
+	if err != nil {
+		return models.OrderDetails{}, err
+	}
+
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return models.OrderDetails{}, err
+	}
+	if len(orders) == 0 {
+		return models.OrderDetails{}, storage.ErrNotFound
+	}
+
+	return orders[0], nil
+}
+
+func (o *orderRepo) GetUserOrders(userID int64) ([]models.OrderDetails, error) {
+	rows, err := o.pool.Query(o.ctx, orderDetailsQuery+`
+		WHERE o.user_id = $1
+		ORDER BY o.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOrders(rows)
+}
+
+// GetOrders lists orders in the given status, oldest first, so that the shop
+// works through them in the order they were placed.
+func (o *orderRepo) GetOrders(status models.OrderStatus, limit, offset int) ([]models.OrderDetails, error) {
+	rows, err := o.pool.Query(o.ctx, orderDetailsQuery+`
+		WHERE o.status = $1
+		ORDER BY o.created_at
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOrders(rows)
+}
+
+// UpdateStatus moves an order from one status to the next. It returns
+// ErrNotFound when the order is no longer in the expected status.
+func (o *orderRepo) UpdateStatus(orderID int64, from, to models.OrderStatus) error {
+	tag, err := o.pool.Exec(o.ctx, `UPDATE orders SET status = $1 WHERE id = $2 AND status = $3`, to, orderID, from)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// Cancel cancels an order that has not been handed over and refunds the
+// purchase in the same transaction.
+func (o *orderRepo) Cancel(orderID, actorID int64) (models.Transaction, error) {
+	tx, err := o.pool.Begin(o.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(o.ctx)
+
+	var purchase models.Transaction
+	err = tx.QueryRow(o.ctx, `
+		SELECT t.id, t.from_user_id, COALESCE(t.to_user_id, 0), t.amount, t.merch_id, t.variant_id
+		FROM orders o
+		JOIN transactions t ON t.id = o.purchase_transaction_id
+		WHERE o.id = $1 AND o.status IN ($2, $3)
+		FOR UPDATE OF o
+	`, orderID, models.OrderStatusPlaced, models.OrderStatusReadyForPickup).
+		Scan(&purchase.ID, &purchase.FromUserID, &purchase.ToUserID, &purchase.Amount, &purchase.MerchID, &purchase.VariantID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, storage.ErrNotFound
+		}
+		return models.Transaction{}, err
+	}
+
+	refund, err := refundPurchaseTx(o.ctx, tx, purchase, actorID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(o.ctx, `
+		UPDATE orders
+		SET status = $1, refund_transaction_id = $2
+		WHERE id = $3
+	`, models.OrderStatusCancelled, refund.ID, orderID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(o.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return refund, nil
+}
+
+func scanOrders(rows pgx.Rows) ([]models.OrderDetails, error) {
+	defer rows.Close()
+
+	var orders []models.OrderDetails
+	for rows.Next() {
+		var o models.OrderDetails
+		if err := rows.Scan(
+			&o.ID, &o.UserID, &o.MerchID, &o.VariantID, &o.PurchaseTransactionID, &o.Status, &o.RefundTransactionID,
+			&o.CreatedAt, &o.UpdatedAt, &o.Amount, &o.Username, &o.MerchName, &o.SKU, &o.Size, &o.Color,
+		); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+
+	return orders, rows.Err()
+}
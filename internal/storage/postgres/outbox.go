@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type outboxRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newOutboxRepo(ctx context.Context, pool *pgxpool.Pool) *outboxRepo {
+	return &outboxRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Outbox() storage.IOutbox {
+	return s.outbox
+}
+
+// ClaimDue leases the events without holding a transaction open, so slow sinks
+// keep no rows locked. Events of a replica that crashes midway are claimed
+// again once the lease runs out.
+func (o *outboxRepo) ClaimDue(limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	rows, err := o.pool.Query(o.ctx, `
+		WITH due AS (
+			SELECT id
+			FROM outbox_events
+			WHERE published_at IS NULL AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE outbox_events e
+			SET locked_until = $2
+			FROM due
+			WHERE e.id = due.id
+			RETURNING e.id, e.type, e.payload, e.created_at
+		)
+		SELECT id, type, payload, created_at FROM claimed ORDER BY id
+	`, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var m models.OutboxEvent
+		if err = rows.Scan(&m.ID, &m.Type, &m.Payload, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, m)
+	}
+
+	return events, rows.Err()
+}
+
+func (o *outboxRepo) MarkPublished(ids []int64) error {
+	_, err := o.pool.Exec(o.ctx, `
+		UPDATE outbox_events
+		SET published_at = NOW(), locked_until = NULL
+		WHERE id = ANY($1)
+	`, ids)
+	return err
+}
+
+// recordEventTx writes a domain event to the outbox. Written in the
+// transaction of the change, the event exists exactly when the change is
+// committed.
+func recordEventTx(ctx context.Context, tx pgx.Tx, eventType models.EventType, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO outbox_events (type, payload) VALUES ($1, $2::JSONB)
+	`, string(eventType), string(payload))
+	return err
+}
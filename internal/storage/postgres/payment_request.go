@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type paymentRequestRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newPaymentRequestRepo(ctx context.Context, pool *pgxpool.Pool) *paymentRequestRepo {
+	return &paymentRequestRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) PaymentRequest() storage.IPaymentRequest {
+	return s.paymentRequest
+}
+
+func (p *paymentRequestRepo) Create(request models.PaymentRequest) (models.PaymentRequest, error) {
+	err := p.pool.QueryRow(p.ctx, `
+		INSERT INTO payment_requests (requester_id, payer_id, amount, memo, status, created_at, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NOW(), $6)
+		RETURNING id, status, created_at
+	`, request.RequesterID, request.PayerID, request.Amount, request.Memo,
+		models.PaymentRequestStatusPending, request.ExpiresAt,
+	).Scan(&request.ID, &request.Status, &request.CreatedAt)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+
+	return request, nil
+}
+
+// GetUserRequests returns requests where the user is either side. Pending
+// requests past their deadline are reported as expired even before ExpireDue
+// stores it.
+func (p *paymentRequestRepo) GetUserRequests(userID int64) ([]models.PaymentRequestDetails, error) {
+	rows, err := p.pool.Query(p.ctx, `
+		SELECT pr.id, pr.requester_id, pr.payer_id, pr.amount, COALESCE(pr.memo, ''),
+		       CASE WHEN pr.status = 'pending' AND pr.expires_at <= NOW() THEN 'expired' ELSE pr.status END,
+		       pr.transaction_id, pr.created_at, pr.expires_at, pr.resolved_at,
+		       ru.username, pu.username
+		FROM payment_requests pr
+		JOIN users ru ON ru.id = pr.requester_id
+		JOIN users pu ON pu.id = pr.payer_id
+		WHERE pr.requester_id = $1 OR pr.payer_id = $1
+		ORDER BY pr.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.PaymentRequestDetails
+	for rows.Next() {
+		var r models.PaymentRequestDetails
+		if err := rows.Scan(
+			&r.ID, &r.RequesterID, &r.PayerID, &r.Amount, &r.Memo,
+			&r.Status, &r.TransactionID, &r.CreatedAt, &r.ExpiresAt, &r.ResolvedAt,
+			&r.RequesterUsername, &r.PayerUsername,
+		); err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+
+	return requests, rows.Err()
+}
+
+// Accept locks the request and pays it with the same transfer path as a
+// regular coin transfer, so the status change and the transfer commit together.
+// Payments above the approval threshold are held as a pending transfer
+// instead, exactly like a direct transfer of that amount. A zero threshold
+// disables approvals.
+func (p *paymentRequestRepo) Accept(
+	requestID, payerID int64,
+	limits models.TransferLimits,
+	approvalThreshold int64,
+) (models.PaymentRequest, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	request := models.PaymentRequest{ID: requestID, PayerID: payerID}
+	var expired bool
+	err = tx.QueryRow(p.ctx, `
+		SELECT requester_id, amount, COALESCE(memo, ''), expires_at <= NOW()
+		FROM payment_requests
+		WHERE id = $1 AND payer_id = $2 AND status = 'pending'
+		FOR UPDATE
+	`, requestID, payerID).Scan(&request.RequesterID, &request.Amount, &request.Memo, &expired)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PaymentRequest{}, storage.ErrNotFound
+		}
+		return models.PaymentRequest{}, err
+	}
+	if expired {
+		if err = expireTx(p.ctx, tx, requestID); err != nil {
+			return models.PaymentRequest{}, err
+		}
+		if err = tx.Commit(p.ctx); err != nil {
+			return models.PaymentRequest{}, err
+		}
+		return models.PaymentRequest{}, storage.ErrExpired
+	}
+
+	if err = checkLimitsTx(p.ctx, tx, payerID, limits, request.Amount); err != nil {
+		return models.PaymentRequest{}, err
+	}
+
+	if approvalThreshold > 0 && request.Amount > approvalThreshold {
+		pending, err := reserveTransferTx(p.ctx, tx, models.PendingTransfer{
+			FromUserID: payerID,
+			ToUserID:   request.RequesterID,
+			Amount:     request.Amount,
+			Memo:       request.Memo,
+		})
+		if err != nil {
+			return models.PaymentRequest{}, err
+		}
+		request.PendingTransferID = &pending.ID
+	} else {
+		transfer, err := transferTx(p.ctx, tx, models.Transaction{
+			FromUserID: payerID,
+			ToUserID:   request.RequesterID,
+			Amount:     request.Amount,
+			Memo:       request.Memo,
+		})
+		if err != nil {
+			return models.PaymentRequest{}, err
+		}
+		request.TransactionID = &transfer.ID
+	}
+
+	err = tx.QueryRow(p.ctx, `
+		UPDATE payment_requests
+		SET status = $1, transaction_id = $2, pending_transfer_id = $3, resolved_at = NOW()
+		WHERE id = $4
+		RETURNING status, resolved_at
+	`, models.PaymentRequestStatusAccepted, request.TransactionID, request.PendingTransferID, requestID,
+	).Scan(&request.Status, &request.ResolvedAt)
+	if err != nil {
+		return models.PaymentRequest{}, err
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return models.PaymentRequest{}, err
+	}
+
+	return request, nil
+}
+
+func (p *paymentRequestRepo) Decline(requestID, payerID int64) error {
+	return p.resolve(requestID, "payer_id", payerID, models.PaymentRequestStatusDeclined)
+}
+
+func (p *paymentRequestRepo) Cancel(requestID, requesterID int64) error {
+	return p.resolve(requestID, "requester_id", requesterID, models.PaymentRequestStatusCancelled)
+}
+
+// resolve closes a pending, unexpired request on behalf of one of its sides.
+// The column name is never user input.
+func (p *paymentRequestRepo) resolve(
+	requestID int64,
+	column string,
+	userID int64,
+	status models.PaymentRequestStatus,
+) error {
+	tag, err := p.pool.Exec(p.ctx, `
+		UPDATE payment_requests
+		SET status = $1, resolved_at = NOW()
+		WHERE id = $2 AND `+column+` = $3 AND status = 'pending' AND expires_at > NOW()
+	`, status, requestID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// ExpireDue marks pending requests past their deadline as expired and returns
+// how many it closed.
+func (p *paymentRequestRepo) ExpireDue() (int64, error) {
+	tag, err := p.pool.Exec(p.ctx, `
+		UPDATE payment_requests
+		SET status = $1, resolved_at = expires_at
+		WHERE status = $2 AND expires_at <= NOW()
+	`, models.PaymentRequestStatusExpired, models.PaymentRequestStatusPending)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func expireTx(ctx context.Context, tx pgx.Tx, requestID int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE payment_requests
+		SET status = $1, resolved_at = expires_at
+		WHERE id = $2
+	`, models.PaymentRequestStatusExpired, requestID)
+	return err
+}
@@ -0,0 +1,255 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type pendingTransferRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newPendingTransferRepo(ctx context.Context, pool *pgxpool.Pool) *pendingTransferRepo {
+	return &pendingTransferRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) PendingTransfer() storage.IPendingTransfer {
+	return s.pendingTransfer
+}
+
+// Create moves the amount from the sender's balance into reserved coins and
+// records the transfer for approval. Like a direct transfer, the reservation
+// uses the giftable allowance first and then the sender's oldest lots. Limits
+// are checked here so a reservation counts against them just like an executed
+// transfer.
+func (p *pendingTransferRepo) Create(
+	transfer models.PendingTransfer,
+	limits models.TransferLimits,
+) (models.PendingTransfer, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	if err = checkLimitsTx(p.ctx, tx, transfer.FromUserID, limits, transfer.Amount); err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	transfer, err = reserveTransferTx(p.ctx, tx, transfer)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	return transfer, nil
+}
+
+// reserveTransferTx holds a transfer for approval inside an existing
+// transaction. The caller checks the limits.
+func reserveTransferTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	transfer models.PendingTransfer,
+) (models.PendingTransfer, error) {
+	giftable, debits, err := debitGiftableFirstTx(ctx, tx, transfer.FromUserID, transfer.Amount)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE users SET reserved_coins = reserved_coins + $1 WHERE id = $2`,
+		transfer.Amount, transfer.FromUserID)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO pending_transfers (
+			from_user_id, to_user_id, amount, giftable_amount, allowance_period, memo, category, status
+		)
+		VALUES ($1, $2, $3, $4, (SELECT allowance_period FROM users WHERE id = $1), NULLIF($5, ''), NULLIF($6, ''), $7)
+		RETURNING id, status, created_at
+	`, transfer.FromUserID, transfer.ToUserID, transfer.Amount, giftable, transfer.Memo, string(transfer.Category),
+		models.PendingTransferStatusPending,
+	).Scan(&transfer.ID, &transfer.Status, &transfer.CreatedAt)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	if err = recordLotDebitsTx(ctx, tx, debits, debitOfPendingTransfer, transfer.ID); err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	return transfer, nil
+}
+
+func (p *pendingTransferRepo) GetPending() ([]models.PendingTransferDetails, error) {
+	rows, err := p.pool.Query(p.ctx, `
+		SELECT pt.id, pt.from_user_id, pt.to_user_id, pt.amount, COALESCE(pt.memo, ''),
+		       COALESCE(pt.category, ''), pt.status, pt.created_at, fu.username, tu.username
+		FROM pending_transfers pt
+		JOIN users fu ON fu.id = pt.from_user_id
+		JOIN users tu ON tu.id = pt.to_user_id
+		WHERE pt.status = $1
+		ORDER BY pt.created_at
+	`, models.PendingTransferStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.PendingTransferDetails
+	for rows.Next() {
+		var t models.PendingTransferDetails
+		if err := rows.Scan(
+			&t.ID, &t.FromUserID, &t.ToUserID, &t.Amount, &t.Memo,
+			&t.Category, &t.Status, &t.CreatedAt, &t.FromUsername, &t.ToUsername,
+		); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
+
+// Approve releases the reservation to the recipient and records the ledger
+// entry. Nobody can approve their own transfer.
+func (p *pendingTransferRepo) Approve(transferID, actorID int64) (models.Transaction, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	transfer := models.Transaction{Type: models.TransactionTypeTransfer}
+	err = tx.QueryRow(p.ctx, `
+		SELECT from_user_id, to_user_id, amount, giftable_amount, COALESCE(memo, ''), COALESCE(category, '')
+		FROM pending_transfers
+		WHERE id = $1 AND status = $2 AND from_user_id <> $3
+		FOR UPDATE
+	`, transferID, models.PendingTransferStatusPending, actorID).Scan(
+		&transfer.FromUserID, &transfer.ToUserID, &transfer.Amount, &transfer.GiftableAmount,
+		&transfer.Memo, &transfer.Category,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, storage.ErrNotFound
+		}
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(p.ctx, `UPDATE users SET reserved_coins = reserved_coins - $1 WHERE id = $2`,
+		transfer.Amount, transfer.FromUserID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = creditTx(p.ctx, tx, transfer.ToUserID, transfer.Amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	transfer, err = insertTransactionTx(p.ctx, tx, transfer)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = moveLotDebitsTx(p.ctx, tx, debitOfPendingTransfer, transferID, transfer.ID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = recordEventTx(p.ctx, tx, models.EventCoinTransferred, transfer); err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(p.ctx, `
+		UPDATE pending_transfers
+		SET status = $1, transaction_id = $2, resolved_by = $3, resolved_at = NOW()
+		WHERE id = $4
+	`, models.PendingTransferStatusApproved, transfer.ID, actorID, transferID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return transfer, nil
+}
+
+// Reject returns the reserved coins to the buckets they were taken from: the
+// giftable part to the allowance and the rest to the lots it was debited from.
+// Unused allowance does not roll over, so the giftable part is dropped when the
+// allowance has been topped up for a new month since the transfer was held.
+// A payment request paid by the transfer is marked declined, since it was
+// never paid.
+func (p *pendingTransferRepo) Reject(transferID, actorID int64) (models.PendingTransfer, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	transfer := models.PendingTransfer{ID: transferID}
+	var giftable int64
+	var period *time.Time
+	err = tx.QueryRow(p.ctx, `
+		UPDATE pending_transfers
+		SET status = $1, resolved_by = $2, resolved_at = NOW()
+		WHERE id = $3 AND status = $4 AND from_user_id <> $2
+		RETURNING from_user_id, to_user_id, amount, giftable_amount, allowance_period, status
+	`, models.PendingTransferStatusRejected, actorID, transferID, models.PendingTransferStatusPending).
+		Scan(&transfer.FromUserID, &transfer.ToUserID, &transfer.Amount, &giftable, &period, &transfer.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PendingTransfer{}, storage.ErrNotFound
+		}
+		return models.PendingTransfer{}, err
+	}
+
+	_, err = tx.Exec(p.ctx, `
+		UPDATE users
+		SET reserved_coins = reserved_coins - $1,
+		    giftable_coins = giftable_coins + CASE WHEN allowance_period IS NOT DISTINCT FROM $4::DATE THEN $2 ELSE 0 END
+		WHERE id = $3
+	`, transfer.Amount, giftable, transfer.FromUserID, period)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	if rest := transfer.Amount - giftable; rest > 0 {
+		err = restoreLotsTx(p.ctx, tx, transfer.FromUserID, rest, debitOfPendingTransfer, transferID)
+		if err != nil {
+			return models.PendingTransfer{}, err
+		}
+	}
+
+	_, err = tx.Exec(p.ctx, `
+		UPDATE payment_requests
+		SET status = $1, resolved_at = NOW()
+		WHERE pending_transfer_id = $2 AND status = $3
+	`, models.PaymentRequestStatusDeclined, transferID, models.PaymentRequestStatusAccepted)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return models.PendingTransfer{}, err
+	}
+
+	return transfer, nil
+}
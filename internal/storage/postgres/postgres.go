@@ -8,9 +8,17 @@ import (
 
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/icoder-new/avito-shop/pkg/logger"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// querier is implemented by both the pool and a transaction, for reads that
+// are used inside and outside of a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type store struct {
 	pool *pgxpool.Pool
 	log  *logger.Logger
@@ -19,6 +27,26 @@ type store struct {
 	coin                   *coinRepo
 	inventory              *inventoryRepo
 	transactionHistoryRepo *transactionHistoryRepo
+	balance                *balanceRepo
+	paymentRequest         *paymentRequestRepo
+	scheduledTransfer      *scheduledTransferRepo
+	reversal               *reversalRepo
+	pendingTransfer        *pendingTransferRepo
+	team                   *teamRepo
+	allowance              *allowanceRepo
+	ret                    *returnRepo
+	order                  *orderRepo
+	variant                *variantRepo
+	promotion              *promotionRepo
+	preorder               *preorderRepo
+	marketplace            *marketplaceRepo
+	wishlist               *wishlistRepo
+	notification           *notificationRepo
+	events                 *eventsRepo
+	webhook                *webhookRepo
+	outbox                 *outboxRepo
+	email                  *emailRepo
+	slack                  *slackRepo
 }
 
 func newStore(ctx context.Context, pool *pgxpool.Pool, log *logger.Logger) *store {
@@ -30,6 +58,26 @@ func newStore(ctx context.Context, pool *pgxpool.Pool, log *logger.Logger) *stor
 		coin:                   newCoinRepo(ctx, pool),
 		inventory:              newInventoryRepo(ctx, pool),
 		transactionHistoryRepo: newTransactionHistoryRepo(ctx, pool),
+		balance:                newBalanceRepo(ctx, pool),
+		paymentRequest:         newPaymentRequestRepo(ctx, pool),
+		scheduledTransfer:      newScheduledTransferRepo(ctx, pool),
+		reversal:               newReversalRepo(ctx, pool),
+		pendingTransfer:        newPendingTransferRepo(ctx, pool),
+		team:                   newTeamRepo(ctx, pool),
+		allowance:              newAllowanceRepo(ctx, pool),
+		ret:                    newReturnRepo(ctx, pool),
+		order:                  newOrderRepo(ctx, pool),
+		variant:                newVariantRepo(ctx, pool),
+		promotion:              newPromotionRepo(ctx, pool),
+		preorder:               newPreorderRepo(ctx, pool),
+		marketplace:            newMarketplaceRepo(ctx, pool),
+		wishlist:               newWishlistRepo(ctx, pool),
+		notification:           newNotificationRepo(ctx, pool),
+		events:                 newEventsRepo(ctx, pool),
+		webhook:                newWebhookRepo(ctx, pool),
+		outbox:                 newOutboxRepo(ctx, pool),
+		email:                  newEmailRepo(ctx, pool),
+		slack:                  newSlackRepo(ctx, pool),
 	}
 }
 
@@ -0,0 +1,317 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const preorderDetailsQuery = `
+	SELECT p.id, p.user_id, p.merch_id, p.variant_id, p.amount, p.status, p.purchase_transaction_id,
+	       p.created_at, p.resolved_at, m.name, COALESCE(v.sku, ''), m.available_from
+	FROM preorders p
+	JOIN merch m ON m.id = p.merch_id
+	LEFT JOIN merch_variants v ON v.id = p.variant_id`
+
+type preorderRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newPreorderRepo(ctx context.Context, pool *pgxpool.Pool) *preorderRepo {
+	return &preorderRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Preorder() storage.IPreorder {
+	return s.preorder
+}
+
+// Create reserves the price of the item from the user's spendable coins. It
+// returns ErrNotAvailable when the item does not take pre-orders right now and
+// ErrPurchaseLimit when the user has reached the item's cap.
+func (p *preorderRepo) Create(preorder models.Preorder) (models.Preorder, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	merch, err := lockMerchTx(p.ctx, tx, preorder.MerchID)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+	if !merch.AcceptsPreorders(time.Now()) {
+		return models.Preorder{}, storage.ErrNotAvailable
+	}
+	if err = checkPurchaseLimitTx(p.ctx, tx, preorder.UserID, merch); err != nil {
+		return models.Preorder{}, err
+	}
+
+	preorder.Amount = merch.Price
+	debits, err := debitTx(p.ctx, tx, preorder.UserID, preorder.Amount)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+
+	_, err = tx.Exec(p.ctx, `UPDATE users SET preorder_coins = preorder_coins + $1 WHERE id = $2`,
+		preorder.Amount, preorder.UserID)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+
+	err = tx.QueryRow(p.ctx, `
+		INSERT INTO preorders (user_id, merch_id, variant_id, amount, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at
+	`, preorder.UserID, preorder.MerchID, preorder.VariantID, preorder.Amount, models.PreorderStatusReserved,
+	).Scan(&preorder.ID, &preorder.Status, &preorder.CreatedAt)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+
+	if err = recordLotDebitsTx(p.ctx, tx, debits, debitOfPreorder, preorder.ID); err != nil {
+		return models.Preorder{}, err
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return models.Preorder{}, err
+	}
+
+	return preorder, nil
+}
+
+func (p *preorderRepo) GetUserPreorders(userID int64) ([]models.PreorderDetails, error) {
+	rows, err := p.pool.Query(p.ctx, preorderDetailsQuery+`
+		WHERE p.user_id = $1
+		ORDER BY p.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preorders []models.PreorderDetails
+	for rows.Next() {
+		var d models.PreorderDetails
+		if err := rows.Scan(
+			&d.ID, &d.UserID, &d.MerchID, &d.VariantID, &d.Amount, &d.Status, &d.PurchaseTransactionID,
+			&d.CreatedAt, &d.ResolvedAt, &d.MerchName, &d.SKU, &d.AvailableFrom,
+		); err != nil {
+			return nil, err
+		}
+		preorders = append(preorders, d)
+	}
+
+	return preorders, rows.Err()
+}
+
+// Cancel withdraws a reserved pre-order of the user and returns the coins.
+func (p *preorderRepo) Cancel(preorderID, userID int64) error {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(p.ctx)
+
+	var amount int64
+	err = tx.QueryRow(p.ctx, `
+		UPDATE preorders
+		SET status = $1, resolved_at = NOW()
+		WHERE id = $2 AND user_id = $3 AND status = $4
+		RETURNING amount
+	`, models.PreorderStatusCancelled, preorderID, userID, models.PreorderStatusReserved).Scan(&amount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+
+	if err = releaseReservationTx(p.ctx, tx, preorderID, userID, amount); err != nil {
+		return err
+	}
+
+	return tx.Commit(p.ctx)
+}
+
+// CancelDrop takes the item off sale for good and refunds every reserved
+// pre-order of it. It returns the refunded pre-orders.
+func (p *preorderRepo) CancelDrop(merchID int64) ([]models.Preorder, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	tag, err := tx.Exec(p.ctx, `
+		UPDATE merch
+		SET cancelled_at = NOW(), preorders_open = FALSE
+		WHERE id = $1 AND cancelled_at IS NULL
+	`, merchID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	rows, err := tx.Query(p.ctx, `
+		UPDATE preorders
+		SET status = $1, resolved_at = NOW()
+		WHERE merch_id = $2 AND status = $3
+		RETURNING id, user_id, amount, status
+	`, models.PreorderStatusRefunded, merchID, models.PreorderStatusReserved)
+	if err != nil {
+		return nil, err
+	}
+
+	var refunds []models.Preorder
+	for rows.Next() {
+		var refund models.Preorder
+		if err := rows.Scan(&refund.ID, &refund.UserID, &refund.Amount, &refund.Status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, refund := range refunds {
+		if err = releaseReservationTx(p.ctx, tx, refund.ID, refund.UserID, refund.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+// GetDue lists reserved pre-orders of items that are on sale now, oldest
+// first so that they are served in the order they were placed.
+func (p *preorderRepo) GetDue(now time.Time) ([]int64, error) {
+	rows, err := p.pool.Query(p.ctx, `
+		SELECT p.id
+		FROM preorders p
+		JOIN merch m ON m.id = p.merch_id
+		WHERE p.status = $1 AND m.cancelled_at IS NULL AND m.available_from <= $2
+		ORDER BY p.created_at, p.id
+	`, models.PreorderStatusReserved, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Fulfil turns a reserved pre-order into a purchase paid with the reserved
+// coins. When the item has sold out the coins are returned instead and the
+// pre-order is marked refunded. It returns the resolved pre-order.
+func (p *preorderRepo) Fulfil(preorderID int64) (models.Preorder, error) {
+	tx, err := p.pool.Begin(p.ctx)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+	defer tx.Rollback(p.ctx)
+
+	preorder := models.Preorder{ID: preorderID}
+	err = tx.QueryRow(p.ctx, `
+		SELECT user_id, merch_id, variant_id, amount
+		FROM preorders
+		WHERE id = $1 AND status = $2
+		FOR UPDATE
+	`, preorderID, models.PreorderStatusReserved).
+		Scan(&preorder.UserID, &preorder.MerchID, &preorder.VariantID, &preorder.Amount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Preorder{}, storage.ErrNotFound
+		}
+		return models.Preorder{}, err
+	}
+
+	merch, err := lockMerchTx(p.ctx, tx, preorder.MerchID)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+
+	preorder.Status = models.PreorderStatusFulfilled
+	err = takeStockTx(p.ctx, tx, merch, preorder.VariantID)
+	switch {
+	case errors.Is(err, storage.ErrOutOfStock):
+		preorder.Status = models.PreorderStatusRefunded
+		if err = releaseReservationTx(p.ctx, tx, preorderID, preorder.UserID, preorder.Amount); err != nil {
+			return models.Preorder{}, err
+		}
+	case err != nil:
+		return models.Preorder{}, err
+	default:
+		_, err = tx.Exec(p.ctx, `UPDATE users SET preorder_coins = preorder_coins - $1 WHERE id = $2`,
+			preorder.Amount, preorder.UserID)
+		if err != nil {
+			return models.Preorder{}, err
+		}
+
+		purchase, err := placePurchaseTx(p.ctx, tx, models.Transaction{
+			FromUserID: preorder.UserID,
+			Amount:     preorder.Amount,
+			MerchID:    &preorder.MerchID,
+			VariantID:  preorder.VariantID,
+		})
+		if err != nil {
+			return models.Preorder{}, err
+		}
+		if err = moveLotDebitsTx(p.ctx, tx, debitOfPreorder, preorderID, purchase.ID); err != nil {
+			return models.Preorder{}, err
+		}
+		preorder.PurchaseTransactionID = &purchase.ID
+	}
+
+	_, err = tx.Exec(p.ctx, `
+		UPDATE preorders
+		SET status = $1, purchase_transaction_id = $2, resolved_at = NOW()
+		WHERE id = $3
+	`, preorder.Status, preorder.PurchaseTransactionID, preorderID)
+	if err != nil {
+		return models.Preorder{}, err
+	}
+
+	if err = tx.Commit(p.ctx); err != nil {
+		return models.Preorder{}, err
+	}
+
+	return preorder, nil
+}
+
+// releaseReservationTx returns the coins reserved by a pre-order to the lots
+// they were taken from.
+func releaseReservationTx(ctx context.Context, tx pgx.Tx, preorderID, userID, amount int64) error {
+	_, err := tx.Exec(ctx, `UPDATE users SET preorder_coins = preorder_coins - $1 WHERE id = $2`, amount, userID)
+	if err != nil {
+		return err
+	}
+
+	return restoreLotsTx(ctx, tx, userID, amount, debitOfPreorder, preorderID)
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const promotionQuery = `
+	SELECT p.id, p.name, COALESCE(p.code, ''), p.kind, p.value, p.merch_id, COALESCE(p.category, ''),
+	       p.starts_at, p.ends_at, p.per_user_limit, p.max_uses,
+	       (SELECT COUNT(*) FROM promotion_redemptions r WHERE r.promotion_id = p.id),
+	       COALESCE(p.created_by, 0), p.created_at, COALESCE(m.name, '')
+	FROM promotions p
+	LEFT JOIN merch m ON m.id = p.merch_id`
+
+// applicablePromotionsQuery selects the running promotions with the given code
+// (an empty code selects the automatic ones) that cover the item and still
+// have uses left overall and for the user.
+const applicablePromotionsQuery = promotionQuery + `
+	WHERE COALESCE(p.code, '') = $3
+	  AND p.starts_at <= NOW() AND (p.ends_at IS NULL OR p.ends_at > NOW())
+	  AND (p.merch_id IS NULL OR p.merch_id = $1)
+	  AND (p.category IS NULL OR p.category = (SELECT category FROM merch WHERE id = $1))
+	  AND (p.max_uses IS NULL OR p.max_uses > (
+	      SELECT COUNT(*) FROM promotion_redemptions r WHERE r.promotion_id = p.id))
+	  AND (p.per_user_limit IS NULL OR p.per_user_limit > (
+	      SELECT COUNT(*) FROM promotion_redemptions r WHERE r.promotion_id = p.id AND r.user_id = $2))
+	  AND ($4::BIGINT = 0 OR p.id = $4)`
+
+type promotionRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newPromotionRepo(ctx context.Context, pool *pgxpool.Pool) *promotionRepo {
+	return &promotionRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Promotion() storage.IPromotion {
+	return s.promotion
+}
+
+// Create stores a promotion. It returns ErrConflict when the code is taken.
+func (p *promotionRepo) Create(promotion models.Promotion) (models.Promotion, error) {
+	err := p.pool.QueryRow(p.ctx, `
+		INSERT INTO promotions (
+			name, code, kind, value, merch_id, category, starts_at, ends_at, per_user_limit, max_uses, created_by
+		)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, promotion.Name, promotion.Code, promotion.Kind, promotion.Value, promotion.MerchID, promotion.Category,
+		promotion.StartsAt, promotion.EndsAt, promotion.PerUserLimit, promotion.MaxUses, promotion.CreatedBy,
+	).Scan(&promotion.ID, &promotion.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return models.Promotion{}, storage.ErrConflict
+		}
+		return models.Promotion{}, err
+	}
+
+	return promotion, nil
+}
+
+func (p *promotionRepo) GetAll() ([]models.Promotion, error) {
+	rows, err := p.pool.Query(p.ctx, promotionQuery+` ORDER BY p.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPromotions(rows)
+}
+
+// GetActive lists the running promotions that apply without a code.
+func (p *promotionRepo) GetActive() ([]models.Promotion, error) {
+	rows, err := p.pool.Query(p.ctx, promotionQuery+`
+		WHERE p.code IS NULL
+		  AND p.starts_at <= NOW() AND (p.ends_at IS NULL OR p.ends_at > NOW())
+		ORDER BY p.starts_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPromotions(rows)
+}
+
+func (p *promotionRepo) GetApplicable(userID, merchID int64, code string) ([]models.Promotion, error) {
+	return applicablePromotions(p.ctx, p.pool, userID, merchID, code, 0)
+}
+
+// End stops a promotion right away. Promotions that have not started yet are
+// closed with an empty window.
+func (p *promotionRepo) End(promotionID int64) error {
+	tag, err := p.pool.Exec(p.ctx, `
+		UPDATE promotions
+		SET ends_at = GREATEST(NOW(), starts_at)
+		WHERE id = $1 AND (ends_at IS NULL OR ends_at > NOW())
+	`, promotionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func applicablePromotions(
+	ctx context.Context, q querier, userID, merchID int64, code string, promotionID int64,
+) ([]models.Promotion, error) {
+	rows, err := q.Query(ctx, applicablePromotionsQuery, merchID, userID, code, promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPromotions(rows)
+}
+
+// applyPromotionTx locks the promotion and checks that it still applies to
+// the purchase. It returns the discount or ErrNotApplicable.
+func applyPromotionTx(ctx context.Context, tx pgx.Tx, userID, merchID, promotionID, price int64) (int64, error) {
+	var code string
+	err := tx.QueryRow(ctx, `SELECT COALESCE(code, '') FROM promotions WHERE id = $1 FOR UPDATE`, promotionID).
+		Scan(&code)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, storage.ErrNotApplicable
+		}
+		return 0, err
+	}
+
+	promotions, err := applicablePromotions(ctx, tx, userID, merchID, code, promotionID)
+	if err != nil {
+		return 0, err
+	}
+	if len(promotions) == 0 {
+		return 0, storage.ErrNotApplicable
+	}
+
+	return promotions[0].Discount(price), nil
+}
+
+func scanPromotions(rows pgx.Rows) ([]models.Promotion, error) {
+	defer rows.Close()
+
+	var promotions []models.Promotion
+	for rows.Next() {
+		var p models.Promotion
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Code, &p.Kind, &p.Value, &p.MerchID, &p.Category,
+			&p.StartsAt, &p.EndsAt, &p.PerUserLimit, &p.MaxUses, &p.Uses,
+			&p.CreatedBy, &p.CreatedAt, &p.MerchName,
+		); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotions, rows.Err()
+}
@@ -0,0 +1,286 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const returnDetailsQuery = `
+	SELECT r.id, r.user_id, r.purchase_transaction_id, r.merch_id, r.variant_id, r.amount, COALESCE(r.reason, ''),
+	       r.status, r.refund_transaction_id, r.resolved_by, r.created_at, r.resolved_at,
+	       u.username, m.name, COALESCE(v.sku, ''), COALESCE(v.size, ''), COALESCE(v.color, '')
+	FROM returns r
+	JOIN users u ON u.id = r.user_id
+	JOIN merch m ON m.id = r.merch_id
+	LEFT JOIN merch_variants v ON v.id = r.variant_id`
+
+type returnRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newReturnRepo(ctx context.Context, pool *pgxpool.Pool) *returnRepo {
+	return &returnRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Return() storage.IReturn {
+	return s.ret
+}
+
+// Create opens a return for one of the user's purchases. It returns ErrExpired
+// when the purchase is older than purchasedAfter and ErrConflict when the
+// purchase already has an open or approved return or its order has not been
+// handed over.
+func (r *returnRepo) Create(ret models.Return, purchasedAfter time.Time, approve bool) (models.Return, error) {
+	tx, err := r.pool.Begin(r.ctx)
+	if err != nil {
+		return models.Return{}, err
+	}
+	defer tx.Rollback(r.ctx)
+
+	var purchasedAt time.Time
+	err = tx.QueryRow(r.ctx, `
+		SELECT merch_id, variant_id, amount, created_at
+		FROM transactions
+		WHERE id = $1 AND from_user_id = $2 AND type = $3
+	`, ret.PurchaseTransactionID, ret.UserID, models.TransactionTypePurchase).
+		Scan(&ret.MerchID, &ret.VariantID, &ret.Amount, &purchasedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Return{}, storage.ErrNotFound
+		}
+		return models.Return{}, err
+	}
+	if purchasedAt.Before(purchasedAfter) {
+		return models.Return{}, storage.ErrExpired
+	}
+
+	// Items that have not been handed over yet are refunded by cancelling the
+	// order, never through a return.
+	var open bool
+	err = tx.QueryRow(r.ctx, `
+		SELECT EXISTS (SELECT 1 FROM orders WHERE purchase_transaction_id = $1 AND status <> $2)
+	`, ret.PurchaseTransactionID, models.OrderStatusHandedOver).Scan(&open)
+	if err != nil {
+		return models.Return{}, err
+	}
+	if open {
+		return models.Return{}, storage.ErrConflict
+	}
+
+	err = tx.QueryRow(r.ctx, `
+		INSERT INTO returns (user_id, purchase_transaction_id, merch_id, variant_id, amount, reason, status)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, status, created_at
+	`, ret.UserID, ret.PurchaseTransactionID, ret.MerchID, ret.VariantID, ret.Amount, ret.Reason,
+		models.ReturnStatusPending,
+	).Scan(&ret.ID, &ret.Status, &ret.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return models.Return{}, storage.ErrConflict
+		}
+		return models.Return{}, err
+	}
+
+	if approve {
+		refund, err := completeReturnTx(r.ctx, tx, ret, ret.UserID)
+		if err != nil {
+			return models.Return{}, err
+		}
+		ret.Status, ret.RefundTransactionID = models.ReturnStatusApproved, &refund.ID
+	}
+
+	if err = tx.Commit(r.ctx); err != nil {
+		return models.Return{}, err
+	}
+
+	return ret, nil
+}
+
+func (r *returnRepo) GetByID(returnID int64) (models.ReturnDetails, error) {
+	rows, err := r.pool.Query(r.ctx, returnDetailsQuery+` WHERE r.id = $1`, returnID)
+	if err != nil {
+		return models.ReturnDetails{}, err
+	}
+
+	returns, err := scanReturns(rows)
+	if err != nil {
+		return models.ReturnDetails{}, err
+	}
+	if len(returns) == 0 {
+		return models.ReturnDetails{}, storage.ErrNotFound
+	}
+
+	return returns[0], nil
+}
+
+func (r *returnRepo) GetUserReturns(userID int64) ([]models.ReturnDetails, error) {
+	rows, err := r.pool.Query(r.ctx, returnDetailsQuery+`
+		WHERE r.user_id = $1
+		ORDER BY r.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanReturns(rows)
+}
+
+func (r *returnRepo) GetPending() ([]models.ReturnDetails, error) {
+	rows, err := r.pool.Query(r.ctx, returnDetailsQuery+`
+		WHERE r.status = $1
+		ORDER BY r.created_at
+	`, models.ReturnStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanReturns(rows)
+}
+
+func (r *returnRepo) Approve(returnID, actorID int64) (models.Transaction, error) {
+	tx, err := r.pool.Begin(r.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(r.ctx)
+
+	ret := models.Return{ID: returnID}
+	err = tx.QueryRow(r.ctx, `
+		SELECT user_id, purchase_transaction_id, merch_id, variant_id, amount
+		FROM returns
+		WHERE id = $1 AND status = $2
+		FOR UPDATE
+	`, returnID, models.ReturnStatusPending).
+		Scan(&ret.UserID, &ret.PurchaseTransactionID, &ret.MerchID, &ret.VariantID, &ret.Amount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, storage.ErrNotFound
+		}
+		return models.Transaction{}, err
+	}
+
+	refund, err := completeReturnTx(r.ctx, tx, ret, actorID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(r.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return refund, nil
+}
+
+func (r *returnRepo) Reject(returnID, actorID int64) error {
+	tag, err := r.pool.Exec(r.ctx, `
+		UPDATE returns
+		SET status = $1, resolved_by = $2, resolved_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, models.ReturnStatusRejected, actorID, returnID, models.ReturnStatusPending)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// completeReturnTx refunds the purchase and closes the return.
+func completeReturnTx(ctx context.Context, tx pgx.Tx, ret models.Return, actorID int64) (models.Transaction, error) {
+	refund, err := refundPurchaseTx(ctx, tx, models.Transaction{
+		ID:         ret.PurchaseTransactionID,
+		FromUserID: ret.UserID,
+		Amount:     ret.Amount,
+		MerchID:    &ret.MerchID,
+		VariantID:  ret.VariantID,
+	}, actorID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE returns
+		SET status = $1, refund_transaction_id = $2, resolved_by = $3, resolved_at = NOW()
+		WHERE id = $4
+	`, models.ReturnStatusApproved, refund.ID, actorID, ret.ID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	return refund, nil
+}
+
+// refundPurchaseTx takes the purchased item back from its owner's inventory,
+// restocks it and puts the price back into the payer's lots with a refund
+// transaction linked to the purchase. It returns ErrConflict when the owner no
+// longer holds the item.
+func refundPurchaseTx(ctx context.Context, tx pgx.Tx, purchase models.Transaction, actorID int64) (models.Transaction, error) {
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_inventory
+		SET quantity = quantity - 1
+		WHERE user_id = $1 AND merch_id = $2 AND variant_id IS NOT DISTINCT FROM $3 AND quantity > 0
+	`, purchase.Owner(), purchase.MerchID, purchase.VariantID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.Transaction{}, storage.ErrConflict
+	}
+
+	if err = restockTx(ctx, tx, *purchase.MerchID, purchase.VariantID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	// A refunded purchase gives the promotion use back to the buyer.
+	if _, err = tx.Exec(ctx, `DELETE FROM promotion_redemptions WHERE transaction_id = $1`, purchase.ID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	err = restoreLotsTx(ctx, tx, purchase.FromUserID, purchase.Amount, debitOfTransaction, purchase.ID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	return insertTransactionTx(ctx, tx, models.Transaction{
+		ToUserID:              purchase.FromUserID,
+		Amount:                purchase.Amount,
+		Type:                  models.TransactionTypeRefund,
+		MerchID:               purchase.MerchID,
+		VariantID:             purchase.VariantID,
+		CreatedBy:             &actorID,
+		OriginalTransactionID: &purchase.ID,
+	})
+}
+
+func scanReturns(rows pgx.Rows) ([]models.ReturnDetails, error) {
+	defer rows.Close()
+
+	var returns []models.ReturnDetails
+	for rows.Next() {
+		var r models.ReturnDetails
+		if err := rows.Scan(
+			&r.ID, &r.UserID, &r.PurchaseTransactionID, &r.MerchID, &r.VariantID, &r.Amount, &r.Reason,
+			&r.Status, &r.RefundTransactionID, &r.ResolvedBy, &r.CreatedAt, &r.ResolvedAt,
+			&r.Username, &r.MerchName, &r.SKU, &r.Size, &r.Color,
+		); err != nil {
+			return nil, err
+		}
+		returns = append(returns, r)
+	}
+
+	return returns, rows.Err()
+}
@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const uniqueViolation = "23505"
+
+const reversalDetailsQuery = `
+	SELECT r.id, r.transaction_id, r.requested_by, r.reason, r.status, r.reversal_transaction_id,
+	       r.resolved_by, r.created_at, r.resolved_at,
+	       t.amount, t.from_user_id, t.to_user_id, su.username, ru.username
+	FROM transfer_reversals r
+	JOIN transactions t ON t.id = r.transaction_id
+	JOIN users su ON su.id = t.from_user_id
+	JOIN users ru ON ru.id = t.to_user_id`
+
+type reversalRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newReversalRepo(ctx context.Context, pool *pgxpool.Pool) *reversalRepo {
+	return &reversalRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Reversal() storage.IReversal {
+	return s.reversal
+}
+
+// Create opens a reversal for a transfer sent by the requester. At most one
+// reversal per transaction can be pending or approved at a time.
+func (r *reversalRepo) Create(transactionID, requesterID int64, reason string) (models.TransferReversal, error) {
+	reversal := models.TransferReversal{
+		TransactionID: transactionID,
+		RequestedBy:   requesterID,
+		Reason:        reason,
+	}
+
+	err := r.pool.QueryRow(r.ctx, `
+		INSERT INTO transfer_reversals (transaction_id, requested_by, reason, status)
+		SELECT id, $2, $3, $4
+		FROM transactions
+		WHERE id = $1 AND from_user_id = $2 AND type = $5
+		RETURNING id, status, created_at
+	`, transactionID, requesterID, reason, models.ReversalStatusPending, models.TransactionTypeTransfer,
+	).Scan(&reversal.ID, &reversal.Status, &reversal.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return models.TransferReversal{}, storage.ErrNotFound
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation:
+			return models.TransferReversal{}, storage.ErrConflict
+		}
+		return models.TransferReversal{}, err
+	}
+
+	return reversal, nil
+}
+
+func (r *reversalRepo) GetByID(reversalID int64) (models.TransferReversalDetails, error) {
+	rows, err := r.pool.Query(r.ctx, reversalDetailsQuery+` WHERE r.id = $1`, reversalID)
+	if err != nil {
+		return models.TransferReversalDetails{}, err
+	}
+
+	reversals, err := scanReversals(rows)
+	if err != nil {
+		return models.TransferReversalDetails{}, err
+	}
+	if len(reversals) == 0 {
+		return models.TransferReversalDetails{}, storage.ErrNotFound
+	}
+
+	return reversals[0], nil
+}
+
+func (r *reversalRepo) GetUserReversals(userID int64) ([]models.TransferReversalDetails, error) {
+	rows, err := r.pool.Query(r.ctx, reversalDetailsQuery+`
+		WHERE t.from_user_id = $1 OR t.to_user_id = $1
+		ORDER BY r.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanReversals(rows)
+}
+
+func (r *reversalRepo) GetPending() ([]models.TransferReversalDetails, error) {
+	rows, err := r.pool.Query(r.ctx, reversalDetailsQuery+`
+		WHERE r.status = $1
+		ORDER BY r.created_at
+	`, models.ReversalStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanReversals(rows)
+}
+
+// Approve posts the compensating transfer from the original recipient back to
+// the original sender and closes the reversal in the same transaction. The
+// original sender cannot approve it.
+func (r *reversalRepo) Approve(reversalID, actorID int64) (models.Transaction, error) {
+	tx, err := r.pool.Begin(r.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(r.ctx)
+
+	var original models.Transaction
+	err = tx.QueryRow(r.ctx, `
+		SELECT t.id, t.from_user_id, t.to_user_id, t.amount
+		FROM transfer_reversals r
+		JOIN transactions t ON t.id = r.transaction_id
+		WHERE r.id = $1 AND r.status = $2 AND t.from_user_id <> $3
+		FOR UPDATE OF r
+	`, reversalID, models.ReversalStatusPending, actorID).
+		Scan(&original.ID, &original.FromUserID, &original.ToUserID, &original.Amount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, storage.ErrNotFound
+		}
+		return models.Transaction{}, err
+	}
+
+	reversal, err := transferTx(r.ctx, tx, models.Transaction{
+		FromUserID:            original.ToUserID,
+		ToUserID:              original.FromUserID,
+		Amount:                original.Amount,
+		Type:                  models.TransactionTypeReversal,
+		CreatedBy:             &actorID,
+		OriginalTransactionID: &original.ID,
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = tx.Exec(r.ctx, `
+		UPDATE transfer_reversals
+		SET status = $1, reversal_transaction_id = $2, resolved_by = $3, resolved_at = NOW()
+		WHERE id = $4
+	`, models.ReversalStatusApproved, reversal.ID, actorID, reversalID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(r.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return reversal, nil
+}
+
+func (r *reversalRepo) Reject(reversalID, actorID int64) error {
+	tag, err := r.pool.Exec(r.ctx, `
+		UPDATE transfer_reversals
+		SET status = $1, resolved_by = $2, resolved_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, models.ReversalStatusRejected, actorID, reversalID, models.ReversalStatusPending)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanReversals(rows pgx.Rows) ([]models.TransferReversalDetails, error) {
+	defer rows.Close()
+
+	var reversals []models.TransferReversalDetails
+	for rows.Next() {
+		var r models.TransferReversalDetails
+		if err := rows.Scan(
+			&r.ID, &r.TransactionID, &r.RequestedBy, &r.Reason, &r.Status, &r.ReversalTransactionID,
+			&r.ResolvedBy, &r.CreatedAt, &r.ResolvedAt,
+			&r.Amount, &r.SenderID, &r.RecipientID, &r.SenderUsername, &r.RecipientUsername,
+		); err != nil {
+			return nil, err
+		}
+		reversals = append(reversals, r)
+	}
+
+	return reversals, rows.Err()
+}
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const scheduledTransferColumns = `
+	st.id, st.from_user_id, st.to_user_id, st.amount, COALESCE(st.memo, ''), COALESCE(st.category, ''),
+	COALESCE(st.schedule, ''), st.next_run_at, st.status, st.created_at, u.username`
+
+type scheduledTransferRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newScheduledTransferRepo(ctx context.Context, pool *pgxpool.Pool) *scheduledTransferRepo {
+	return &scheduledTransferRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) ScheduledTransfer() storage.IScheduledTransfer {
+	return s.scheduledTransfer
+}
+
+func (s *scheduledTransferRepo) Create(transfer models.ScheduledTransfer) (models.ScheduledTransfer, error) {
+	err := s.pool.QueryRow(s.ctx, `
+		INSERT INTO scheduled_transfers (from_user_id, to_user_id, amount, memo, category, schedule, next_run_at, status)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+		RETURNING id, status, created_at
+	`, transfer.FromUserID, transfer.ToUserID, transfer.Amount, transfer.Memo, string(transfer.Category),
+		transfer.Schedule, transfer.NextRunAt, models.ScheduledTransferStatusActive,
+	).Scan(&transfer.ID, &transfer.Status, &transfer.CreatedAt)
+	if err != nil {
+		return models.ScheduledTransfer{}, err
+	}
+
+	return transfer, nil
+}
+
+func (s *scheduledTransferRepo) GetUserTransfers(userID int64) ([]models.ScheduledTransferDetails, error) {
+	rows, err := s.pool.Query(s.ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM scheduled_transfers st
+		JOIN users u ON u.id = st.to_user_id
+		WHERE st.from_user_id = $1
+		ORDER BY st.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanScheduledTransfers(rows)
+}
+
+func (s *scheduledTransferRepo) Cancel(transferID, userID int64) error {
+	tag, err := s.pool.Exec(s.ctx, `
+		UPDATE scheduled_transfers
+		SET status = $1, next_run_at = NULL
+		WHERE id = $2 AND from_user_id = $3 AND status = $4
+	`, models.ScheduledTransferStatusCancelled, transferID, userID, models.ScheduledTransferStatusActive)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *scheduledTransferRepo) GetDue(now time.Time, limit int) ([]models.ScheduledTransferDetails, error) {
+	rows, err := s.pool.Query(s.ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM scheduled_transfers st
+		JOIN users u ON u.id = st.to_user_id
+		WHERE st.status = $1 AND st.next_run_at <= $2
+		ORDER BY st.next_run_at
+		LIMIT $3
+	`, models.ScheduledTransferStatusActive, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanScheduledTransfers(rows)
+}
+
+// Claim moves a due transfer to its next run (or completes it) only if no one
+// else has done so since it was read, so concurrent workers never double-pay.
+// The run is recorded as running in the same transaction, so every claimed
+// activation leaves a run behind even if its outcome is never written.
+func (s *scheduledTransferRepo) Claim(
+	transferID int64,
+	runAt time.Time,
+	nextRunAt *time.Time,
+) (models.ScheduledTransferRun, error) {
+	tx, err := s.pool.Begin(s.ctx)
+	if err != nil {
+		return models.ScheduledTransferRun{}, err
+	}
+	defer tx.Rollback(s.ctx)
+
+	tag, err := tx.Exec(s.ctx, `
+		UPDATE scheduled_transfers
+		SET next_run_at = $1,
+		    status = CASE WHEN $1::timestamptz IS NULL THEN $2 ELSE status END
+		WHERE id = $3 AND next_run_at = $4 AND status = $5
+	`, nextRunAt, models.ScheduledTransferStatusCompleted, transferID, runAt, models.ScheduledTransferStatusActive)
+	if err != nil {
+		return models.ScheduledTransferRun{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ScheduledTransferRun{}, storage.ErrNotFound
+	}
+
+	run := models.ScheduledTransferRun{
+		ScheduledTransferID: transferID,
+		Status:              models.ScheduledTransferRunStatusRunning,
+		ScheduledFor:        runAt,
+	}
+	err = tx.QueryRow(s.ctx, `
+		INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, status, scheduled_for)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (scheduled_transfer_id, scheduled_for) DO NOTHING
+		RETURNING id, created_at
+	`, run.ScheduledTransferID, run.Status, run.ScheduledFor).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ScheduledTransferRun{}, storage.ErrNotFound
+		}
+		return models.ScheduledTransferRun{}, err
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return models.ScheduledTransferRun{}, err
+	}
+
+	return run, nil
+}
+
+// FinishRun records the outcome of a running run.
+func (s *scheduledTransferRepo) FinishRun(run models.ScheduledTransferRun) error {
+	tag, err := s.pool.Exec(s.ctx, `
+		UPDATE scheduled_transfer_runs
+		SET status = $1, error = NULLIF($2, '')
+		WHERE id = $3 AND status = $4
+	`, run.Status, run.Error, run.ID, models.ScheduledTransferRunStatusRunning)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *scheduledTransferRepo) GetRuns(transferID, userID int64) ([]models.ScheduledTransferRun, error) {
+	rows, err := s.pool.Query(s.ctx, `
+		SELECT r.id, r.scheduled_transfer_id, r.status, COALESCE(r.error, ''), r.scheduled_for, r.created_at
+		FROM scheduled_transfer_runs r
+		JOIN scheduled_transfers st ON st.id = r.scheduled_transfer_id
+		WHERE r.scheduled_transfer_id = $1 AND st.from_user_id = $2
+		ORDER BY r.created_at DESC
+	`, transferID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.ScheduledTransferRun
+	for rows.Next() {
+		var r models.ScheduledTransferRun
+		if err := rows.Scan(&r.ID, &r.ScheduledTransferID, &r.Status, &r.Error, &r.ScheduledFor, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
+
+func scanScheduledTransfers(rows pgx.Rows) ([]models.ScheduledTransferDetails, error) {
+	defer rows.Close()
+
+	var transfers []models.ScheduledTransferDetails
+	for rows.Next() {
+		var t models.ScheduledTransferDetails
+		if err := rows.Scan(
+			&t.ID, &t.FromUserID, &t.ToUserID, &t.Amount, &t.Memo, &t.Category,
+			&t.Schedule, &t.NextRunAt, &t.Status, &t.CreatedAt, &t.ToUsername,
+		); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type slackRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newSlackRepo(ctx context.Context, pool *pgxpool.Pool) *slackRepo {
+	return &slackRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Slack() storage.ISlack {
+	return s.slack
+}
+
+// CreateLinkCode also drops the user's expired codes.
+func (s *slackRepo) CreateLinkCode(userID int64, code string, expiresAt time.Time) error {
+	tx, err := s.pool.Begin(s.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(s.ctx)
+
+	if _, err = tx.Exec(s.ctx, `DELETE FROM slack_link_codes WHERE user_id = $1 AND expires_at <= NOW()`, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(s.ctx, `
+		INSERT INTO slack_link_codes (code, user_id, expires_at) VALUES ($1, $2, $3)
+	`, code, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(s.ctx)
+}
+
+func (s *slackRepo) Link(code, teamID, slackUserID string) (int64, error) {
+	tx, err := s.pool.Begin(s.ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(s.ctx)
+
+	var userID int64
+	err = tx.QueryRow(s.ctx, `
+		DELETE FROM slack_link_codes WHERE code = $1 AND expires_at > NOW() RETURNING user_id
+	`, code).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec(s.ctx, `
+		INSERT INTO slack_links (team_id, slack_user_id, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (team_id, slack_user_id) DO UPDATE SET user_id = EXCLUDED.user_id, created_at = NOW()
+	`, teamID, slackUserID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (s *slackRepo) GetLinkedUser(teamID, slackUserID string) (models.User, error) {
+	var user models.User
+	err := s.pool.QueryRow(s.ctx, `
+		SELECT u.id, u.username, u.coins, u.reserved_coins, u.giftable_coins, u.role
+		FROM slack_links l
+		JOIN users u ON u.id = l.user_id
+		WHERE l.team_id = $1 AND l.slack_user_id = $2
+	`, teamID, slackUserID).
+		Scan(&user.ID, &user.Username, &user.Coins, &user.ReservedCoins, &user.GiftableCoins, &user.Role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, storage.ErrNotFound
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *slackRepo) Unlink(teamID, slackUserID string) error {
+	tag, err := s.pool.Exec(s.ctx, `
+		DELETE FROM slack_links WHERE team_id = $1 AND slack_user_id = $2
+	`, teamID, slackUserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
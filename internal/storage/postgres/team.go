@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const teamColumns = `id, name, lead_id, budget, monthly_budget, budget_period, created_at`
+
+type teamRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newTeamRepo(ctx context.Context, pool *pgxpool.Pool) *teamRepo {
+	return &teamRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Team() storage.ITeam {
+	return s.team
+}
+
+// Create adds a team and makes its lead a member. The budget stays empty until
+// the next monthly top-up.
+func (t *teamRepo) Create(team models.Team) (models.Team, error) {
+	tx, err := t.pool.Begin(t.ctx)
+	if err != nil {
+		return models.Team{}, err
+	}
+	defer tx.Rollback(t.ctx)
+
+	err = tx.QueryRow(t.ctx, `
+		INSERT INTO teams (name, lead_id, monthly_budget)
+		VALUES ($1, $2, $3)
+		RETURNING `+teamColumns,
+		team.Name, team.LeadID, team.MonthlyBudget,
+	).Scan(&team.ID, &team.Name, &team.LeadID, &team.Budget, &team.MonthlyBudget, &team.BudgetPeriod, &team.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return models.Team{}, storage.ErrConflict
+		}
+		return models.Team{}, err
+	}
+
+	if team.LeadID != nil {
+		if _, err = tx.Exec(t.ctx, `UPDATE users SET team_id = $1 WHERE id = $2`, team.ID, *team.LeadID); err != nil {
+			return models.Team{}, err
+		}
+	}
+
+	if err = tx.Commit(t.ctx); err != nil {
+		return models.Team{}, err
+	}
+
+	return team, nil
+}
+
+func (t *teamRepo) GetByID(teamID int64) (models.Team, error) {
+	return t.get(`SELECT `+teamColumns+` FROM teams WHERE id = $1`, teamID)
+}
+
+func (t *teamRepo) GetByLead(leadID int64) (models.Team, error) {
+	return t.get(`SELECT `+teamColumns+` FROM teams WHERE lead_id = $1`, leadID)
+}
+
+func (t *teamRepo) get(query string, arg int64) (models.Team, error) {
+	var team models.Team
+	err := t.pool.QueryRow(t.ctx, query, arg).
+		Scan(&team.ID, &team.Name, &team.LeadID, &team.Budget, &team.MonthlyBudget, &team.BudgetPeriod, &team.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Team{}, storage.ErrNotFound
+		}
+		return models.Team{}, err
+	}
+
+	return team, nil
+}
+
+func (t *teamRepo) GetMembers(teamID int64) ([]models.User, error) {
+	rows, err := t.pool.Query(t.ctx, `
+		SELECT id, username
+		FROM users
+		WHERE team_id = $1
+		ORDER BY username
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.User
+	for rows.Next() {
+		var member models.User
+		if err := rows.Scan(&member.ID, &member.Username); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMembers moves the users into the team. A user belongs to at most one
+// team, so this also takes them out of their previous one.
+func (t *teamRepo) AddMembers(teamID int64, userIDs []int64) error {
+	_, err := t.pool.Exec(t.ctx, `UPDATE users SET team_id = $1 WHERE id = ANY($2)`, teamID, userIDs)
+	return err
+}
+
+// Grant pays a member from the team budget. The recipient must still be in
+// the team when the transaction runs.
+func (t *teamRepo) Grant(teamID, leadID, toUserID, amount int64, memo string) (models.Transaction, error) {
+	tx, err := t.pool.Begin(t.ctx)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	defer tx.Rollback(t.ctx)
+
+	var budget int64
+	err = tx.QueryRow(t.ctx, `
+		SELECT budget
+		FROM teams
+		WHERE id = $1 AND lead_id = $2 AND EXISTS (SELECT 1 FROM users WHERE id = $3 AND team_id = $1)
+		FOR UPDATE
+	`, teamID, leadID, toUserID).Scan(&budget)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, storage.ErrNotFound
+		}
+		return models.Transaction{}, err
+	}
+	if budget < amount {
+		return models.Transaction{}, storage.ErrInsufficientFunds
+	}
+
+	if _, err = tx.Exec(t.ctx, `UPDATE teams SET budget = budget - $1 WHERE id = $2`, amount, teamID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = creditTx(t.ctx, tx, toUserID, amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	grant, err := insertTransactionTx(t.ctx, tx, models.Transaction{
+		ToUserID:  toUserID,
+		Amount:    amount,
+		Type:      models.TransactionTypeTeamBudget,
+		Memo:      memo,
+		CreatedBy: &leadID,
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = tx.Commit(t.ctx); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return grant, nil
+}
@@ -2,12 +2,19 @@ package postgres
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
 	"github.com/icoder-new/avito-shop/internal/storage"
 
 	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const defaultHistoryLimit = 50
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type transactionHistoryRepo struct {
 	ctx  context.Context
 	pool *pgxpool.Pool
@@ -24,26 +31,72 @@ func (s *store) TransactionHistory() storage.ITransactionHistory {
 	return s.transactionHistoryRepo
 }
 
-func (t *transactionHistoryRepo) GetTransactions(userID int64) ([]models.Transaction, error) {
-	rows, err := t.pool.Query(t.ctx, `
-		SELECT id, from_user_id, to_user_id, amount, type, merch_id, created_at
-		FROM transactions
-		WHERE from_user_id = $1 OR to_user_id = $1
-		ORDER BY created_at DESC
-	`, userID)
+func (t *transactionHistoryRepo) GetTransactions(
+	userID int64,
+	filter storage.TransactionFilter,
+) ([]models.TransactionDetails, error) {
+	var (
+		conditions = []string{"(t.from_user_id = $1 OR t.to_user_id = $1)"}
+		args       = []interface{}{userID}
+	)
+
+	if filter.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+		n := len(args)
+		conditions = append(conditions,
+			fmt.Sprintf("(t.memo ILIKE $%d OR fu.username ILIKE $%d OR tu.username ILIKE $%d)", n, n, n))
+	}
+	if filter.Type != "" {
+		args = append(args, string(filter.Type))
+		conditions = append(conditions, fmt.Sprintf("t.type = $%d", len(args)))
+	}
+	if filter.Category != "" {
+		args = append(args, string(filter.Category))
+		conditions = append(conditions, fmt.Sprintf("t.category = $%d", len(args)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	args = append(args, limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+		SELECT t.id, COALESCE(t.from_user_id, 0), COALESCE(t.to_user_id, 0), t.amount, t.type, t.merch_id,
+		       COALESCE(t.memo, ''), COALESCE(t.category, ''), COALESCE(t.reason, ''),
+		       t.original_transaction_id, t.original_amount, t.created_at,
+		       COALESCE(fu.username, ''), COALESCE(tu.username, ''), COALESCE(m.name, '')
+		FROM transactions t
+		LEFT JOIN users fu ON fu.id = t.from_user_id
+		LEFT JOIN users tu ON tu.id = t.to_user_id
+		LEFT JOIN merch m ON m.id = t.merch_id
+		WHERE %s
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT $%d OFFSET $%d
+	`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := t.pool.Query(t.ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var transactions []models.Transaction
+	var transactions []models.TransactionDetails
 	for rows.Next() {
-		var tx models.Transaction
-		if err := rows.Scan(&tx.ID, &tx.FromUserID, &tx.ToUserID, &tx.Amount, &tx.Type, &tx.MerchID, &tx.CreatedAt); err != nil {
+		var tx models.TransactionDetails
+		if err := rows.Scan(
+			&tx.ID, &tx.FromUserID, &tx.ToUserID, &tx.Amount, &tx.Type, &tx.MerchID,
+			&tx.Memo, &tx.Category, &tx.Reason, &tx.OriginalTransactionID, &tx.OriginalAmount, &tx.CreatedAt,
+			&tx.FromUsername, &tx.ToUsername, &tx.MerchName,
+		); err != nil {
 			return nil, err
 		}
 		transactions = append(transactions, tx)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate transactions: %w", err)
+	}
+
 	return transactions, nil
 }
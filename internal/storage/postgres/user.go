@@ -2,8 +2,10 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"github.com/icoder-new/avito-shop/internal/models"
 	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -27,25 +29,42 @@ func (u *userRepo) CreateUser(username, passwordHash string) (models.User, error
 	query := `
 		INSERT INTO users (username, password_hash, coins, created_at, updated_at)
 		VALUES ($1, $2, 0, NOW(), NOW())
-		RETURNING id, username, password_hash, coins, created_at, updated_at;
+		RETURNING id, username, password_hash, coins, reserved_coins, preorder_coins, giftable_coins, team_id, email, email_opt_out, role, created_at, updated_at;
 	`
 
+	tx, err := u.pool.Begin(u.ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(u.ctx)
+
 	var user models.User
-	err := u.pool.QueryRow(u.ctx, query, username, passwordHash).Scan(
-		&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.CreatedAt, &user.UpdatedAt,
+	err = tx.QueryRow(u.ctx, query, username, passwordHash).Scan(
+		&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.ReservedCoins, &user.PreorderCoins, &user.GiftableCoins, &user.TeamID, &user.Email, &user.EmailOptOut, &user.Role, &user.CreatedAt, &user.UpdatedAt,
 	)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err = recordEventTx(u.ctx, tx, models.EventUserCreated, user); err != nil {
+		return models.User{}, err
+	}
 
-	return user, err
+	if err = tx.Commit(u.ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
 }
 
 func (u *userRepo) GetUserByID(userID int64) (models.User, error) {
 	var (
 		user  models.User
-		query = "SELECT id, username, password_hash, coins, created_at, updated_at FROM users WHERE id = $1"
+		query = "SELECT id, username, password_hash, coins, reserved_coins, preorder_coins, giftable_coins, team_id, email, email_opt_out, role, created_at, updated_at FROM users WHERE id = $1"
 	)
 
 	err := u.pool.QueryRow(u.ctx, query, userID).
-		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.CreatedAt, &user.UpdatedAt)
+		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.ReservedCoins, &user.PreorderCoins, &user.GiftableCoins, &user.TeamID, &user.Email, &user.EmailOptOut, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return models.User{}, err
 	}
@@ -55,18 +74,82 @@ func (u *userRepo) GetUserByID(userID int64) (models.User, error) {
 func (u *userRepo) GetUserByUsername(username string) (models.User, error) {
 	var (
 		user  models.User
-		query = "SELECT id, username, password_hash, coins, created_at, updated_at FROM users WHERE username = $1"
+		query = "SELECT id, username, password_hash, coins, reserved_coins, preorder_coins, giftable_coins, team_id, email, email_opt_out, role, created_at, updated_at FROM users WHERE username = $1"
 	)
 
 	err := u.pool.QueryRow(u.ctx, query, username).
-		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.CreatedAt, &user.UpdatedAt)
+		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.ReservedCoins, &user.PreorderCoins, &user.GiftableCoins, &user.TeamID, &user.Email, &user.EmailOptOut, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return models.User{}, err
 	}
 	return user, nil
 }
 
+func (u *userRepo) GetUsersByUsernames(usernames []string) ([]models.User, error) {
+	rows, err := u.pool.Query(u.ctx, `
+		SELECT id, username, password_hash, coins, reserved_coins, preorder_coins, giftable_coins, team_id, email, email_opt_out, role, created_at, updated_at
+		FROM users
+		WHERE username = ANY($1)
+	`, usernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.ReservedCoins, &user.PreorderCoins, &user.GiftableCoins, &user.TeamID, &user.Email, &user.EmailOptOut, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateUserCoins sets the balance by crediting or debiting the difference, so
+// the user's coin lots stay in line with the new balance.
 func (u *userRepo) UpdateUserCoins(userID int64, coins int64) error {
-	_, err := u.pool.Exec(u.ctx, "UPDATE users SET coins = $1, updated_at = NOW() WHERE id = $2", coins, userID)
-	return err
+	tx, err := u.pool.Begin(u.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(u.ctx)
+
+	var current int64
+	if err = tx.QueryRow(u.ctx, "SELECT coins FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&current); err != nil {
+		return err
+	}
+
+	switch {
+	case coins > current:
+		err = creditTx(u.ctx, tx, userID, coins-current)
+	case coins < current:
+		_, err = debitTx(u.ctx, tx, userID, current-coins)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(u.ctx)
+}
+
+// UpdateEmailSettings sets the user's email address and opt-out. It returns
+// ErrConflict when another user has the address.
+func (u *userRepo) UpdateEmailSettings(userID int64, email *string, optOut bool) error {
+	_, err := u.pool.Exec(u.ctx, `
+		UPDATE users SET email = $1, email_opt_out = $2, updated_at = NOW() WHERE id = $3
+	`, email, optOut, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return storage.ErrConflict
+		}
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const variantQuery = `
+	SELECT id, merch_id, sku, COALESCE(size, ''), COALESCE(color, ''), stock
+	FROM merch_variants`
+
+type variantRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newVariantRepo(ctx context.Context, pool *pgxpool.Pool) *variantRepo {
+	return &variantRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Variant() storage.IVariant {
+	return s.variant
+}
+
+// Create adds a variant to a merch item. It returns ErrConflict when the SKU
+// is already taken.
+func (v *variantRepo) Create(variant models.MerchVariant) (models.MerchVariant, error) {
+	err := v.pool.QueryRow(v.ctx, `
+		INSERT INTO merch_variants (merch_id, sku, size, color, stock)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id
+	`, variant.MerchID, variant.SKU, variant.Size, variant.Color, variant.Stock).Scan(&variant.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return models.MerchVariant{}, storage.ErrConflict
+		}
+		return models.MerchVariant{}, err
+	}
+
+	return variant, nil
+}
+
+func (v *variantRepo) GetBySKU(sku string) (models.MerchVariant, error) {
+	var variant models.MerchVariant
+	err := v.pool.QueryRow(v.ctx, variantQuery+` WHERE sku = $1`, sku).Scan(
+		&variant.ID, &variant.MerchID, &variant.SKU, &variant.Size, &variant.Color, &variant.Stock,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.MerchVariant{}, storage.ErrNotFound
+		}
+		return models.MerchVariant{}, err
+	}
+
+	return variant, nil
+}
+
+func (v *variantRepo) GetByMerch(merchID int64) ([]models.MerchVariant, error) {
+	rows, err := v.pool.Query(v.ctx, variantQuery+` WHERE merch_id = $1 ORDER BY id`, merchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.MerchVariant
+	for rows.Next() {
+		var variant models.MerchVariant
+		if err := rows.Scan(
+			&variant.ID, &variant.MerchID, &variant.SKU, &variant.Size, &variant.Color, &variant.Stock,
+		); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// SetStock replaces the stock of a variant. A nil stock removes the limit.
+func (v *variantRepo) SetStock(variantID int64, stock *int64) error {
+	tag, err := v.pool.Exec(v.ctx, `UPDATE merch_variants SET stock = $1 WHERE id = $2`, stock, variantID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultWebhookDeliveriesLimit = 50
+
+type webhookRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newWebhookRepo(ctx context.Context, pool *pgxpool.Pool) *webhookRepo {
+	return &webhookRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Webhook() storage.IWebhook {
+	return s.webhook
+}
+
+func (w *webhookRepo) Create(webhook models.Webhook) (models.Webhook, error) {
+	err := w.pool.QueryRow(w.ctx, `
+		INSERT INTO webhooks (url, secret, event_types, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, active, created_at
+	`, webhook.URL, webhook.Secret, webhook.EventTypes, webhook.CreatedBy).
+		Scan(&webhook.ID, &webhook.Active, &webhook.CreatedAt)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (w *webhookRepo) GetAll() ([]models.Webhook, error) {
+	rows, err := w.pool.Query(w.ctx, `
+		SELECT id, url, secret, event_types, active, created_by, created_at
+		FROM webhooks
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var m models.Webhook
+		err := rows.Scan(&m.ID, &m.URL, &m.Secret, &m.EventTypes, &m.Active, &m.CreatedBy, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, m)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (w *webhookRepo) Deactivate(webhookID int64) error {
+	tag, err := w.pool.Exec(w.ctx, `UPDATE webhooks SET active = FALSE WHERE id = $1 AND active`, webhookID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// Enqueue queues the event for every active webhook subscribed to its type.
+// An event that was already queued is skipped, so a relayed event can be
+// enqueued again safely.
+func (w *webhookRepo) Enqueue(event models.OutboxEvent) error {
+	_, err := w.pool.Exec(w.ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id)
+		SELECT id, $1
+		FROM webhooks
+		WHERE active AND $2 = ANY(event_types)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`, event.ID, string(event.Type))
+	return err
+}
+
+func (w *webhookRepo) ClaimDue(limit int, lease time.Duration) ([]models.WebhookDeliveryDetails, error) {
+	rows, err := w.pool.Query(w.ctx, `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $3
+		FROM due, webhooks wh, outbox_events e
+		WHERE d.id = due.id AND wh.id = d.webhook_id AND e.id = d.event_id
+		RETURNING d.id, d.webhook_id, d.event_id, e.type, e.payload, d.status, d.attempts, d.next_attempt_at,
+			d.response_status, COALESCE(d.last_error, ''), e.created_at, d.delivered_at, wh.url, wh.secret
+	`, models.WebhookDeliveryStatusPending, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDeliveryDetails
+	for rows.Next() {
+		var m models.WebhookDeliveryDetails
+		err := rows.Scan(&m.ID, &m.WebhookID, &m.EventID, &m.EventType, &m.Payload, &m.Status, &m.Attempts,
+			&m.NextAttemptAt, &m.ResponseStatus, &m.LastError, &m.CreatedAt, &m.DeliveredAt, &m.URL, &m.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, m)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (w *webhookRepo) MarkDelivered(deliveryID int64, responseStatus int) error {
+	_, err := w.pool.Exec(w.ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, response_status = $2, last_error = NULL, delivered_at = NOW()
+		WHERE id = $3
+	`, models.WebhookDeliveryStatusDelivered, responseStatus, deliveryID)
+	return err
+}
+
+func (w *webhookRepo) MarkFailed(deliveryID int64, responseStatus *int, lastError string, retryAt *time.Time) error {
+	status := models.WebhookDeliveryStatusPending
+	if retryAt == nil {
+		status = models.WebhookDeliveryStatusDead
+	}
+
+	_, err := w.pool.Exec(w.ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, response_status = $2, last_error = $3,
+			next_attempt_at = COALESCE($4, next_attempt_at)
+		WHERE id = $5
+	`, status, responseStatus, lastError, retryAt, deliveryID)
+	return err
+}
+
+func (w *webhookRepo) GetDeliveries(filter storage.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultWebhookDeliveriesLimit
+	}
+
+	rows, err := w.pool.Query(w.ctx, `
+		SELECT d.id, d.webhook_id, d.event_id, e.type, e.payload, d.status, d.attempts, d.next_attempt_at,
+			d.response_status, COALESCE(d.last_error, ''), e.created_at, d.delivered_at
+		FROM webhook_deliveries d
+		JOIN outbox_events e ON e.id = d.event_id
+		WHERE ($1::BIGINT = 0 OR d.webhook_id = $1) AND ($2::TEXT = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3 OFFSET $4
+	`, filter.WebhookID, string(filter.Status), limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var m models.WebhookDelivery
+		err := rows.Scan(&m.ID, &m.WebhookID, &m.EventID, &m.EventType, &m.Payload, &m.Status, &m.Attempts,
+			&m.NextAttemptAt, &m.ResponseStatus, &m.LastError, &m.CreatedAt, &m.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, m)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (w *webhookRepo) Retry(deliveryID int64) error {
+	tag, err := w.pool.Exec(w.ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = 0, next_attempt_at = NOW()
+		WHERE id = $2 AND status = $3
+	`, models.WebhookDeliveryStatusPending, deliveryID, models.WebhookDeliveryStatusDead)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
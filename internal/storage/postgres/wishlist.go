@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const wishlistDetailsQuery = `
+	SELECT w.id, w.user_id, w.merch_id, w.variant_id, w.last_price, w.in_stock, w.affordable_notified, w.created_at,
+	       m.name, COALESCE(v.sku, ''), COALESCE(v.size, ''), COALESCE(v.color, ''), m.price,
+	       CASE WHEN w.variant_id IS NULL THEN m.stock ELSE v.stock END, u.coins
+	FROM wishlist_items w
+	JOIN merch m ON m.id = w.merch_id
+	JOIN users u ON u.id = w.user_id
+	LEFT JOIN merch_variants v ON v.id = w.variant_id`
+
+type wishlistRepo struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func newWishlistRepo(ctx context.Context, pool *pgxpool.Pool) *wishlistRepo {
+	return &wishlistRepo{
+		ctx:  ctx,
+		pool: pool,
+	}
+}
+
+func (s *store) Wishlist() storage.IWishlist {
+	return s.wishlist
+}
+
+func (w *wishlistRepo) Add(item models.WishlistItem) (models.WishlistItem, error) {
+	err := w.pool.QueryRow(w.ctx, `
+		INSERT INTO wishlist_items (user_id, merch_id, variant_id, last_price, in_stock, affordable_notified)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, item.UserID, item.MerchID, item.VariantID, item.LastPrice, item.InStock, item.AffordableNotified,
+	).Scan(&item.ID, &item.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return models.WishlistItem{}, storage.ErrConflict
+		}
+		return models.WishlistItem{}, err
+	}
+
+	return item, nil
+}
+
+func (w *wishlistRepo) GetUserWishlist(userID int64) ([]models.WishlistItemDetails, error) {
+	rows, err := w.pool.Query(w.ctx, wishlistDetailsQuery+`
+		WHERE w.user_id = $1
+		ORDER BY w.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanWishlistItems(rows)
+}
+
+func (w *wishlistRepo) Remove(itemID, userID int64) error {
+	tag, err := w.pool.Exec(w.ctx, `DELETE FROM wishlist_items WHERE id = $1 AND user_id = $2`, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (w *wishlistRepo) GetBatch(afterID int64, limit int) ([]models.WishlistItemDetails, error) {
+	rows, err := w.pool.Query(w.ctx, wishlistDetailsQuery+`
+		WHERE w.id > $1
+		ORDER BY w.id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanWishlistItems(rows)
+}
+
+// SaveEvaluation moves the item from the state the evaluator read to the new
+// one. It returns ErrConflict when the item changed in between, so that a
+// concurrent run cannot report the same change twice.
+func (w *wishlistRepo) SaveEvaluation(
+	seen, next models.WishlistItem,
+	notifications []models.Notification,
+) error {
+	tx, err := w.pool.Begin(w.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(w.ctx)
+
+	tag, err := tx.Exec(w.ctx, `
+		UPDATE wishlist_items
+		SET last_price = $1, in_stock = $2, affordable_notified = $3
+		WHERE id = $4 AND last_price = $5 AND in_stock = $6 AND affordable_notified = $7
+	`, next.LastPrice, next.InStock, next.AffordableNotified,
+		seen.ID, seen.LastPrice, seen.InStock, seen.AffordableNotified)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrConflict
+	}
+
+	for _, n := range notifications {
+		if _, err = insertNotification(w.ctx, tx, n); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(w.ctx)
+}
+
+func scanWishlistItems(rows pgx.Rows) ([]models.WishlistItemDetails, error) {
+	defer rows.Close()
+
+	var items []models.WishlistItemDetails
+	for rows.Next() {
+		var d models.WishlistItemDetails
+		if err := rows.Scan(
+			&d.ID, &d.UserID, &d.MerchID, &d.VariantID, &d.LastPrice, &d.InStock, &d.AffordableNotified, &d.CreatedAt,
+			&d.MerchName, &d.SKU, &d.Size, &d.Color, &d.Price, &d.Stock, &d.Coins,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, d)
+	}
+
+	return items, rows.Err()
+}
@@ -1,9 +1,43 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/icoder-new/avito-shop/internal/models"
 )
 
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrExpired           = errors.New("expired")
+	ErrConflict          = errors.New("conflict")
+	ErrOutOfStock        = errors.New("out of stock")
+	ErrNotApplicable     = errors.New("not applicable")
+	ErrNotAvailable      = errors.New("not available")
+	ErrPurchaseLimit     = errors.New("purchase limit reached")
+)
+
+const (
+	LimitPerTransfer = "per_transfer"
+	LimitDaily       = "daily"
+	LimitMonthly     = "monthly"
+)
+
+// LimitExceededError reports which transfer limit a transfer would break and
+// how many coins the sender may still send under it.
+type LimitExceededError struct {
+	Limit     string
+	Max       int64
+	Remaining int64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s transfer limit exceeded: %d of %d remaining", e.Limit, e.Remaining, e.Max)
+}
+
 type IStorage interface {
 	CloseDB()
 
@@ -11,25 +45,305 @@ type IStorage interface {
 	Coin() ICoin
 	Inventory() IInventory
 	TransactionHistory() ITransactionHistory
+	Balance() IBalance
+	PaymentRequest() IPaymentRequest
+	ScheduledTransfer() IScheduledTransfer
+	Reversal() IReversal
+	PendingTransfer() IPendingTransfer
+	Team() ITeam
+	Allowance() IAllowance
+	Return() IReturn
+	Order() IOrder
+	Variant() IVariant
+	Promotion() IPromotion
+	Preorder() IPreorder
+	Marketplace() IMarketplace
+	Wishlist() IWishlist
+	Notification() INotification
+	Events() IEvents
+	Webhook() IWebhook
+	Outbox() IOutbox
+	Email() IEmail
+	Slack() ISlack
 }
 
 type IUser interface {
 	CreateUser(username, passwordHash string) (models.User, error)
 	GetUserByID(userID int64) (models.User, error)
 	GetUserByUsername(username string) (models.User, error)
+	GetUsersByUsernames(usernames []string) ([]models.User, error)
 	UpdateUserCoins(userID int64, coins int64) error
+	UpdateEmailSettings(userID int64, email *string, optOut bool) error
 }
 
 type ICoin interface {
-	TransferCoins(fromUserID, toUserID int64, amount int64) error
+	TransferCoins(transfer models.Transaction, limits models.TransferLimits) error
+	TransferBatch(transfers []models.Transaction, limits models.TransferLimits) ([]models.Transaction, error)
+	GetTransferUsage(userID int64) (models.TransferUsage, error)
+	GetExpiringLots(userID int64, before time.Time) ([]models.CoinLot, error)
+	GetUsersWithExpiredLots(now time.Time, limit int) ([]int64, error)
+	ExpireLots(userID int64, now time.Time) (models.Transaction, error)
 	GetUserTransactions(userID int64) ([]models.Transaction, error)
+	Grant(actorID int64, userIDs []int64, amount int64, reason string) ([]models.Transaction, error)
+	Clawback(actorID, userID int64, amount int64, reason string) (models.Transaction, error)
+	GetAdjustments(limit, offset int) ([]models.TransactionDetails, error)
 }
 
 type IInventory interface {
-	BuyItem(userID, merchID int64) error
+	BuyItem(userID, merchID int64, variantID, promotionID *int64) error
+	GiftItem(gift models.Transaction) (models.Transaction, error)
+	TransferItem(transfer models.Transaction) (models.Transaction, error)
+	GetMerchByName(name string) (models.Merch, error)
+	CreateMerch(merch models.Merch) (models.Merch, error)
+	UpdateAvailability(merch models.Merch) error
 	GetUserInventory(userID int64) ([]models.UserInventory, error)
 }
 
 type ITransactionHistory interface {
-	GetTransactions(userID int64) ([]models.Transaction, error)
+	GetTransactions(userID int64, filter TransactionFilter) ([]models.TransactionDetails, error)
+}
+
+// TransactionFilter narrows a user's history. Query is matched against the
+// memo and the usernames of both parties; zero values mean "no filter".
+type TransactionFilter struct {
+	Query    string
+	Type     models.TransactionType
+	Category models.TransferCategory
+	Limit    int
+	Offset   int
+}
+
+type IBalance interface {
+	CreateSnapshot(userID int64, balance int64) error
+	// TakeSnapshots checkpoints, as of at, every user with transactions since
+	// their latest snapshot. It returns the number of snapshots written.
+	TakeSnapshots(at time.Time) (int64, error)
+	GetBalanceAt(userID int64, at time.Time) (int64, error)
+}
+
+type IPaymentRequest interface {
+	Create(request models.PaymentRequest) (models.PaymentRequest, error)
+	GetUserRequests(userID int64) ([]models.PaymentRequestDetails, error)
+	// Accept holds the payment for approval when it exceeds approvalThreshold.
+	Accept(
+		requestID, payerID int64,
+		limits models.TransferLimits,
+		approvalThreshold int64,
+	) (models.PaymentRequest, error)
+	Decline(requestID, payerID int64) error
+	Cancel(requestID, requesterID int64) error
+	ExpireDue() (int64, error)
+}
+
+type IScheduledTransfer interface {
+	Create(transfer models.ScheduledTransfer) (models.ScheduledTransfer, error)
+	GetUserTransfers(userID int64) ([]models.ScheduledTransferDetails, error)
+	Cancel(transferID, userID int64) error
+	GetDue(now time.Time, limit int) ([]models.ScheduledTransferDetails, error)
+	// Claim returns ErrNotFound when the run was already claimed.
+	Claim(transferID int64, runAt time.Time, nextRunAt *time.Time) (models.ScheduledTransferRun, error)
+	FinishRun(run models.ScheduledTransferRun) error
+	GetRuns(transferID, userID int64) ([]models.ScheduledTransferRun, error)
+}
+
+type IReversal interface {
+	Create(transactionID, requesterID int64, reason string) (models.TransferReversal, error)
+	GetByID(reversalID int64) (models.TransferReversalDetails, error)
+	GetUserReversals(userID int64) ([]models.TransferReversalDetails, error)
+	GetPending() ([]models.TransferReversalDetails, error)
+	Approve(reversalID, actorID int64) (models.Transaction, error)
+	Reject(reversalID, actorID int64) error
+}
+
+type IPendingTransfer interface {
+	Create(transfer models.PendingTransfer, limits models.TransferLimits) (models.PendingTransfer, error)
+	GetPending() ([]models.PendingTransferDetails, error)
+	Approve(transferID, actorID int64) (models.Transaction, error)
+	Reject(transferID, actorID int64) (models.PendingTransfer, error)
+}
+
+type ITeam interface {
+	Create(team models.Team) (models.Team, error)
+	GetByID(teamID int64) (models.Team, error)
+	GetByLead(leadID int64) (models.Team, error)
+	GetMembers(teamID int64) ([]models.User, error)
+	AddMembers(teamID int64, userIDs []int64) error
+	Grant(teamID, leadID, toUserID, amount int64, memo string) (models.Transaction, error)
+}
+
+// IAllowance resets monthly balances. Period is the first day of the month;
+// rows already topped up for that period are skipped, so calls are idempotent.
+type IAllowance interface {
+	TopUpUsers(period time.Time, amount int64) ([]int64, error)
+	TopUpTeams(period time.Time) (int64, error)
+}
+
+type IReturn interface {
+	// Create opens a return for a purchase made after purchasedAfter. With
+	// approve set, the return is completed in the same transaction.
+	Create(ret models.Return, purchasedAfter time.Time, approve bool) (models.Return, error)
+	GetByID(returnID int64) (models.ReturnDetails, error)
+	GetUserReturns(userID int64) ([]models.ReturnDetails, error)
+	GetPending() ([]models.ReturnDetails, error)
+	Approve(returnID, actorID int64) (models.Transaction, error)
+	Reject(returnID, actorID int64) error
+}
+
+type IOrder interface {
+	GetByID(orderID int64) (models.OrderDetails, error)
+	GetUserOrders(userID int64) ([]models.OrderDetails, error)
+	GetOrders(status models.OrderStatus, limit, offset int) ([]models.OrderDetails, error)
+	UpdateStatus(orderID int64, from, to models.OrderStatus) error
+	Cancel(orderID, actorID int64) (models.Transaction, error)
+}
+
+type IVariant interface {
+	Create(variant models.MerchVariant) (models.MerchVariant, error)
+	GetBySKU(sku string) (models.MerchVariant, error)
+	GetByMerch(merchID int64) ([]models.MerchVariant, error)
+	SetStock(variantID int64, stock *int64) error
+}
+
+type IPromotion interface {
+	Create(promotion models.Promotion) (models.Promotion, error)
+	GetAll() ([]models.Promotion, error)
+	GetActive() ([]models.Promotion, error)
+	GetApplicable(userID, merchID int64, code string) ([]models.Promotion, error)
+	End(promotionID int64) error
+}
+
+type IPreorder interface {
+	Create(preorder models.Preorder) (models.Preorder, error)
+	GetUserPreorders(userID int64) ([]models.PreorderDetails, error)
+	Cancel(preorderID, userID int64) error
+	CancelDrop(merchID int64) ([]models.Preorder, error)
+	GetDue(now time.Time) ([]int64, error)
+	Fulfil(preorderID int64) (models.Preorder, error)
+}
+
+// ListingFilter narrows the active marketplace listings. Query is matched
+// against the item name and the seller; zero values mean "no filter".
+type ListingFilter struct {
+	Query    string
+	Item     string
+	Category string
+	MinPrice int64
+	MaxPrice int64
+	Limit    int
+	Offset   int
+}
+
+type IMarketplace interface {
+	// Create takes one unit of the item out of the seller's inventory and
+	// lists it. It returns ErrNotFound when the seller does not hold the item.
+	Create(listing models.Listing) (models.Listing, error)
+	GetByID(listingID int64) (models.ListingDetails, error)
+	Search(filter ListingFilter) ([]models.ListingDetails, error)
+	GetUserListings(userID int64) ([]models.ListingDetails, error)
+	Cancel(listingID, sellerID int64) error
+	Buy(listingID, buyerID, feePercent int64, limits models.TransferLimits) (models.Transaction, error)
+}
+
+type IWishlist interface {
+	// Add returns ErrConflict when the item is already on the wishlist.
+	Add(item models.WishlistItem) (models.WishlistItem, error)
+	GetUserWishlist(userID int64) ([]models.WishlistItemDetails, error)
+	Remove(itemID, userID int64) error
+	// GetBatch returns up to limit wishlist items with IDs above afterID, in
+	// ID order.
+	GetBatch(afterID int64, limit int) ([]models.WishlistItemDetails, error)
+	// SaveEvaluation stores the new state of a wishlist item together with
+	// the notifications it produced. It returns ErrConflict when the item is
+	// no longer in the seen state.
+	SaveEvaluation(seen, next models.WishlistItem, notifications []models.Notification) error
+}
+
+// NotificationFilter pages through a user's notifications, newest first.
+type NotificationFilter struct {
+	UnreadOnly bool
+	Limit      int
+	Offset     int
+}
+
+type INotification interface {
+	// Create stores a notification unless the user muted its type, in which
+	// case the returned notification has a zero ID.
+	Create(notification models.Notification) (models.Notification, error)
+	GetUserNotifications(userID int64, filter NotificationFilter) ([]models.Notification, error)
+	CountUnread(userID int64) (int64, error)
+	// MarkRead marks the given notifications of the user as read, or all of
+	// them when ids is empty, and returns how many were unread.
+	MarkRead(userID int64, ids []int64) (int64, error)
+	GetMutedTypes(userID int64) ([]models.NotificationType, error)
+	SetMutedTypes(userID int64, types []models.NotificationType) error
+}
+
+// IEvents carries stream events between replicas over Postgres
+// LISTEN/NOTIFY. It satisfies pubsub.Relay.
+type IEvents interface {
+	Publish(payload []byte) error
+	Listen(ctx context.Context, handle func(payload []byte)) error
+}
+
+// WebhookDeliveryFilter pages through deliveries, newest first. Zero fields
+// match everything.
+type WebhookDeliveryFilter struct {
+	WebhookID int64
+	Status    models.WebhookDeliveryStatus
+	Limit     int
+	Offset    int
+}
+
+type IWebhook interface {
+	Create(webhook models.Webhook) (models.Webhook, error)
+	GetAll() ([]models.Webhook, error)
+	// Deactivate stops queueing events for the webhook. Queued deliveries
+	// are still attempted.
+	Deactivate(webhookID int64) error
+	Enqueue(event models.OutboxEvent) error
+	// ClaimDue returns up to limit pending deliveries that are due and hides
+	// them from other claims for the lease, so that replicas do not send the
+	// same delivery twice.
+	ClaimDue(limit int, lease time.Duration) ([]models.WebhookDeliveryDetails, error)
+	MarkDelivered(deliveryID int64, responseStatus int) error
+	// MarkFailed records a failed attempt. A nil retryAt makes the delivery
+	// dead.
+	MarkFailed(deliveryID int64, responseStatus *int, lastError string, retryAt *time.Time) error
+	GetDeliveries(filter WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	// Retry queues a dead delivery again with a fresh set of attempts. It
+	// returns ErrNotFound when there is no dead delivery with the id.
+	Retry(deliveryID int64) error
+}
+
+type IOutbox interface {
+	// ClaimDue returns up to limit unpublished events, oldest first, and hides
+	// them from other claims for the lease, so that replicas do not relay the
+	// same batch at once.
+	ClaimDue(limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkPublished(ids []int64) error
+}
+
+type IEmail interface {
+	// Enqueue queues an email unless the user has no address or opted out,
+	// in which case it reports false.
+	Enqueue(userID int64, template string, data []byte) (bool, error)
+	// ClaimDue returns up to limit pending emails that are due and hides them
+	// from other claims for the lease.
+	ClaimDue(limit int, lease time.Duration) ([]models.QueuedEmailDetails, error)
+	MarkSent(emailID int64) error
+	// MarkFailed records a failed attempt. A nil retryAt fails the email for
+	// good.
+	MarkFailed(emailID int64, lastError string, retryAt *time.Time) error
+}
+
+type ISlack interface {
+	CreateLinkCode(userID int64, code string, expiresAt time.Time) error
+	// Link redeems an unexpired code and links the workspace user to its
+	// owner, replacing an earlier link. It returns ErrNotFound for an unknown
+	// or expired code.
+	Link(code, teamID, slackUserID string) (int64, error)
+	// GetLinkedUser returns ErrNotFound when the workspace user is not linked.
+	GetLinkedUser(teamID, slackUserID string) (models.User, error)
+	// Unlink returns ErrNotFound when the workspace user is not linked.
+	Unlink(teamID, slackUserID string) error
 }
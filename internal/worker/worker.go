@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultInterval = time.Minute
+
+// Worker runs a job periodically until its context is cancelled.
+type Worker struct {
+	name     string
+	interval time.Duration
+	log      *logger.Logger
+	job      func() error
+}
+
+func New(log *logger.Logger, name string, interval time.Duration, job func() error) *Worker {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &Worker{
+		name:     name,
+		interval: interval,
+		log:      log,
+		job:      job,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	w.log.Info("starting worker",
+		zap.String("worker", w.name),
+		zap.Duration("interval", w.interval),
+	)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.job(); err != nil {
+			w.log.Error("worker job failed",
+				zap.String("worker", w.name),
+				zap.Error(err),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			w.log.Info("stopping worker", zap.String("worker", w.name))
+			return
+		case <-ticker.C:
+		}
+	}
+}
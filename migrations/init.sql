@@ -6,15 +6,56 @@ CREATE TABLE IF NOT EXISTS users
     username      VARCHAR(50) UNIQUE NOT NULL,
     password_hash VARCHAR(255)       NOT NULL,
     coins         BIGINT             NOT NULL DEFAULT 1000,
+    reserved_coins BIGINT            NOT NULL DEFAULT 0 CHECK (reserved_coins >= 0),
+    preorder_coins BIGINT            NOT NULL DEFAULT 0 CHECK (preorder_coins >= 0),
+    giftable_coins BIGINT            NOT NULL DEFAULT 0 CHECK (giftable_coins >= 0),
+    allowance_period DATE,
+    email         VARCHAR(255) UNIQUE,
+    email_opt_out BOOLEAN            NOT NULL DEFAULT FALSE,
+    role          VARCHAR(20)        NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'treasurer', 'manager',
+                                                                                 'shop_manager', 'system')),
     created_at    TIMESTAMP WITH TIME ZONE    DEFAULT CURRENT_TIMESTAMP,
     updated_at    TIMESTAMP WITH TIME ZONE    DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS teams
+(
+    id             BIGSERIAL PRIMARY KEY,
+    name           VARCHAR(100) UNIQUE NOT NULL,
+    lead_id        BIGINT UNIQUE REFERENCES users (id) ON DELETE SET NULL,
+    budget         BIGINT NOT NULL DEFAULT 0 CHECK (budget >= 0),
+    monthly_budget BIGINT NOT NULL DEFAULT 0 CHECK (monthly_budget >= 0),
+    budget_period  DATE,
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS team_id BIGINT REFERENCES teams (id) ON DELETE SET NULL;
+
 CREATE TABLE IF NOT EXISTS merch
 (
     id    BIGSERIAL PRIMARY KEY,
     name  VARCHAR(50) UNIQUE NOT NULL,
-    price BIGINT             NOT NULL CHECK (price > 0)
+    price           BIGINT             NOT NULL CHECK (price > 0),
+    stock           BIGINT CHECK (stock >= 0),
+    category        VARCHAR(30),
+    available_from  TIMESTAMP WITH TIME ZONE,
+    available_until TIMESTAMP WITH TIME ZONE,
+    per_user_limit  BIGINT CHECK (per_user_limit > 0),
+    preorders_open  BOOLEAN            NOT NULL DEFAULT FALSE,
+    cancelled_at    TIMESTAMP WITH TIME ZONE,
+    CHECK (available_until IS NULL OR available_from IS NULL OR available_until > available_from),
+    CHECK (NOT preorders_open OR available_from IS NOT NULL)
+);
+
+CREATE TABLE IF NOT EXISTS merch_variants
+(
+    id       BIGSERIAL PRIMARY KEY,
+    merch_id BIGINT      NOT NULL REFERENCES merch (id) ON DELETE CASCADE,
+    sku      VARCHAR(50) UNIQUE NOT NULL,
+    size     VARCHAR(20),
+    color    VARCHAR(30),
+    stock    BIGINT CHECK (stock >= 0),
+    CHECK (size IS NOT NULL OR color IS NOT NULL)
 );
 
 CREATE TABLE IF NOT EXISTS user_inventory
@@ -22,9 +63,28 @@ CREATE TABLE IF NOT EXISTS user_inventory
     id         BIGSERIAL PRIMARY KEY,
     user_id    BIGINT REFERENCES users (id) ON DELETE CASCADE,
     merch_id   BIGINT REFERENCES merch (id) ON DELETE CASCADE,
+    variant_id BIGINT REFERENCES merch_variants (id) ON DELETE CASCADE,
     quantity   BIGINT NOT NULL          DEFAULT 0 CHECK (quantity >= 0),
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE (user_id, merch_id)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS promotions
+(
+    id             BIGSERIAL PRIMARY KEY,
+    name           VARCHAR(100) NOT NULL,
+    code           VARCHAR(50) UNIQUE,
+    kind           VARCHAR(20)  NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value          BIGINT       NOT NULL CHECK (value > 0),
+    merch_id       BIGINT REFERENCES merch (id) ON DELETE CASCADE,
+    category       VARCHAR(30),
+    starts_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ends_at        TIMESTAMP WITH TIME ZONE,
+    per_user_limit BIGINT CHECK (per_user_limit > 0),
+    max_uses       BIGINT CHECK (max_uses > 0),
+    created_by     BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (kind <> 'percent' OR value <= 100),
+    CHECK (ends_at IS NULL OR ends_at >= starts_at)
 );
 
 CREATE TABLE IF NOT EXISTS transactions
@@ -32,30 +92,381 @@ CREATE TABLE IF NOT EXISTS transactions
     id           BIGSERIAL PRIMARY KEY,
     from_user_id BIGINT REFERENCES users (id) ON DELETE CASCADE,
     to_user_id   BIGINT REFERENCES users (id) ON DELETE CASCADE,
-    amount       BIGINT      NOT NULL CHECK (amount > 0),
-    type         VARCHAR(20) NOT NULL CHECK (type IN ('transfer', 'purchase')),
+    amount       BIGINT      NOT NULL CHECK (amount >= 0),
+    giftable_amount BIGINT   NOT NULL DEFAULT 0 CHECK (giftable_amount >= 0 AND giftable_amount <= amount),
+    type         VARCHAR(20) NOT NULL CHECK (type IN ('transfer', 'purchase', 'grant', 'clawback', 'reversal', 'expiry',
+                                                     'allowance', 'team_budget', 'refund', 'gift', 'item_transfer',
+                                                     'marketplace_sale', 'marketplace_fee')),
     merch_id     BIGINT REFERENCES merch (id) ON DELETE CASCADE,
-    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    variant_id   BIGINT REFERENCES merch_variants (id) ON DELETE SET NULL,
+    original_amount BIGINT CHECK (original_amount >= amount),
+    promotion_id BIGINT REFERENCES promotions (id) ON DELETE SET NULL,
+    memo         VARCHAR(140),
+    category     VARCHAR(20) CHECK (category IN ('thanks', 'lunch', 'gift', 'help', 'other')),
+    reason       VARCHAR(255),
+    created_by   BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    original_transaction_id BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (type NOT IN ('grant', 'clawback') OR reason IS NOT NULL),
+    CHECK (amount > 0 OR type = 'item_transfer')
+);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions
+(
+    id             BIGSERIAL PRIMARY KEY,
+    promotion_id   BIGINT NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    user_id        BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    transaction_id BIGINT NOT NULL UNIQUE REFERENCES transactions (id) ON DELETE CASCADE,
+    discount       BIGINT NOT NULL CHECK (discount > 0),
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coin_lots
+(
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount      BIGINT NOT NULL CHECK (amount > 0),
+    remaining   BIGINT NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP + INTERVAL '12 months'
+);
+
+CREATE TABLE IF NOT EXISTS balance_snapshots
+(
+    user_id  BIGINT REFERENCES users (id) ON DELETE CASCADE,
+    balance  BIGINT NOT NULL,
+    taken_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, taken_at)
+);
+
+CREATE TABLE IF NOT EXISTS payment_requests
+(
+    id             BIGSERIAL PRIMARY KEY,
+    requester_id   BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    payer_id       BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount         BIGINT      NOT NULL CHECK (amount > 0),
+    memo           VARCHAR(140),
+    status         VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled', 'expired')),
+    transaction_id BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at    TIMESTAMP WITH TIME ZONE,
+    CHECK (requester_id <> payer_id)
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfers
+(
+    id           BIGSERIAL PRIMARY KEY,
+    from_user_id BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    to_user_id   BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount       BIGINT      NOT NULL CHECK (amount > 0),
+    memo         VARCHAR(140),
+    category     VARCHAR(20) CHECK (category IN ('thanks', 'lunch', 'gift', 'help', 'other')),
+    schedule     VARCHAR(100),
+    next_run_at  TIMESTAMP WITH TIME ZONE,
+    status       VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled')),
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_user_id <> to_user_id)
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfer_runs
+(
+    id                    BIGSERIAL PRIMARY KEY,
+    scheduled_transfer_id BIGINT      NOT NULL REFERENCES scheduled_transfers (id) ON DELETE CASCADE,
+    status                VARCHAR(20) NOT NULL
+        CHECK (status IN ('running', 'succeeded', 'pending_approval', 'failed')),
+    error                 TEXT,
+    scheduled_for         TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at            TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (scheduled_transfer_id, scheduled_for)
+);
+
+CREATE TABLE IF NOT EXISTS transfer_reversals
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    transaction_id          BIGINT       NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    requested_by            BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason                  VARCHAR(255) NOT NULL,
+    status                  VARCHAR(20)  NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reversal_transaction_id BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    resolved_by             BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at              TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at             TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS pending_transfers
+(
+    id             BIGSERIAL PRIMARY KEY,
+    from_user_id   BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    to_user_id     BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount         BIGINT      NOT NULL CHECK (amount > 0),
+    giftable_amount BIGINT     NOT NULL DEFAULT 0 CHECK (giftable_amount >= 0 AND giftable_amount <= amount),
+    allowance_period DATE,
+    memo           VARCHAR(140),
+    category       VARCHAR(20) CHECK (category IN ('thanks', 'lunch', 'gift', 'help', 'other')),
+    status         VARCHAR(20) NOT NULL DEFAULT 'pending_approval'
+        CHECK (status IN ('pending_approval', 'approved', 'rejected')),
+    transaction_id BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    resolved_by    BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at    TIMESTAMP WITH TIME ZONE,
+    CHECK (from_user_id <> to_user_id)
+);
+
+ALTER TABLE payment_requests
+    ADD COLUMN IF NOT EXISTS pending_transfer_id BIGINT REFERENCES pending_transfers (id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS preorders
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    user_id                 BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    merch_id                BIGINT      NOT NULL REFERENCES merch (id) ON DELETE CASCADE,
+    variant_id              BIGINT REFERENCES merch_variants (id) ON DELETE SET NULL,
+    amount                  BIGINT      NOT NULL CHECK (amount > 0),
+    status                  VARCHAR(20) NOT NULL DEFAULT 'reserved'
+        CHECK (status IN ('reserved', 'fulfilled', 'cancelled', 'refunded')),
+    purchase_transaction_id BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    created_at              TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at             TIMESTAMP WITH TIME ZONE
+);
+
+-- Each row is the share of one lot taken by a debit, kept so that undoing the
+-- debit can put the coins back into the lot with its original expiry.
+CREATE TABLE IF NOT EXISTS coin_lot_debits
+(
+    id                  BIGSERIAL PRIMARY KEY,
+    lot_id              BIGINT NOT NULL REFERENCES coin_lots (id) ON DELETE CASCADE,
+    amount              BIGINT NOT NULL CHECK (amount > 0),
+    transaction_id      BIGINT REFERENCES transactions (id) ON DELETE CASCADE,
+    pending_transfer_id BIGINT REFERENCES pending_transfers (id) ON DELETE CASCADE,
+    preorder_id         BIGINT REFERENCES preorders (id) ON DELETE CASCADE,
+    restored_at         TIMESTAMP WITH TIME ZONE,
+    CHECK (num_nonnulls(transaction_id, pending_transfer_id, preorder_id) = 1)
+);
+
+CREATE TABLE IF NOT EXISTS returns
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    user_id                 BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purchase_transaction_id BIGINT      NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    merch_id                BIGINT      NOT NULL REFERENCES merch (id) ON DELETE CASCADE,
+    variant_id              BIGINT REFERENCES merch_variants (id) ON DELETE SET NULL,
+    amount                  BIGINT      NOT NULL CHECK (amount > 0),
+    reason                  VARCHAR(255),
+    status                  VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    refund_transaction_id   BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    resolved_by             BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at              TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at             TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS orders
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    user_id                 BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    merch_id                BIGINT      NOT NULL REFERENCES merch (id) ON DELETE CASCADE,
+    variant_id              BIGINT REFERENCES merch_variants (id) ON DELETE SET NULL,
+    purchase_transaction_id BIGINT      NOT NULL UNIQUE REFERENCES transactions (id) ON DELETE CASCADE,
+    status                  VARCHAR(20) NOT NULL DEFAULT 'placed'
+        CHECK (status IN ('placed', 'ready_for_pickup', 'handed_over', 'cancelled')),
+    refund_transaction_id   BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    created_at              TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at              TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS listings
+(
+    id                  BIGSERIAL PRIMARY KEY,
+    seller_id           BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    merch_id            BIGINT      NOT NULL REFERENCES merch (id) ON DELETE CASCADE,
+    variant_id          BIGINT REFERENCES merch_variants (id) ON DELETE SET NULL,
+    price               BIGINT      NOT NULL CHECK (price > 0),
+    status              VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'sold', 'cancelled')),
+    buyer_id            BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    sale_transaction_id BIGINT REFERENCES transactions (id) ON DELETE SET NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    closed_at           TIMESTAMP WITH TIME ZONE,
+    CHECK (buyer_id IS NULL OR buyer_id <> seller_id)
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items
+(
+    id                  BIGSERIAL PRIMARY KEY,
+    user_id             BIGINT  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    merch_id            BIGINT  NOT NULL REFERENCES merch (id) ON DELETE CASCADE,
+    variant_id          BIGINT REFERENCES merch_variants (id) ON DELETE CASCADE,
+    last_price          BIGINT  NOT NULL CHECK (last_price > 0),
+    in_stock            BOOLEAN NOT NULL,
+    affordable_notified BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notifications
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       VARCHAR(30)  NOT NULL CHECK (type IN ('coins_received', 'coins_granted', 'merch_purchased',
+                                                     'gift_received', 'wishlist_affordable', 'wishlist_price_drop',
+                                                     'wishlist_back_in_stock')),
+    message    VARCHAR(255) NOT NULL,
+    merch_id   BIGINT REFERENCES merch (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    read_at    TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS notification_mutes
+(
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, type)
+);
+
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id           BIGSERIAL PRIMARY KEY,
+    type         VARCHAR(50) NOT NULL,
+    payload      JSONB       NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id          BIGSERIAL PRIMARY KEY,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(100)  NOT NULL,
+    event_types TEXT[]        NOT NULL,
+    active      BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_by  BIGINT        NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      BIGINT      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        BIGINT      NOT NULL REFERENCES outbox_events (id),
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    response_status INT,
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delivered_at    TIMESTAMP WITH TIME ZONE,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE TABLE IF NOT EXISTS emails
+(
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    template        VARCHAR(50) NOT NULL,
+    data            JSONB       NOT NULL,
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    sent_at         TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS slack_links
+(
+    team_id       VARCHAR(50) NOT NULL,
+    slack_user_id VARCHAR(50) NOT NULL,
+    user_id       BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, slack_user_id)
+);
+
+CREATE TABLE IF NOT EXISTS slack_link_codes
+(
+    code       VARCHAR(20) PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_users_team_id ON users (team_id);
 CREATE INDEX IF NOT EXISTS idx_user_inventory_user_id ON user_inventory (user_id);
-CREATE INDEX IF NOT EXISTS idx_transactions_from_user_id ON transactions (from_user_id);
-CREATE INDEX IF NOT EXISTS idx_transactions_to_user_id ON transactions (to_user_id);
-
-INSERT INTO merch (name, price)
-VALUES ('t-shirt', 80),
-       ('cup', 20),
-       ('book', 50),
-       ('pen', 10),
-       ('powerbank', 200),
-       ('hoody', 300),
-       ('umbrella', 200),
-       ('socks', 10),
-       ('wallet', 50),
-       ('pink-hoody', 500)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_inventory_item ON user_inventory (user_id, merch_id, COALESCE(variant_id, 0));
+CREATE INDEX IF NOT EXISTS idx_merch_variants_merch_id ON merch_variants (merch_id);
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_promotion_id ON promotion_redemptions (promotion_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_from_user_id_created_at ON transactions (from_user_id, created_at) INCLUDE (amount);
+CREATE INDEX IF NOT EXISTS idx_transactions_to_user_id_created_at ON transactions (to_user_id, created_at) INCLUDE (amount);
+CREATE INDEX IF NOT EXISTS idx_transactions_adjustments ON transactions (created_at) WHERE type IN ('grant', 'clawback');
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfer_reversals_open ON transfer_reversals (transaction_id)
+    WHERE status IN ('pending', 'approved');
+CREATE INDEX IF NOT EXISTS idx_pending_transfers_from_user_id ON pending_transfers (from_user_id, created_at)
+    WHERE status = 'pending_approval';
+CREATE INDEX IF NOT EXISTS idx_coin_lots_user_id ON coin_lots (user_id, received_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_coin_lots_expires_at ON coin_lots (expires_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_coin_lot_debits_transaction_id ON coin_lot_debits (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_coin_lot_debits_pending_transfer_id ON coin_lot_debits (pending_transfer_id);
+CREATE INDEX IF NOT EXISTS idx_coin_lot_debits_preorder_id ON coin_lot_debits (preorder_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_returns_open ON returns (purchase_transaction_id)
+    WHERE status IN ('pending', 'approved');
+CREATE INDEX IF NOT EXISTS idx_returns_user_id ON returns (user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_merch_id ON orders (merch_id, user_id) WHERE status <> 'cancelled';
+CREATE INDEX IF NOT EXISTS idx_preorders_user_id ON preorders (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_preorders_reserved ON preorders (merch_id, created_at) WHERE status = 'reserved';
+CREATE INDEX IF NOT EXISTS idx_orders_open ON orders (status, created_at)
+    WHERE status IN ('placed', 'ready_for_pickup');
+CREATE INDEX IF NOT EXISTS idx_listings_active ON listings (merch_id, price) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_listings_seller_id ON listings (seller_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlist_items_item ON wishlist_items (user_id, merch_id, COALESCE(variant_id, 0));
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (user_id) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_slack_links_user_id ON slack_links (user_id);
+CREATE INDEX IF NOT EXISTS idx_emails_due ON emails (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_requester_id ON payment_requests (requester_id);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_payer_id ON payment_requests (payer_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_from_user_id ON scheduled_transfers (from_user_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers (next_run_at) WHERE status = 'active';
+
+-- The system account collects marketplace fees. Its password hash matches no
+-- password, and login refuses the role anyway.
+INSERT INTO users (username, password_hash, coins, role)
+VALUES ('system', '!', 0, 'system')
+ON CONFLICT (username) DO NOTHING;
+
+-- Balances that predate lot tracking become a single lot each.
+INSERT INTO coin_lots (user_id, amount, remaining)
+SELECT u.id, u.coins, u.coins
+FROM users u
+WHERE u.coins > 0
+  AND NOT EXISTS (SELECT 1 FROM coin_lots l WHERE l.user_id = u.id);
+
+INSERT INTO merch (name, price, category)
+VALUES ('t-shirt', 80, 'apparel'),
+       ('cup', 20, 'accessories'),
+       ('book', 50, 'stationery'),
+       ('pen', 10, 'stationery'),
+       ('powerbank', 200, 'accessories'),
+       ('hoody', 300, 'apparel'),
+       ('umbrella', 200, 'accessories'),
+       ('socks', 10, 'apparel'),
+       ('wallet', 50, 'accessories'),
+       ('pink-hoody', 500, 'apparel')
 ON CONFLICT (name) DO NOTHING;
 
+INSERT INTO merch_variants (merch_id, sku, size, color, stock)
+SELECT m.id, v.sku, v.size, v.color, v.stock
+FROM merch m
+         JOIN (VALUES ('HOODY-BLK-S', 'S', 'black', 20),
+                      ('HOODY-BLK-M', 'M', 'black', 20),
+                      ('HOODY-BLK-L', 'L', 'black', 20),
+                      ('HOODY-BLK-XL', 'XL', 'black', 10)) AS v (sku, size, color, stock) ON m.name = 'hoody'
+ON CONFLICT (sku) DO NOTHING;
+
 CREATE OR REPLACE FUNCTION update_updated_at_column()
     RETURNS TRIGGER AS
 $$
@@ -69,4 +480,10 @@ CREATE TRIGGER update_users_updated_at
     BEFORE UPDATE
     ON users
     FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_orders_updated_at
+    BEFORE UPDATE
+    ON orders
+    FOR EACH ROW
 EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
@@ -9,6 +9,7 @@ import (
 const (
 	BadRequest          = 400
 	UnauthorizedError   = 401
+	ForbiddenError      = 403
 	NotFoundError       = 404
 	ValidationError     = 422
 	InternalServerError = 500
@@ -46,6 +47,14 @@ func IsAppError(err error) bool {
 	return stderrors.As(err, &appErr)
 }
 
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
 func ErrBadRequest(msg string) *AppError {
 	return NewAppError(BadRequest, msg, nil)
 }
@@ -54,6 +63,10 @@ func ErrUnauthorized(msg string) *AppError {
 	return NewAppError(UnauthorizedError, msg, nil)
 }
 
+func ErrForbidden(msg string) *AppError {
+	return NewAppError(ForbiddenError, msg, nil)
+}
+
 func ErrNotFound(msg string) *AppError {
 	return NewAppError(NotFoundError, msg, nil)
 }
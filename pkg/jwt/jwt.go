@@ -23,6 +23,7 @@ type TokenManager struct {
 type Claims struct {
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -33,10 +34,11 @@ func NewTokenManager(jwt config.JWTCredentials) (*TokenManager, error) {
 	}, nil
 }
 
-func (m *TokenManager) NewJWT(userID int64, username string) (string, error) {
+func (m *TokenManager) NewJWT(userID int64, username, role string) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
+		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
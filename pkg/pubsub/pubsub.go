@@ -0,0 +1,186 @@
+// Package pubsub delivers events to the open streams of a user. Without a
+// relay the events stay within the process; with one, an event published on
+// any replica reaches the subscribers of every replica.
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/icoder-new/avito-shop/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	subscriptionBuffer = 16
+	minRelayBackoff    = time.Second
+	maxRelayBackoff    = 30 * time.Second
+)
+
+// Event is a message for one user. Data is sent to the client as is.
+type Event struct {
+	UserID int64           `json:"userId"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Relay carries events between replicas. Listen blocks, handing every
+// payload published by any replica, this one included, to handle until the
+// context is cancelled or the connection fails.
+type Relay interface {
+	Publish(payload []byte) error
+	Listen(ctx context.Context, handle func(payload []byte)) error
+}
+
+// Subscription receives the events of one user on C until it is
+// unsubscribed.
+type Subscription struct {
+	C <-chan Event
+
+	userID int64
+	ch     chan Event
+}
+
+type Broker struct {
+	log   *logger.Logger
+	relay Relay
+
+	mu   sync.RWMutex
+	subs map[int64]map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker. A nil relay keeps events within the process.
+func NewBroker(log *logger.Logger, relay Relay) *Broker {
+	return &Broker{
+		log:   log,
+		relay: relay,
+		subs:  make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe(userID int64) *Subscription {
+	ch := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: ch, userID: userID, ch: ch}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*Subscription]struct{})
+	}
+	b.subs[userID][sub] = struct{}{}
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs, ok := b.subs[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok = subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.userID)
+	}
+	close(sub.ch)
+}
+
+// Publish sends an event to the user's subscribers. It never blocks on a
+// slow subscriber: an event that does not fit its buffer is dropped for it.
+func (b *Broker) Publish(userID int64, eventType string, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		b.log.Error("failed to marshal event",
+			zap.String("type", eventType),
+			zap.Error(err),
+		)
+		return
+	}
+
+	event := Event{UserID: userID, Type: eventType, Data: raw}
+	if b.relay == nil {
+		b.deliver(event)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err == nil {
+		err = b.relay.Publish(payload)
+	}
+	if err != nil {
+		b.log.Error("failed to relay event, delivering locally",
+			zap.String("type", eventType),
+			zap.Error(err),
+		)
+		b.deliver(event)
+	}
+}
+
+// Run listens to the relay until the context is cancelled, reconnecting with
+// a growing delay when the connection fails. Without a relay it only waits
+// for the context.
+func (b *Broker) Run(ctx context.Context) {
+	if b.relay == nil {
+		<-ctx.Done()
+		return
+	}
+
+	backoff := minRelayBackoff
+	for {
+		started := time.Now()
+		err := b.relay.Listen(ctx, b.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxRelayBackoff {
+			backoff = minRelayBackoff
+		}
+
+		b.log.Error("event relay disconnected",
+			zap.Duration("retry_in", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRelayBackoff)
+	}
+}
+
+func (b *Broker) receive(payload []byte) {
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		b.log.Error("failed to decode relayed event", zap.Error(err))
+		return
+	}
+
+	b.deliver(event)
+}
+
+func (b *Broker) deliver(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs[event.UserID] {
+		select {
+		case sub.ch <- event:
+		default:
+			b.log.Warn("dropping event for slow subscriber",
+				zap.Int64("user_id", event.UserID),
+				zap.String("type", event.Type),
+			)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icoder-new/avito-shop/pkg/cron"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronSchedule(t *testing.T) {
+	assert := assert.New(t)
+
+	// Wednesday.
+	from := time.Date(2025, time.January, 15, 10, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2025, time.January, 20, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, time.January, 19, 9, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2025, time.January, 16, 10, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		schedule, err := cron.Parse(tc.spec)
+		require.NoError(t, err, tc.spec)
+		assert.Equal(tc.want, schedule.Next(from), tc.spec)
+	}
+
+	t.Run("impossible schedule", func(t *testing.T) {
+		schedule, err := cron.Parse("0 0 30 2 *")
+		require.NoError(t, err)
+		assert.True(schedule.Next(from).IsZero())
+	})
+
+	t.Run("invalid specs", func(t *testing.T) {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+			_, err := cron.Parse(spec)
+			assert.Error(err, spec)
+		}
+	})
+}
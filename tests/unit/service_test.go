@@ -2,23 +2,36 @@ package unit
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"github.com/icoder-new/avito-shop/internal/config"
 	"github.com/icoder-new/avito-shop/internal/dto"
+	"github.com/icoder-new/avito-shop/internal/email"
 	"github.com/icoder-new/avito-shop/internal/models"
+	"github.com/icoder-new/avito-shop/internal/outbox"
 	"github.com/icoder-new/avito-shop/internal/service"
 	"github.com/icoder-new/avito-shop/internal/storage"
 	"github.com/icoder-new/avito-shop/internal/storage/postgres"
 	"github.com/icoder-new/avito-shop/pkg/jwt"
 	"github.com/icoder-new/avito-shop/pkg/logger"
+	"github.com/icoder-new/avito-shop/pkg/pubsub"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
 
 var (
+	testLogger  *logger.Logger
 	testStorage storage.IStorage
 	testService service.IService
+	testSMTP    *fakeSMTP
 )
 
 func setupTestEnv(t *testing.T) {
@@ -27,6 +40,7 @@ func setupTestEnv(t *testing.T) {
 
 	log, err := logger.New(cfg.Settings.Logger)
 	require.NoError(t, err)
+	testLogger = log
 
 	storage, err := postgres.NewStorage(context.Background(), log, cfg.GetDSN(), cfg.Settings.DB)
 	require.NoError(t, err)
@@ -38,7 +52,13 @@ func setupTestEnv(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	testService = service.NewService(cfg, log, testStorage, jwtManager)
+	testSMTP = startFakeSMTP(t)
+	cfg.Settings.Email.Enabled = true
+	cfg.Settings.Email.Host = "127.0.0.1"
+	cfg.Settings.Email.Port = testSMTP.Port()
+	sender := email.NewSMTPSender(cfg.Settings.Email, config.SMTPCredentials{})
+
+	testService = service.NewService(cfg, log, testStorage, jwtManager, pubsub.NewBroker(log, nil), sender)
 }
 
 func cleanup(t *testing.T) {
@@ -94,7 +114,7 @@ func TestCoinService(t *testing.T) {
 			Amount: 500,
 		}
 
-		err := testService.Coin().Send(user1.ID, sendReq)
+		_, err := testService.Coin().Send(user1.ID, sendReq)
 		assert.NoError(err)
 
 		sender, err := testService.User().GetInfo(user1.ID)
@@ -112,9 +132,458 @@ func TestCoinService(t *testing.T) {
 			Amount: 2000,
 		}
 
-		err := testService.Coin().Send(user1.ID, sendReq)
+		_, err := testService.Coin().Send(user1.ID, sendReq)
+		assert.Error(err)
+	})
+
+	t.Run("system account is not a recipient", func(t *testing.T) {
+		_, err := testService.Coin().Send(user1.ID, dto.SendCoinRequest{ToUser: "system", Amount: 10})
+		assert.ErrorContains(err, "recipient not found")
+
+		_, err = testService.PaymentRequest().Create(user2.ID, dto.CreatePaymentRequest{FromUser: "system", Amount: 10})
+		assert.ErrorContains(err, "payer not found")
+	})
+}
+
+func TestBatchTransfer(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	manager := createTestUser(t, "batch-manager")
+	createTestUser(t, "batch-alice")
+	createTestUser(t, "batch-bob")
+
+	t.Run("invalid recipient rejects whole batch", func(t *testing.T) {
+		resp, err := testService.Coin().SendBatch(manager.ID, dto.BatchSendCoinRequest{
+			Transfers: []dto.BatchTransfer{
+				{ToUser: "batch-alice", Amount: 100},
+				{ToUser: "batch-nobody", Amount: 100},
+			},
+		})
+		assert.Error(err)
+		assert.Equal("rejected", resp.Status)
+		assert.Equal("not_executed", resp.Results[0].Status)
+		assert.Equal("invalid", resp.Results[1].Status)
+
+		info, err := testService.User().GetInfo(manager.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1000), info.Coins)
+	})
+
+	t.Run("valid batch", func(t *testing.T) {
+		resp, err := testService.Coin().SendBatch(manager.ID, dto.BatchSendCoinRequest{
+			Transfers: []dto.BatchTransfer{
+				{ToUser: "batch-alice", Amount: 100, Memo: "hackathon"},
+				{ToUser: "batch-bob", Amount: 200},
+			},
+		})
+		assert.NoError(err)
+		assert.Equal("completed", resp.Status)
+		assert.NotZero(resp.Results[1].TransactionID)
+
+		info, err := testService.User().GetInfo(manager.ID)
+		assert.NoError(err)
+		assert.Equal(int64(700), info.Coins)
+	})
+
+	t.Run("total exceeds balance", func(t *testing.T) {
+		_, err := testService.Coin().SendBatch(manager.ID, dto.BatchSendCoinRequest{
+			Transfers: []dto.BatchTransfer{
+				{ToUser: "batch-alice", Amount: 400},
+				{ToUser: "batch-bob", Amount: 400},
+			},
+		})
+		assert.Error(err)
+	})
+}
+
+func TestScheduledTransferRuns(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "scheduler")
+	recipient := createTestUser(t, "scheduler-friend")
+
+	due := time.Now().Add(-time.Minute).UTC().Truncate(time.Microsecond)
+	schedule := func(amount int64, cron string) int64 {
+		created, err := testStorage.ScheduledTransfer().Create(models.ScheduledTransfer{
+			FromUserID: sender.ID,
+			ToUserID:   recipient.ID,
+			Amount:     amount,
+			Schedule:   cron,
+			NextRunAt:  &due,
+		})
+		require.NoError(t, err)
+		return created.ID
+	}
+
+	oneOff := schedule(10, "")
+	recurring := schedule(20, "@daily")
+	held := schedule(600, "")
+	failing := schedule(1500, "")
+
+	require.NoError(t, testService.ScheduledTransfer().RunDue())
+	require.NoError(t, testService.ScheduledTransfer().RunDue(), "runs are claimed once")
+
+	runs := func(transferID int64) []dto.ScheduledTransferRun {
+		runs, err := testService.ScheduledTransfer().GetRuns(sender.ID, transferID)
+		require.NoError(t, err)
+		return runs
+	}
+
+	cases := []struct {
+		transferID int64
+		status     string
+		error      string
+	}{
+		{oneOff, "succeeded", ""},
+		{recurring, "succeeded", ""},
+		{held, "pending_approval", ""},
+		{failing, "failed", "amount exceeds the per-transfer limit of 1000 coins"},
+	}
+	for _, tc := range cases {
+		if got := runs(tc.transferID); assert.Len(got, 1) {
+			assert.Equal(tc.status, got[0].Status)
+			assert.Equal(tc.error, got[0].Error)
+			assert.True(due.Equal(got[0].ScheduledFor))
+		}
+	}
+
+	transfers, err := testService.ScheduledTransfer().List(sender.ID)
+	require.NoError(t, err)
+	for _, transfer := range transfers {
+		if transfer.ID == recurring {
+			assert.Equal("active", transfer.Status, "recurring transfers stay active")
+			if assert.NotNil(transfer.NextRunAt) {
+				assert.True(transfer.NextRunAt.After(time.Now()))
+			}
+		} else {
+			assert.Equal("completed", transfer.Status)
+			assert.Nil(transfer.NextRunAt)
+		}
+	}
+
+	info, err := testService.User().GetInfo(recipient.ID)
+	require.NoError(t, err)
+	assert.Equal(int64(1030), info.Coins, "only the executed transfers are paid")
+
+	info, err = testService.User().GetInfo(sender.ID)
+	require.NoError(t, err)
+	assert.Equal(int64(600), info.PendingCoins)
+}
+
+func TestTreasury(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	treasurer := createTestUser(t, "treasury-keeper")
+	first := createTestUser(t, "treasury-first")
+	second := createTestUser(t, "treasury-second")
+
+	coins := func(user *models.User) int64 {
+		info, err := testService.User().GetInfo(user.ID)
+		require.NoError(t, err)
+		return info.Coins
+	}
+
+	t.Run("grant", func(t *testing.T) {
+		grants, err := testService.Treasury().Grant(treasurer.ID, dto.GrantRequest{
+			Usernames: []string{"treasury-first", "treasury-second", "treasury-first"},
+			Amount:    150,
+			Reason:    "hackathon winners",
+		})
+		require.NoError(t, err)
+		assert.Len(grants, 2, "duplicate usernames are granted once")
+		assert.Equal(int64(1150), coins(first))
+		assert.Equal(int64(1150), coins(second))
+
+		_, err = testService.Treasury().Grant(treasurer.ID, dto.GrantRequest{
+			Usernames: []string{"treasury-first", "treasury-nobody"},
+			Amount:    150,
+			Reason:    "typo",
+		})
+		assert.ErrorContains(err, "some users were not found")
+		assert.Equal(int64(1150), coins(first))
+
+		_, err = testService.Treasury().Grant(treasurer.ID, dto.GrantRequest{
+			Usernames: []string{"treasury-first", "system"},
+			Amount:    150,
+			Reason:    "fees",
+		})
+		assert.ErrorContains(err, "some users were not found", "the system account cannot be granted coins")
+		assert.Equal(int64(1150), coins(first))
+	})
+
+	t.Run("clawback above the balance", func(t *testing.T) {
+		_, err := testService.Treasury().Clawback(treasurer.ID, dto.ClawbackRequest{
+			Username: "treasury-first",
+			Amount:   5000,
+			Reason:   "overpaid",
+		})
+		assert.ErrorContains(err, "clawback exceeds user balance")
+		assert.Equal(int64(1150), coins(first))
+	})
+
+	t.Run("missing reason", func(t *testing.T) {
+		_, err := testService.Treasury().Grant(treasurer.ID, dto.GrantRequest{
+			Usernames: []string{"treasury-first"},
+			Amount:    100,
+		})
+		assert.Error(err, "the database requires a reason")
+
+		_, err = testService.Treasury().Clawback(treasurer.ID, dto.ClawbackRequest{
+			Username: "treasury-first",
+			Amount:   100,
+		})
+		assert.Error(err, "the database requires a reason")
+		assert.Equal(int64(1150), coins(first))
+	})
+
+	t.Run("audit listing", func(t *testing.T) {
+		clawback, err := testService.Treasury().Clawback(treasurer.ID, dto.ClawbackRequest{
+			Username: "treasury-first",
+			Amount:   50,
+			Reason:   "duplicate payout",
+		})
+		require.NoError(t, err)
+		assert.Equal(int64(1100), coins(first))
+
+		adjustments, err := testService.Treasury().GetAdjustments(dto.AdjustmentsQuery{Limit: 3})
+		require.NoError(t, err)
+		require.Len(t, adjustments, 3)
+
+		assert.Equal(clawback.TransactionID, adjustments[0].TransactionID)
+		assert.Equal("clawback", adjustments[0].Type)
+		assert.Equal("treasury-first", adjustments[0].Username)
+		assert.Equal("duplicate payout", adjustments[0].Reason)
+
+		for _, grant := range adjustments[1:] {
+			assert.Equal("grant", grant.Type)
+			assert.Equal(int64(150), grant.Amount)
+			assert.Equal("hackathon winners", grant.Reason)
+		}
+		for _, adjustment := range adjustments {
+			assert.Equal("treasury-keeper", adjustment.CreatedBy)
+		}
+	})
+}
+
+func TestReversals(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "reversal-sender")
+	recipient := createTestUser(t, "reversal-recipient")
+	treasurer := createTestUser(t, "reversal-treasurer")
+	outsider := createTestUser(t, "reversal-outsider")
+
+	for _, memo := range []string{"wrong person", "double click"} {
+		_, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "reversal-recipient", Amount: 100, Memo: memo})
+		require.NoError(t, err)
+	}
+	require.NoError(t, testService.Inventory().BuyItem(sender.ID, "cup", dto.BuyQuery{}))
+
+	transactionID := func(query dto.HistoryQuery) int64 {
+		history, err := testService.History().GetHistory(sender.ID, query)
+		require.NoError(t, err)
+		require.Len(t, history.Transactions, 1)
+		return history.Transactions[0].ID
+	}
+	first := transactionID(dto.HistoryQuery{Query: "wrong person"})
+	second := transactionID(dto.HistoryQuery{Query: "double click"})
+	purchase := transactionID(dto.HistoryQuery{Type: "purchase"})
+
+	coins := func(user *models.User) int64 {
+		info, err := testService.User().GetInfo(user.ID)
+		require.NoError(t, err)
+		return info.Coins
+	}
+
+	opened, err := testService.Reversal().Open(sender.ID, first, dto.ReversalRequest{Reason: "sent to the wrong person"})
+	require.NoError(t, err)
+	assert.Equal("pending", opened.Status)
+	assert.Equal(int64(100), opened.Amount)
+	assert.Equal("reversal-recipient", opened.ToUser)
+
+	t.Run("open", func(t *testing.T) {
+		_, err := testService.Reversal().Open(sender.ID, first, dto.ReversalRequest{Reason: "again"})
+		assert.ErrorContains(err, "already has an open or approved reversal")
+
+		_, err = testService.Reversal().Open(recipient.ID, first, dto.ReversalRequest{Reason: "not mine"})
+		assert.ErrorContains(err, "transfer sent by you not found")
+
+		_, err = testService.Reversal().Open(sender.ID, purchase, dto.ReversalRequest{Reason: "changed my mind"})
+		assert.ErrorContains(err, "transfer sent by you not found", "only transfers can be reversed")
+	})
+
+	t.Run("approve", func(t *testing.T) {
+		err := testService.Reversal().Approve(sender.ID, models.RoleUser, opened.ID)
+		assert.ErrorContains(err, "only the recipient or a treasurer")
+		err = testService.Reversal().Approve(sender.ID, models.RoleTreasurer, opened.ID)
+		assert.ErrorContains(err, "only the recipient or a treasurer", "a treasurer cannot approve their own reversal")
+		err = testService.Reversal().Approve(outsider.ID, models.RoleUser, opened.ID)
+		assert.ErrorContains(err, "reversal not found")
+
+		require.NoError(t, testService.Reversal().Approve(recipient.ID, models.RoleUser, opened.ID))
+		assert.Equal(int64(1000-100-20), coins(sender))
+		assert.Equal(int64(1000+100), coins(recipient))
+
+		err = testService.Reversal().Approve(recipient.ID, models.RoleUser, opened.ID)
+		assert.ErrorContains(err, "pending reversal not found")
+
+		_, err = testService.Reversal().Open(sender.ID, first, dto.ReversalRequest{Reason: "again"})
+		assert.ErrorContains(err, "already has an open or approved reversal")
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		rejected, err := testService.Reversal().Open(sender.ID, second, dto.ReversalRequest{Reason: "clicked twice"})
+		require.NoError(t, err)
+
+		require.NoError(t, testService.Reversal().Reject(treasurer.ID, models.RoleTreasurer, rejected.ID))
+		assert.Equal(int64(1000+100), coins(recipient), "a rejected reversal moves no coins")
+
+		reversals, err := testService.Reversal().List(recipient.ID)
+		require.NoError(t, err)
+		statuses := make(map[int64]string, len(reversals.Received))
+		for _, reversal := range reversals.Received {
+			statuses[reversal.ID] = reversal.Status
+		}
+		assert.Equal(map[int64]string{opened.ID: "approved", rejected.ID: "rejected"}, statuses)
+
+		_, err = testService.Reversal().Open(sender.ID, second, dto.ReversalRequest{Reason: "clicked twice, really"})
+		assert.NoError(err, "a rejected reversal can be opened again")
+	})
+}
+
+func TestTransferLimits(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "limits-sender")
+	createTestUser(t, "limits-receiver")
+	require.NoError(t, testStorage.User().UpdateUserCoins(sender.ID, 5000))
+
+	t.Run("per-transfer cap", func(t *testing.T) {
+		_, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "limits-receiver", Amount: 1001})
+		assert.Error(err)
+	})
+
+	t.Run("daily limit", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "limits-receiver", Amount: 1000})
+			assert.NoError(err)
+		}
+
+		_, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "limits-receiver", Amount: 1})
+		assert.ErrorContains(err, "0 coins remaining")
+
+		limits, err := testService.Coin().GetLimits(sender.ID)
+		assert.NoError(err)
+		assert.Equal(int64(2000), limits.Daily.Used)
+		assert.Equal(int64(0), *limits.Daily.Remaining)
+	})
+}
+
+func TestTransferApproval(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "approval-sender")
+	receiver := createTestUser(t, "approval-receiver")
+	manager := createTestUser(t, "approval-manager")
+
+	resp, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "approval-receiver", Amount: 600})
+	assert.NoError(err)
+	assert.Equal("pending_approval", resp.Status)
+	require.NotZero(t, resp.PendingTransferID)
+
+	info, err := testService.User().GetInfo(sender.ID)
+	assert.NoError(err)
+	assert.Equal(int64(400), info.Coins)
+	assert.Equal(int64(600), info.PendingCoins)
+
+	t.Run("sender cannot approve own transfer", func(t *testing.T) {
+		err := testService.Approval().ApproveTransfer(sender.ID, resp.PendingTransferID)
 		assert.Error(err)
 	})
+
+	t.Run("approve", func(t *testing.T) {
+		err := testService.Approval().ApproveTransfer(manager.ID, resp.PendingTransferID)
+		assert.NoError(err)
+
+		info, err := testService.User().GetInfo(sender.ID)
+		assert.NoError(err)
+		assert.Equal(int64(400), info.Coins)
+		assert.Zero(info.PendingCoins)
+
+		info, err = testService.User().GetInfo(receiver.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1600), info.Coins)
+	})
+}
+
+func TestCoinLots(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "lots-owner")
+	friend := createTestUser(t, "lots-friend")
+	manager := createTestUser(t, "lots-manager")
+
+	_, err := testService.Coin().Send(user.ID, dto.SendCoinRequest{ToUser: "lots-friend", Amount: 300})
+	require.NoError(t, err)
+	require.NoError(t, testService.Inventory().BuyItem(user.ID, "cup", dto.BuyQuery{}))
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(680), info.Coins)
+
+	lots, err := testStorage.Coin().GetExpiringLots(user.ID, time.Now().AddDate(1, 0, 1))
+	assert.NoError(err)
+	require.Len(t, lots, 1)
+	assert.Equal(int64(1000), lots[0].Amount)
+	assert.Equal(int64(680), lots[0].Remaining)
+
+	t.Run("rejected transfer goes back to its lot", func(t *testing.T) {
+		resp, err := testService.Coin().Send(user.ID, dto.SendCoinRequest{ToUser: "lots-friend", Amount: 600})
+		require.NoError(t, err)
+		require.Equal(t, "pending_approval", resp.Status)
+		require.NoError(t, testService.Approval().RejectTransfer(manager.ID, resp.PendingTransferID))
+
+		lots, err := testStorage.Coin().GetExpiringLots(user.ID, time.Now().AddDate(1, 0, 1))
+		assert.NoError(err)
+		require.Len(t, lots, 1, "no new lot with a fresh expiry")
+		assert.Equal(int64(680), lots[0].Remaining)
+	})
+
+	t.Run("reversed transfer goes back to its lot", func(t *testing.T) {
+		history, err := testService.History().GetHistory(user.ID, dto.HistoryQuery{Type: "transfer"})
+		require.NoError(t, err)
+		require.Len(t, history.Transactions, 1)
+
+		reversal, err := testService.Reversal().Open(user.ID, history.Transactions[0].ID, dto.ReversalRequest{Reason: "mistake"})
+		require.NoError(t, err)
+		require.NoError(t, testService.Reversal().Approve(friend.ID, models.RoleUser, reversal.ID))
+
+		lots, err := testStorage.Coin().GetExpiringLots(user.ID, time.Now().AddDate(1, 0, 1))
+		assert.NoError(err)
+		require.Len(t, lots, 1, "no new lot with a fresh expiry")
+		assert.Equal(int64(980), lots[0].Remaining)
+	})
 }
 
 func TestInventoryService(t *testing.T) {
@@ -126,7 +595,7 @@ func TestInventoryService(t *testing.T) {
 	user := createTestUser(t, "shopper")
 
 	t.Run("buy item", func(t *testing.T) {
-		err := testService.Inventory().BuyItem(user.ID, "t-shirt")
+		err := testService.Inventory().BuyItem(user.ID, "t-shirt", dto.BuyQuery{})
 		assert.NoError(err)
 
 		info, err := testService.User().GetInfo(user.ID)
@@ -137,14 +606,1249 @@ func TestInventoryService(t *testing.T) {
 	})
 
 	t.Run("buy expensive item with insufficient funds", func(t *testing.T) {
-		err := testService.Inventory().BuyItem(user.ID, "pink-hoody") // стоит 500
+		err := testService.Inventory().BuyItem(user.ID, "pink-hoody", dto.BuyQuery{}) // стоит 500
 		assert.Error(err)
 	})
 
 	t.Run("buy invalid item", func(t *testing.T) {
-		err := testService.Inventory().BuyItem(user.ID, "invalid-item")
+		err := testService.Inventory().BuyItem(user.ID, "invalid-item", dto.BuyQuery{})
+		assert.Error(err)
+	})
+}
+
+func TestBalanceHistory(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	before := time.Now()
+	user := createTestUser(t, "historian")
+	createTestUser(t, "historian-friend")
+
+	t.Run("balance before registration", func(t *testing.T) {
+		balance, err := testService.User().GetBalanceAt(user.ID, before.Add(-time.Hour))
+		assert.NoError(err)
+		assert.Equal(int64(0), balance.Coins)
+	})
+
+	t.Run("balance follows transfers", func(t *testing.T) {
+		afterGrant := time.Now()
+
+		_, err := testService.Coin().Send(user.ID, dto.SendCoinRequest{
+			ToUser: "historian-friend",
+			Amount: 100,
+		})
+		assert.NoError(err)
+
+		balance, err := testService.User().GetBalanceAt(user.ID, afterGrant)
+		assert.NoError(err)
+		assert.Equal(int64(1000), balance.Coins)
+
+		balance, err = testService.User().GetBalanceAt(user.ID, time.Now())
+		assert.NoError(err)
+		assert.Equal(int64(900), balance.Coins)
+	})
+
+	t.Run("snapshots keep the balance", func(t *testing.T) {
+		beforeSnapshot := time.Now()
+		taken, err := testStorage.Balance().TakeSnapshots(time.Now())
+		require.NoError(t, err)
+		assert.Positive(taken)
+
+		taken, err = testStorage.Balance().TakeSnapshots(time.Now())
+		require.NoError(t, err)
+		assert.Zero(taken, "users without new transactions keep their snapshot")
+
+		_, err = testService.Coin().Send(user.ID, dto.SendCoinRequest{ToUser: "historian-friend", Amount: 50})
+		require.NoError(t, err)
+
+		balance, err := testService.User().GetBalanceAt(user.ID, beforeSnapshot)
+		assert.NoError(err)
+		assert.Equal(int64(900), balance.Coins)
+
+		balance, err = testService.User().GetBalanceAt(user.ID, time.Now())
+		assert.NoError(err)
+		assert.Equal(int64(850), balance.Coins)
+	})
+}
+
+func TestTransactionHistory(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "searcher")
+	createTestUser(t, "searcher-friend")
+
+	for _, req := range []dto.SendCoinRequest{
+		{ToUser: "searcher-friend", Amount: 10, Memo: "pizza friday", Category: "lunch"},
+		{ToUser: "searcher-friend", Amount: 20, Memo: "100% effort", Category: "thanks"},
+		{ToUser: "searcher-friend", Amount: 30, Memo: "code_review", Category: "help"},
+	} {
+		_, err := testService.Coin().Send(user.ID, req)
+		require.NoError(t, err)
+	}
+	require.NoError(t, testService.Inventory().BuyItem(user.ID, "cup", dto.BuyQuery{}))
+
+	memos := func(query dto.HistoryQuery) []string {
+		history, err := testService.History().GetHistory(user.ID, query)
+		require.NoError(t, err)
+
+		memos := make([]string, 0, len(history.Transactions))
+		for _, entry := range history.Transactions {
+			memos = append(memos, entry.Memo)
+		}
+		return memos
+	}
+
+	assert.Equal([]string{"pizza friday"}, memos(dto.HistoryQuery{Query: "PIZZA"}))
+	assert.Equal([]string{"100% effort"}, memos(dto.HistoryQuery{Query: "%"}), "LIKE wildcards are escaped")
+	assert.Equal([]string{"code_review"}, memos(dto.HistoryQuery{Query: "_"}), "LIKE wildcards are escaped")
+	assert.Equal([]string{"code_review", "100% effort", "pizza friday"}, memos(dto.HistoryQuery{Query: "searcher-friend"}),
+		"the query also matches usernames")
+	assert.Equal([]string{"100% effort"}, memos(dto.HistoryQuery{Category: "thanks"}))
+	assert.Equal([]string{"code_review", "100% effort", "pizza friday"}, memos(dto.HistoryQuery{Type: "transfer"}))
+	assert.Equal([]string{"", "code_review"}, memos(dto.HistoryQuery{Limit: 2}))
+	assert.Equal([]string{"100% effort"}, memos(dto.HistoryQuery{Limit: 1, Offset: 2}))
+
+	history, err := testService.History().GetHistory(user.ID, dto.HistoryQuery{Type: "purchase"})
+	assert.NoError(err)
+	if assert.Len(history.Transactions, 1) {
+		assert.Equal("cup", history.Transactions[0].Item)
+	}
+}
+
+func TestPaymentRequestService(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	requester := createTestUser(t, "invoicer")
+	payer := createTestUser(t, "invoicee")
+
+	t.Run("accept payment request", func(t *testing.T) {
+		request, err := testService.PaymentRequest().Create(requester.ID, dto.CreatePaymentRequest{
+			FromUser: "invoicee",
+			Amount:   50,
+			Memo:     "lunch split",
+		})
+		assert.NoError(err)
+		assert.Equal("pending", request.Status)
+
+		resp, err := testService.PaymentRequest().Accept(payer.ID, request.ID)
+		assert.NoError(err)
+		assert.Equal("completed", resp.Status)
+
+		info, err := testService.User().GetInfo(requester.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1050), info.Coins)
+
+		_, err = testService.PaymentRequest().Accept(payer.ID, request.ID)
 		assert.Error(err)
 	})
+
+	t.Run("accept above the approval threshold", func(t *testing.T) {
+		manager := createTestUser(t, "invoice-manager")
+
+		request, err := testService.PaymentRequest().Create(requester.ID, dto.CreatePaymentRequest{
+			FromUser: "invoicee",
+			Amount:   600,
+			Memo:     "offsite deposit",
+		})
+		require.NoError(t, err)
+
+		resp, err := testService.PaymentRequest().Accept(payer.ID, request.ID)
+		require.NoError(t, err)
+		assert.Equal("pending_approval", resp.Status)
+		require.NotZero(t, resp.PendingTransferID)
+
+		info, err := testService.User().GetInfo(payer.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1000-50-600), info.Coins)
+		assert.Equal(int64(600), info.PendingCoins)
+
+		info, err = testService.User().GetInfo(requester.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1050), info.Coins, "nothing is paid before approval")
+
+		requests, err := testService.PaymentRequest().List(payer.ID)
+		assert.NoError(err)
+		assert.Equal("accepted", requests.Incoming[0].Status)
+
+		require.NoError(t, testService.Approval().ApproveTransfer(manager.ID, resp.PendingTransferID))
+
+		info, err = testService.User().GetInfo(requester.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1650), info.Coins)
+	})
+
+	t.Run("rejected hold declines the request", func(t *testing.T) {
+		manager := createTestUser(t, "invoice-rejecter")
+		held := createTestUser(t, "invoice-held")
+
+		request, err := testService.PaymentRequest().Create(requester.ID, dto.CreatePaymentRequest{
+			FromUser: "invoice-held",
+			Amount:   600,
+		})
+		require.NoError(t, err)
+
+		resp, err := testService.PaymentRequest().Accept(held.ID, request.ID)
+		require.NoError(t, err)
+		require.NoError(t, testService.Approval().RejectTransfer(manager.ID, resp.PendingTransferID))
+
+		requests, err := testService.PaymentRequest().List(held.ID)
+		assert.NoError(err)
+		require.Len(t, requests.Incoming, 1)
+		assert.Equal("declined", requests.Incoming[0].Status)
+	})
+
+	t.Run("decline payment request", func(t *testing.T) {
+		request, err := testService.PaymentRequest().Create(requester.ID, dto.CreatePaymentRequest{
+			FromUser: "invoicee",
+			Amount:   10,
+		})
+		assert.NoError(err)
+
+		err = testService.PaymentRequest().Decline(payer.ID, request.ID)
+		assert.NoError(err)
+
+		requests, err := testService.PaymentRequest().List(payer.ID)
+		assert.NoError(err)
+		assert.Equal("declined", requests.Incoming[0].Status)
+	})
+
+	t.Run("expiry is stored", func(t *testing.T) {
+		overdue := func() models.PaymentRequest {
+			request, err := testStorage.PaymentRequest().Create(models.PaymentRequest{
+				RequesterID: requester.ID,
+				PayerID:     payer.ID,
+				Amount:      10,
+				ExpiresAt:   time.Now().Add(-time.Minute),
+			})
+			require.NoError(t, err)
+			return request
+		}
+
+		accepted := overdue()
+		_, err := testService.PaymentRequest().Accept(payer.ID, accepted.ID)
+		assert.ErrorContains(err, "payment request has expired")
+
+		overdue()
+		expired, err := testStorage.PaymentRequest().ExpireDue()
+		assert.NoError(err)
+		assert.Equal(int64(1), expired, "the accept attempt already stored its expiry")
+
+		expired, err = testStorage.PaymentRequest().ExpireDue()
+		assert.NoError(err)
+		assert.Zero(expired)
+	})
+}
+
+func TestMonthlyAllowance(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "allowance-owner")
+	friend := createTestUser(t, "allowance-friend")
+
+	require.NoError(t, testService.Allowance().TopUp())
+	require.NoError(t, testService.Allowance().TopUp())
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(200), info.GiftableCoins)
+
+	t.Run("gifts use the allowance first", func(t *testing.T) {
+		_, err := testService.Coin().Send(user.ID, dto.SendCoinRequest{ToUser: "allowance-friend", Amount: 250})
+		assert.NoError(err)
+
+		info, err := testService.User().GetInfo(user.ID)
+		assert.NoError(err)
+		assert.Zero(info.GiftableCoins)
+		assert.Equal(int64(950), info.Coins)
+
+		info, err = testService.User().GetInfo(friend.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1250), info.Coins)
+		assert.Equal(int64(200), info.GiftableCoins)
+	})
+
+	t.Run("balance history counts only spendable coins", func(t *testing.T) {
+		for _, u := range []*models.User{user, friend} {
+			info, err := testService.User().GetInfo(u.ID)
+			assert.NoError(err)
+
+			balance, err := testService.User().GetBalanceAt(u.ID, time.Now())
+			assert.NoError(err)
+			assert.Equal(info.Coins, balance.Coins)
+		}
+	})
+
+	t.Run("purchases cannot use the allowance", func(t *testing.T) {
+		for _, item := range []string{"pink-hoody", "pink-hoody", "powerbank", "book"} {
+			require.NoError(t, testService.Inventory().BuyItem(friend.ID, item, dto.BuyQuery{}))
+		}
+
+		err := testService.Inventory().BuyItem(friend.ID, "cup", dto.BuyQuery{})
+		assert.ErrorContains(err, "insufficient funds")
+
+		info, err := testService.User().GetInfo(friend.ID)
+		assert.NoError(err)
+		assert.Zero(info.Coins)
+		assert.Equal(int64(200), info.GiftableCoins)
+	})
+
+	t.Run("rejected hold does not roll the allowance over", func(t *testing.T) {
+		holder := createTestUser(t, "allowance-holder")
+		manager := createTestUser(t, "allowance-manager")
+		require.NoError(t, testService.Allowance().TopUp())
+
+		resp, err := testService.Coin().Send(holder.ID, dto.SendCoinRequest{ToUser: "allowance-friend", Amount: 600})
+		require.NoError(t, err)
+		require.Equal(t, "pending_approval", resp.Status)
+
+		_, err = testStorage.Allowance().TopUpUsers(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC), 200)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, testService.Allowance().TopUp()) }()
+
+		require.NoError(t, testService.Approval().RejectTransfer(manager.ID, resp.PendingTransferID))
+
+		info, err := testService.User().GetInfo(holder.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1000), info.Coins)
+		assert.Equal(int64(200), info.GiftableCoins, "last month's allowance is not given back")
+	})
+}
+
+func TestTeamBudget(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	lead := createTestUser(t, "budget-lead")
+	member := createTestUser(t, "budget-member")
+	createTestUser(t, "budget-outsider")
+
+	team, err := testService.Team().Create(dto.CreateTeamRequest{Name: "budget-team", Lead: "budget-lead", MonthlyBudget: 300})
+	require.NoError(t, err)
+	_, err = testService.Team().AddMembers(team.ID, dto.TeamMembersRequest{Usernames: []string{"budget-member"}})
+	require.NoError(t, err)
+
+	err = testService.Team().Grant(lead.ID, dto.TeamGrantRequest{ToUser: "budget-member", Amount: 100})
+	assert.ErrorContains(err, "team budget is too low", "the budget is empty until the monthly top-up")
+
+	require.NoError(t, testService.Allowance().TopUp())
+
+	t.Run("lead grants to a member", func(t *testing.T) {
+		require.NoError(t, testService.Team().Grant(lead.ID, dto.TeamGrantRequest{
+			ToUser: "budget-member",
+			Amount: 100,
+			Memo:   "release party",
+		}))
+
+		info, err := testService.User().GetInfo(member.ID)
+		assert.NoError(err)
+		assert.Equal(int64(1100), info.Coins)
+
+		team, err := testService.Team().GetUserTeam(member.ID)
+		assert.NoError(err)
+		assert.Equal(int64(200), team.Budget)
+	})
+
+	t.Run("rejected grants", func(t *testing.T) {
+		err := testService.Team().Grant(lead.ID, dto.TeamGrantRequest{ToUser: "budget-member", Amount: 250})
+		assert.ErrorContains(err, "team budget is too low")
+
+		err = testService.Team().Grant(lead.ID, dto.TeamGrantRequest{ToUser: "budget-outsider", Amount: 10})
+		assert.ErrorContains(err, "not a member of your team")
+
+		err = testService.Team().Grant(lead.ID, dto.TeamGrantRequest{ToUser: "budget-lead", Amount: 10})
+		assert.ErrorContains(err, "yourself")
+
+		err = testService.Team().Grant(member.ID, dto.TeamGrantRequest{ToUser: "budget-lead", Amount: 10})
+		assert.ErrorContains(err, "only team leads")
+
+		team, err := testService.Team().GetUserTeam(member.ID)
+		assert.NoError(err)
+		assert.Equal(int64(200), team.Budget)
+	})
+}
+
+func TestMerchReturn(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "returns-buyer")
+	require.NoError(t, testService.Inventory().BuyItem(user.ID, "book", dto.BuyQuery{}))
+
+	history, err := testService.History().GetHistory(user.ID, dto.HistoryQuery{Type: "purchase"})
+	require.NoError(t, err)
+	require.Len(t, history.Transactions, 1)
+	purchaseID := history.Transactions[0].ID
+
+	orders, err := testService.Order().List(user.ID)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	_, err = testService.Return().Create(user.ID, purchaseID, dto.ReturnRequest{})
+	assert.Error(err)
+
+	for _, status := range []string{"ready_for_pickup", "handed_over"} {
+		err = testService.Order().UpdateStatus(user.ID, orders[0].ID, dto.OrderStatusRequest{Status: status})
+		require.NoError(t, err)
+	}
+
+	ret, err := testService.Return().Create(user.ID, purchaseID, dto.ReturnRequest{Reason: "wrong size"})
+	assert.NoError(err)
+	assert.Equal("approved", ret.Status)
+	assert.NotNil(ret.RefundTransactionID)
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(1000), info.Coins)
+	assert.Empty(info.Inventory)
+
+	_, err = testService.Return().Create(user.ID, purchaseID, dto.ReturnRequest{})
+	assert.Error(err)
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "orders-buyer")
+	require.NoError(t, testService.Inventory().BuyItem(user.ID, "cup", dto.BuyQuery{}))
+
+	orders, err := testService.Order().List(user.ID)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal("placed", orders[0].Status)
+
+	queue, err := testService.Order().GetOrders(dto.OrdersQuery{})
+	assert.NoError(err)
+	assert.Len(queue, 1)
+
+	err = testService.Order().UpdateStatus(user.ID, orders[0].ID, dto.OrderStatusRequest{Status: "handed_over"})
+	assert.Error(err)
+
+	err = testService.Order().UpdateStatus(user.ID, orders[0].ID, dto.OrderStatusRequest{Status: "ready_for_pickup"})
+	assert.NoError(err)
+
+	other := createTestUser(t, "orders-other")
+	assert.Error(testService.Order().Cancel(other.ID, orders[0].ID))
+
+	assert.NoError(testService.Order().Cancel(user.ID, orders[0].ID))
+	assert.Error(testService.Order().Cancel(user.ID, orders[0].ID))
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(1000), info.Coins)
+	assert.Empty(info.Inventory)
+
+	orders, err = testService.Order().List(user.ID)
+	assert.NoError(err)
+	assert.Equal("cancelled", orders[0].Status)
+	assert.NotNil(orders[0].RefundTransactionID)
+}
+
+func TestMerchVariants(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "variants-buyer")
+
+	variants, err := testService.Inventory().GetVariants("hoody")
+	assert.NoError(err)
+	assert.NotEmpty(variants)
+
+	assert.Error(testService.Inventory().BuyItem(user.ID, "hoody", dto.BuyQuery{}))
+	assert.Error(testService.Inventory().BuyItem(user.ID, "hoody", dto.BuyQuery{SKU: "unknown-sku"}))
+	assert.Error(testService.Inventory().BuyItem(user.ID, "cup", dto.BuyQuery{SKU: "HOODY-BLK-M"}))
+
+	stock := int64(1)
+	assert.NoError(testService.Inventory().SetVariantStock("HOODY-BLK-M", dto.VariantStockRequest{Stock: &stock}))
+	assert.NoError(testService.Inventory().BuyItem(user.ID, "hoody", dto.BuyQuery{SKU: "HOODY-BLK-M"}))
+	assert.Error(testService.Inventory().BuyItem(user.ID, "hoody", dto.BuyQuery{SKU: "HOODY-BLK-M"}))
+	assert.NoError(testService.Inventory().BuyItem(user.ID, "hoody", dto.BuyQuery{SKU: "HOODY-BLK-L"}))
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(400), info.Coins)
+	require.Len(t, info.Inventory, 2)
+	for _, item := range info.Inventory {
+		assert.Equal("hoody", item.Type)
+		assert.Equal(int64(1), item.Quantity)
+		assert.Contains([]string{"HOODY-BLK-M", "HOODY-BLK-L"}, item.SKU)
+	}
+
+	orders, err := testService.Order().List(user.ID)
+	assert.NoError(err)
+	require.Len(t, orders, 2)
+	assert.NotEmpty(orders[0].Size)
+}
+
+func TestPromotions(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	manager := createTestUser(t, "promo-manager")
+	user := createTestUser(t, "promo-buyer")
+
+	sale, err := testService.Promotion().Create(manager.ID, dto.PromotionRequest{
+		Name:  "umbrella week",
+		Kind:  "percent",
+		Value: 20,
+		Item:  "umbrella",
+	})
+	require.NoError(t, err)
+	defer testService.Promotion().End(sale.ID)
+
+	limit := int64(1)
+	_, err = testService.Promotion().Create(manager.ID, dto.PromotionRequest{
+		Name:         "conference",
+		Code:         "conf2024",
+		Kind:         "fixed",
+		Value:        30,
+		Category:     "apparel",
+		PerUserLimit: &limit,
+	})
+	require.NoError(t, err)
+
+	_, err = testService.Promotion().Create(manager.ID, dto.PromotionRequest{Name: "too much", Kind: "percent", Value: 150})
+	assert.Error(err)
+
+	active, err := testService.Promotion().GetActive()
+	assert.NoError(err)
+	assert.Len(active, 1)
+
+	assert.NoError(testService.Inventory().BuyItem(user.ID, "umbrella", dto.BuyQuery{}))
+	assert.Error(testService.Inventory().BuyItem(user.ID, "cup", dto.BuyQuery{PromoCode: "CONF2024"}))
+	assert.NoError(testService.Inventory().BuyItem(user.ID, "t-shirt", dto.BuyQuery{PromoCode: "CONF2024"}))
+	assert.Error(testService.Inventory().BuyItem(user.ID, "t-shirt", dto.BuyQuery{PromoCode: "conf2024"}))
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(1000-160-50), info.Coins)
+
+	history, err := testService.History().GetHistory(user.ID, dto.HistoryQuery{Type: "purchase"})
+	assert.NoError(err)
+	require.Len(t, history.Transactions, 2)
+	assert.Equal(int64(50), history.Transactions[0].Amount)
+	require.NotNil(t, history.Transactions[0].OriginalAmount)
+	assert.Equal(int64(80), *history.Transactions[0].OriginalAmount)
+}
+
+func TestPreorders(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "preorder-buyer")
+	other := createTestUser(t, "preorder-other")
+
+	stock := int64(1)
+	limit := int64(1)
+	from := time.Now().Add(time.Hour)
+	_, err := testService.Inventory().CreateItem(dto.MerchRequest{
+		Name:  "drop-cap",
+		Price: 100,
+		Stock: &stock,
+		AvailabilityRequest: dto.AvailabilityRequest{
+			AvailableFrom: &from,
+			PerUserLimit:  &limit,
+			PreordersOpen: true,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Error(testService.Inventory().BuyItem(user.ID, "drop-cap", dto.BuyQuery{}))
+
+	_, err = testService.Preorder().Create(user.ID, dto.PreorderRequest{Item: "drop-cap"})
+	require.NoError(t, err)
+	_, err = testService.Preorder().Create(user.ID, dto.PreorderRequest{Item: "drop-cap"})
+	assert.Error(err)
+	_, err = testService.Preorder().Create(other.ID, dto.PreorderRequest{Item: "drop-cap"})
+	require.NoError(t, err)
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(900), info.Coins)
+	assert.Equal(int64(100), info.PreorderCoins)
+	assert.Zero(info.PendingCoins, "pre-orders are not transfers awaiting approval")
+
+	now := time.Now()
+	require.NoError(t, testService.Inventory().SetAvailability("drop-cap", dto.AvailabilityRequest{
+		AvailableFrom: &now,
+		PerUserLimit:  &limit,
+	}))
+	require.NoError(t, testService.Preorder().FulfilDue())
+
+	preorders, err := testService.Preorder().List(user.ID)
+	assert.NoError(err)
+	require.Len(t, preorders, 1)
+	assert.Equal("fulfilled", preorders[0].Status)
+
+	preorders, err = testService.Preorder().List(other.ID)
+	assert.NoError(err)
+	require.Len(t, preorders, 1)
+	assert.Equal("refunded", preorders[0].Status)
+
+	info, err = testService.User().GetInfo(other.ID)
+	assert.NoError(err)
+	assert.Equal(int64(1000), info.Coins)
+	assert.Zero(info.PreorderCoins)
+
+	info, err = testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Zero(info.PreorderCoins)
+	require.Len(t, info.Inventory, 1)
+	assert.Equal("drop-cap", info.Inventory[0].Type)
+
+	assert.Error(testService.Inventory().BuyItem(user.ID, "drop-cap", dto.BuyQuery{}))
+}
+
+func TestCancelDrop(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	user := createTestUser(t, "drop-buyer")
+
+	from := time.Now().Add(24 * time.Hour)
+	_, err := testService.Inventory().CreateItem(dto.MerchRequest{
+		Name:                "drop-scarf",
+		Price:               150,
+		AvailabilityRequest: dto.AvailabilityRequest{AvailableFrom: &from, PreordersOpen: true},
+	})
+	require.NoError(t, err)
+
+	_, err = testService.Preorder().Create(user.ID, dto.PreorderRequest{Item: "drop-scarf"})
+	require.NoError(t, err)
+
+	assert.NoError(testService.Preorder().CancelDrop("drop-scarf"))
+	assert.Error(testService.Preorder().CancelDrop("drop-scarf"))
+
+	info, err := testService.User().GetInfo(user.ID)
+	assert.NoError(err)
+	assert.Equal(int64(1000), info.Coins)
+	assert.Zero(info.PreorderCoins)
+
+	_, err = testService.Preorder().Create(user.ID, dto.PreorderRequest{Item: "drop-scarf"})
+	assert.Error(err)
+}
+
+func TestGifts(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "gift-sender")
+	recipient := createTestUser(t, "gift-recipient")
+
+	assert.Error(testService.Inventory().Gift(sender.ID, dto.GiftRequest{ToUser: "gift-sender", Item: "cup"}))
+	assert.Error(testService.Inventory().Gift(sender.ID, dto.GiftRequest{ToUser: "nobody-here", Item: "cup"}))
+
+	require.NoError(t, testService.Inventory().Gift(sender.ID, dto.GiftRequest{
+		ToUser: "gift-recipient",
+		Item:   "cup",
+		Memo:   "happy birthday",
+	}))
+
+	info, err := testService.User().GetInfo(sender.ID)
+	assert.NoError(err)
+	assert.Equal(int64(980), info.Coins)
+	assert.Empty(info.Inventory)
+
+	info, err = testService.User().GetInfo(recipient.ID)
+	assert.NoError(err)
+	assert.Equal(int64(1000), info.Coins)
+	require.Len(t, info.Inventory, 1)
+	assert.Equal("cup", info.Inventory[0].Type)
+
+	balance, err := testService.User().GetBalanceAt(recipient.ID, time.Now())
+	assert.NoError(err)
+	assert.Equal(int64(1000), balance.Coins)
+
+	orders, err := testService.Order().List(recipient.ID)
+	assert.NoError(err)
+	assert.Len(orders, 1)
+
+	history, err := testService.History().GetHistory(recipient.ID, dto.HistoryQuery{Type: "gift"})
+	assert.NoError(err)
+	require.Len(t, history.Transactions, 1)
+	assert.Equal("gift-sender", history.Transactions[0].FromUser)
+	assert.Equal("happy birthday", history.Transactions[0].Memo)
+
+	assert.Error(testService.Inventory().TransferItem(sender.ID, dto.ItemTransferRequest{ToUser: "gift-recipient", Item: "cup"}))
+	require.NoError(t, testService.Inventory().TransferItem(recipient.ID, dto.ItemTransferRequest{ToUser: "gift-sender", Item: "cup"}))
+
+	info, err = testService.User().GetInfo(sender.ID)
+	assert.NoError(err)
+	assert.Equal(int64(980), info.Coins)
+	require.Len(t, info.Inventory, 1)
+	assert.Equal("cup", info.Inventory[0].Type)
+
+	info, err = testService.User().GetInfo(recipient.ID)
+	assert.NoError(err)
+	assert.Empty(info.Inventory)
+
+	history, err = testService.History().GetHistory(sender.ID, dto.HistoryQuery{Type: "item_transfer"})
+	assert.NoError(err)
+	require.Len(t, history.Transactions, 1)
+	assert.Equal("gift-recipient", history.Transactions[0].FromUser)
+	assert.Zero(history.Transactions[0].Amount)
+}
+
+func TestMarketplace(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	cfg, err := config.LoadConfig("../../config/config.yml")
+	require.NoError(t, err)
+	fee := int64(40) * cfg.Settings.Service.MarketplaceFeePercent / 100
+
+	seller := createTestUser(t, "market-seller")
+	buyer := createTestUser(t, "market-buyer")
+
+	require.NoError(t, testService.Inventory().BuyItem(seller.ID, "cup", dto.BuyQuery{}))
+
+	listing, err := testService.Marketplace().CreateListing(seller.ID, dto.ListingRequest{Item: "cup", Price: 40})
+	require.NoError(t, err)
+	assert.Equal("market-seller", listing.Seller)
+	assert.Equal("active", listing.Status)
+
+	_, err = testService.Marketplace().CreateListing(seller.ID, dto.ListingRequest{Item: "cup", Price: 40})
+	assert.Error(err)
+
+	info, err := testService.User().GetInfo(seller.ID)
+	assert.NoError(err)
+	assert.Empty(info.Inventory)
+
+	found, err := testService.Marketplace().Search(dto.ListingsQuery{Query: "market-seller", Item: "cup"})
+	assert.NoError(err)
+	require.Len(t, found, 1)
+	assert.Equal(listing.ID, found[0].ID)
+
+	assert.Error(testService.Marketplace().Buy(seller.ID, listing.ID))
+	require.NoError(t, testService.Marketplace().Buy(buyer.ID, listing.ID))
+	assert.Error(testService.Marketplace().Buy(buyer.ID, listing.ID))
+
+	info, err = testService.User().GetInfo(seller.ID)
+	assert.NoError(err)
+	assert.Equal(int64(980+40)-fee, info.Coins)
+
+	info, err = testService.User().GetInfo(buyer.ID)
+	assert.NoError(err)
+	assert.Equal(int64(960), info.Coins)
+	require.Len(t, info.Inventory, 1)
+	assert.Equal("cup", info.Inventory[0].Type)
+
+	history, err := testService.History().GetHistory(seller.ID, dto.HistoryQuery{Type: "marketplace_sale"})
+	assert.NoError(err)
+	require.Len(t, history.Transactions, 1)
+	assert.Equal("market-buyer", history.Transactions[0].FromUser)
+
+	notifications, err := testService.Notification().List(seller.ID, dto.NotificationsQuery{})
+	assert.NoError(err)
+	messages := make([]string, 0, len(notifications.Notifications))
+	for _, notification := range notifications.Notifications {
+		messages = append(messages, notification.Message)
+	}
+	assert.Contains(messages, "market-buyer bought your listing for 40 coins")
+
+	relisted, err := testService.Marketplace().CreateListing(buyer.ID, dto.ListingRequest{Item: "cup", Price: 60})
+	require.NoError(t, err)
+	assert.Error(testService.Marketplace().Cancel(seller.ID, relisted.ID))
+	require.NoError(t, testService.Marketplace().Cancel(buyer.ID, relisted.ID))
+
+	mine, err := testService.Marketplace().MyListings(buyer.ID)
+	assert.NoError(err)
+	require.Len(t, mine, 1)
+	assert.Equal("cancelled", mine[0].Status)
+
+	info, err = testService.User().GetInfo(buyer.ID)
+	assert.NoError(err)
+	require.Len(t, info.Inventory, 1)
+
+	_, err = testService.Auth().Login(dto.AuthRequest{Username: "system", Password: "password123"})
+	assert.Error(err)
+
+	t.Run("price above the approval threshold", func(t *testing.T) {
+		_, err := testService.Marketplace().CreateListing(buyer.ID, dto.ListingRequest{
+			Item:  "cup",
+			Price: cfg.Settings.Service.ApprovalThreshold + 1,
+		})
+		assert.ErrorContains(err, "approval threshold")
+	})
+
+	t.Run("purchase counts against the buyer's transfer limits", func(t *testing.T) {
+		spender := createTestUser(t, "market-spender")
+		require.NoError(t, testStorage.User().UpdateUserCoins(spender.ID, 5000))
+		for i := 0; i < 2; i++ {
+			_, err := testService.Coin().Send(spender.ID, dto.SendCoinRequest{ToUser: "market-seller", Amount: 1000})
+			require.NoError(t, err)
+		}
+
+		listing, err := testService.Marketplace().CreateListing(buyer.ID, dto.ListingRequest{Item: "cup", Price: 40})
+		require.NoError(t, err)
+
+		err = testService.Marketplace().Buy(spender.ID, listing.ID)
+		assert.ErrorContains(err, "daily transfer limit of 2000 coins exceeded")
+
+		info, err := testService.User().GetInfo(spender.ID)
+		assert.NoError(err)
+		assert.Empty(info.Inventory)
+
+		limits, err := testService.Coin().GetLimits(buyer.ID)
+		assert.NoError(err)
+		assert.Equal(int64(40), limits.Daily.Used, "the earlier purchase counts as spent")
+	})
+}
+
+func TestWishlist(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	manager := createTestUser(t, "wish-manager")
+	user := createTestUser(t, "wish-user")
+
+	_, err := testService.Inventory().CreateItem(dto.MerchRequest{Name: "wish-lamp", Price: 1600})
+	require.NoError(t, err)
+	soldOut := int64(0)
+	_, err = testService.Inventory().AddVariant("wish-lamp", dto.VariantRequest{SKU: "LAMP-WHT", Color: "white", Stock: &soldOut})
+	require.NoError(t, err)
+
+	_, err = testService.Wishlist().Add(user.ID, dto.WishlistRequest{Item: "wish-lamp"})
+	assert.Error(err)
+
+	item, err := testService.Wishlist().Add(user.ID, dto.WishlistRequest{Item: "wish-lamp", SKU: "LAMP-WHT"})
+	require.NoError(t, err)
+	assert.False(item.InStock)
+	assert.False(item.Affordable)
+
+	_, err = testService.Wishlist().Add(user.ID, dto.WishlistRequest{Item: "wish-lamp", SKU: "LAMP-WHT"})
+	assert.Error(err)
+
+	require.NoError(t, testService.Wishlist().Evaluate())
+	notifications, err := testService.Notification().List(user.ID, dto.NotificationsQuery{})
+	assert.NoError(err)
+	assert.Empty(notifications.Notifications)
+
+	restocked := int64(3)
+	require.NoError(t, testService.Inventory().SetVariantStock("LAMP-WHT", dto.VariantStockRequest{Stock: &restocked}))
+	sale, err := testService.Promotion().Create(manager.ID, dto.PromotionRequest{
+		Name:  "lamp sale",
+		Kind:  "percent",
+		Value: 50,
+		Item:  "wish-lamp",
+	})
+	require.NoError(t, err)
+	defer testService.Promotion().End(sale.ID)
+
+	require.NoError(t, testService.Wishlist().Evaluate())
+	require.NoError(t, testService.Wishlist().Evaluate())
+
+	notifications, err = testService.Notification().List(user.ID, dto.NotificationsQuery{})
+	assert.NoError(err)
+	require.Len(t, notifications.Notifications, 3)
+	kinds := make([]string, 0, len(notifications.Notifications))
+	for _, n := range notifications.Notifications {
+		kinds = append(kinds, n.Type)
+	}
+	assert.ElementsMatch([]string{"wishlist_back_in_stock", "wishlist_price_drop", "wishlist_affordable"}, kinds)
+
+	items, err := testService.Wishlist().List(user.ID)
+	assert.NoError(err)
+	require.Len(t, items, 1)
+	assert.Equal(int64(800), items[0].Price)
+	assert.True(items[0].InStock)
+	assert.True(items[0].Affordable)
+
+	assert.NoError(testService.Wishlist().Remove(user.ID, item.ID))
+	assert.Error(testService.Wishlist().Remove(user.ID, item.ID))
+}
+
+func TestNotifications(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "notify-sender")
+	recipient := createTestUser(t, "notify-recipient")
+
+	require.NoError(t, testService.Notification().SetPreferences(recipient.ID, dto.NotificationPreferences{
+		Muted: []string{"merch_purchased"},
+	}))
+	preferences, err := testService.Notification().GetPreferences(recipient.ID)
+	assert.NoError(err)
+	assert.Equal([]string{"merch_purchased"}, preferences.Muted)
+
+	_, err = testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "notify-recipient", Amount: 10, Memo: "thanks"})
+	require.NoError(t, err)
+	require.NoError(t, testService.Inventory().BuyItem(recipient.ID, "pen", dto.BuyQuery{}))
+	require.NoError(t, testService.Inventory().BuyItem(sender.ID, "pen", dto.BuyQuery{}))
+
+	notifications, err := testService.Notification().List(recipient.ID, dto.NotificationsQuery{})
+	assert.NoError(err)
+	assert.Equal(int64(1), notifications.UnreadCount)
+	require.Len(t, notifications.Notifications, 1)
+	assert.Equal("coins_received", notifications.Notifications[0].Type)
+	assert.Equal("notify-sender sent you 10 coins: thanks", notifications.Notifications[0].Message)
+
+	notifications, err = testService.Notification().List(sender.ID, dto.NotificationsQuery{})
+	assert.NoError(err)
+	require.Len(t, notifications.Notifications, 1)
+	assert.Equal("merch_purchased", notifications.Notifications[0].Type)
+
+	assert.Error(testService.Notification().MarkRead(recipient.ID, dto.MarkNotificationsReadRequest{}))
+	require.NoError(t, testService.Notification().MarkRead(recipient.ID, dto.MarkNotificationsReadRequest{All: true}))
+
+	notifications, err = testService.Notification().List(recipient.ID, dto.NotificationsQuery{Unread: true})
+	assert.NoError(err)
+	assert.Zero(notifications.UnreadCount)
+	assert.Empty(notifications.Notifications)
+
+	t.Run("batch and approved transfers", func(t *testing.T) {
+		manager := createTestUser(t, "notify-manager")
+
+		_, err := testService.Coin().SendBatch(sender.ID, dto.BatchSendCoinRequest{
+			Transfers: []dto.BatchTransfer{{ToUser: "notify-recipient", Amount: 20}},
+		})
+		require.NoError(t, err)
+
+		resp, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "notify-recipient", Amount: 600})
+		require.NoError(t, err)
+		require.Equal(t, "pending_approval", resp.Status)
+		require.NoError(t, testService.Approval().ApproveTransfer(manager.ID, resp.PendingTransferID))
+
+		notifications, err := testService.Notification().List(recipient.ID, dto.NotificationsQuery{Unread: true})
+		assert.NoError(err)
+		require.Len(t, notifications.Notifications, 2)
+		assert.Equal("notify-sender sent you 600 coins", notifications.Notifications[0].Message)
+		assert.Equal("notify-sender sent you 20 coins", notifications.Notifications[1].Message)
+	})
+}
+
+func TestStream(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "stream-sender")
+	recipient := createTestUser(t, "stream-recipient")
+	manager := createTestUser(t, "stream-manager")
+
+	sub := testService.Stream().Subscribe(recipient.ID)
+	defer testService.Stream().Unsubscribe(sub)
+
+	receive := func(sub *pubsub.Subscription, count int) map[string]json.RawMessage {
+		received := make(map[string]json.RawMessage)
+		for len(received) < count {
+			select {
+			case event := <-sub.C:
+				received[event.Type] = event.Data
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for events, got %v", received)
+			}
+		}
+		return received
+	}
+
+	_, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "stream-recipient", Amount: 10, Memo: "thanks"})
+	require.NoError(t, err)
+
+	received := receive(sub, 2)
+
+	updated, err := testStorage.User().GetUserByID(recipient.ID)
+	require.NoError(t, err)
+
+	var balance dto.BalanceEvent
+	require.NoError(t, json.Unmarshal(received["balance"], &balance))
+	assert.Equal(updated.Coins, balance.Coins)
+
+	var transfer dto.TransferEvent
+	require.NoError(t, json.Unmarshal(received["transfer"], &transfer))
+	assert.Equal(dto.TransferEvent{FromUser: "stream-sender", Amount: 10, Memo: "thanks"}, transfer)
+
+	t.Run("approved transfer", func(t *testing.T) {
+		senderSub := testService.Stream().Subscribe(sender.ID)
+		defer testService.Stream().Unsubscribe(senderSub)
+
+		resp, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "stream-recipient", Amount: 600})
+		require.NoError(t, err)
+		require.Equal(t, "pending_approval", resp.Status)
+
+		var balance dto.BalanceEvent
+		require.NoError(t, json.Unmarshal(receive(senderSub, 1)["balance"], &balance))
+		assert.Equal(int64(600), balance.PendingCoins)
+
+		require.NoError(t, testService.Approval().ApproveTransfer(manager.ID, resp.PendingTransferID))
+
+		received := receive(sub, 2)
+		var transfer dto.TransferEvent
+		require.NoError(t, json.Unmarshal(received["transfer"], &transfer))
+		assert.Equal(dto.TransferEvent{FromUser: "stream-sender", Amount: 600}, transfer)
+
+		require.NoError(t, json.Unmarshal(receive(senderSub, 1)["balance"], &balance))
+		assert.Zero(balance.PendingCoins)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header, body: body}
+	}))
+	defer server.Close()
+
+	admin := createTestUser(t, "webhook-admin")
+	recipient := createTestUser(t, "webhook-recipient")
+
+	webhook, err := testService.Webhook().Create(admin.ID, dto.WebhookRequest{
+		URL:        server.URL,
+		EventTypes: []string{"coin.transferred"},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(webhook.Secret)
+	defer testService.Webhook().Delete(webhook.ID)
+
+	_, err = testService.Coin().Send(admin.ID, dto.SendCoinRequest{ToUser: "webhook-recipient", Amount: 10})
+	require.NoError(t, err)
+	require.NoError(t, testService.Inventory().BuyItem(admin.ID, "pen", dto.BuyQuery{}))
+
+	require.NoError(t, outbox.NewRelay(testLogger, testStorage.Outbox(), outbox.NewWebhookSink(testStorage.Webhook())).Run())
+	require.NoError(t, testService.Webhook().Deliver())
+
+	select {
+	case r := <-requests:
+		assert.Equal("coin.transferred", r.header.Get("X-Webhook-Event"))
+
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write([]byte(r.header.Get("X-Webhook-Timestamp") + "."))
+		mac.Write(r.body)
+		assert.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), r.header.Get("X-Webhook-Signature"))
+
+		var payload dto.WebhookPayload
+		require.NoError(t, json.Unmarshal(r.body, &payload))
+		var transfer models.Transaction
+		require.NoError(t, json.Unmarshal(payload.Data, &transfer))
+		assert.Equal(admin.ID, transfer.FromUserID)
+		assert.Equal(recipient.ID, transfer.ToUserID)
+		assert.Equal(int64(10), transfer.Amount)
+	default:
+		t.Fatal("webhook was not delivered")
+	}
+	assert.Empty(requests, "purchases are not subscribed")
+
+	deliveries, err := testService.Webhook().GetDeliveries(dto.WebhookDeliveriesQuery{WebhookID: webhook.ID})
+	assert.NoError(err)
+	require.Len(t, deliveries, 1)
+	assert.Equal("delivered", deliveries[0].Status)
+	assert.Equal(1, deliveries[0].Attempts)
+
+	assert.Error(testService.Webhook().Retry(deliveries[0].ID), "only dead deliveries can be retried")
+}
+
+func TestOutbox(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "outbox-sender")
+	recipient := createTestUser(t, "outbox-recipient")
+
+	_, err := testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "outbox-recipient", Amount: 10})
+	require.NoError(t, err)
+	require.NoError(t, testService.Inventory().BuyItem(sender.ID, "pen", dto.BuyQuery{}))
+
+	sink := outbox.NewMemorySink()
+	require.NoError(t, outbox.NewRelay(testLogger, testStorage.Outbox(), sink).Run())
+
+	var transfers, purchases []models.Transaction
+	for _, event := range sink.Events() {
+		var transaction models.Transaction
+		if event.Type == models.EventUserCreated || json.Unmarshal(event.Payload, &transaction) != nil ||
+			transaction.FromUserID != sender.ID {
+			continue
+		}
+		switch event.Type {
+		case models.EventCoinTransferred:
+			transfers = append(transfers, transaction)
+		case models.EventMerchPurchased:
+			purchases = append(purchases, transaction)
+		}
+	}
+	require.NotEmpty(t, transfers)
+	assert.Equal(recipient.ID, transfers[len(transfers)-1].ToUserID)
+	assert.Equal(int64(10), transfers[len(transfers)-1].Amount)
+	assert.NotEmpty(purchases)
+
+	again := outbox.NewMemorySink()
+	require.NoError(t, outbox.NewRelay(testLogger, testStorage.Outbox(), again).Run())
+	assert.Empty(again.Events(), "published events are not relayed twice")
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	_, err = testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "outbox-recipient", Amount: 10})
+	require.NoError(t, err)
+	assert.Error(outbox.NewRelay(testLogger, testStorage.Outbox(), outbox.NewHTTPSink(down.URL, time.Second)).Run())
+
+	leased := outbox.NewMemorySink()
+	require.NoError(t, outbox.NewRelay(testLogger, testStorage.Outbox(), leased).Run())
+	assert.Empty(leased.Events(), "a failed batch stays leased until the lease runs out")
+}
+
+func TestEmail(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "email-sender")
+	recipient := createTestUser(t, "email-recipient")
+
+	require.NoError(t, testService.Email().UpdateSettings(recipient.ID, dto.EmailSettings{Email: "Email-Recipient@example.com"}))
+	assert.Error(testService.Email().UpdateSettings(sender.ID, dto.EmailSettings{Email: "email-recipient@example.com"}),
+		"addresses are unique")
+	settings, err := testService.Email().GetSettings(recipient.ID)
+	assert.NoError(err)
+	assert.Equal(dto.EmailSettings{Email: "email-recipient@example.com"}, settings)
+
+	_, err = testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "email-recipient", Amount: 10, Memo: "great demo"})
+	require.NoError(t, err)
+	require.NoError(t, testService.Email().SendQueued())
+
+	msg := receiveEmail(t, "email-recipient@example.com")
+	assert.Contains(msg.Data, "Subject: You received 10 coins from email-sender")
+	assert.Contains(msg.Data, "email-sender sent you 10 coins.")
+	assert.Contains(msg.Data, "text/html")
+
+	require.NoError(t, testService.Email().UpdateSettings(recipient.ID, dto.EmailSettings{
+		Email:  "email-recipient@example.com",
+		OptOut: true,
+	}))
+	_, err = testService.Coin().Send(sender.ID, dto.SendCoinRequest{ToUser: "email-recipient", Amount: 10})
+	require.NoError(t, err)
+	require.NoError(t, testService.Email().SendQueued())
+
+	for len(testSMTP.Messages) > 0 {
+		assert.NotContains((<-testSMTP.Messages).To, "email-recipient@example.com", "opted-out users get no email")
+	}
+
+	t.Run("approved transfer", func(t *testing.T) {
+		manager := createTestUser(t, "email-manager")
+		require.NoError(t, testService.Email().UpdateSettings(sender.ID, dto.EmailSettings{Email: "email-sender@example.com"}))
+
+		resp, err := testService.Coin().Send(recipient.ID, dto.SendCoinRequest{ToUser: "email-sender", Amount: 600})
+		require.NoError(t, err)
+		require.Equal(t, "pending_approval", resp.Status)
+		require.NoError(t, testService.Approval().ApproveTransfer(manager.ID, resp.PendingTransferID))
+		require.NoError(t, testService.Email().SendQueued())
+
+		msg := receiveEmail(t, "email-sender@example.com")
+		assert.Contains(msg.Data, "Subject: You received 600 coins from email-recipient")
+	})
+}
+
+func TestSlack(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanup(t)
+
+	assert := assert.New(t)
+
+	sender := createTestUser(t, "slack-sender")
+	recipient := createTestUser(t, "slack-recipient")
+
+	command := func(userID, text string) dto.SlackResponse {
+		resp, err := testService.Slack().HandleCommand(dto.SlackCommand{TeamID: "T1", UserID: userID, Text: text})
+		require.NoError(t, err)
+		return resp
+	}
+
+	command("U1", "unlink")
+	resp := command("U1", "<@U2|bob> 10")
+	assert.Equal("ephemeral", resp.ResponseType, "unlinked users cannot send")
+
+	for user, id := range map[string]int64{"U1": sender.ID, "U2": recipient.ID} {
+		code, err := testService.Slack().CreateLinkCode(id)
+		require.NoError(t, err)
+		assert.Contains(command(user, "link "+strings.ToLower(code.Code)).Text, "Linked")
+		assert.Contains(command(user, "link "+code.Code).Text, "invalid", "codes are single-use")
+	}
+
+	before, err := testStorage.User().GetUserByID(recipient.ID)
+	require.NoError(t, err)
+
+	resp = command("U1", "<@U2|bob> 10 thanks for the review")
+	assert.Equal("in_channel", resp.ResponseType)
+	assert.Equal(":coin: <@U1> sent 10 coins to *slack-recipient*: thanks for the review", resp.Text)
+
+	resp = command("U1", "@slack-recipient 5")
+	assert.Equal("in_channel", resp.ResponseType)
+
+	after, err := testStorage.User().GetUserByID(recipient.ID)
+	require.NoError(t, err)
+	assert.Equal(before.Coins+15, after.Coins)
+
+	resp = command("U1", "<@U2> ten")
+	assert.Equal("ephemeral", resp.ResponseType)
+	resp = command("U1", "<@U1> 10")
+	assert.Contains(resp.Text, "cannot send coins to yourself")
+
+	assert.Contains(command("U2", "unlink").Text, "unlinked")
+	assert.Contains(command("U1", "<@U2> 10").Text, "has not linked")
+}
+
+// receiveEmail waits for a message to the address, skipping messages to
+// others.
+func receiveEmail(t *testing.T, to string) capturedEmail {
+	for {
+		select {
+		case msg := <-testSMTP.Messages:
+			if len(msg.To) == 1 && msg.To[0] == to {
+				return msg
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no email to %s", to)
+		}
+	}
 }
 
 func createTestUser(t *testing.T, username string) *models.User {